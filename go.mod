@@ -7,6 +7,7 @@ require (
 	github.com/leosunmo/ics-golang v0.0.0-20190201052222-09af3d63fa59
 	github.com/leosunmo/timerange-go v1.0.0
 	github.com/mattn/go-runewidth v0.0.6 // indirect
+	github.com/mitchellh/mapstructure v1.1.2
 	github.com/olekukonko/tablewriter v0.0.1
 	github.com/sirupsen/logrus v1.4.2
 	github.com/spf13/pflag v1.0.5
@@ -14,4 +15,5 @@ require (
 	golang.org/x/net v0.0.0-20191105084925-a882066a44e0
 	golang.org/x/oauth2 v0.0.0-20190604053449-0f29369cfe45
 	google.golang.org/api v0.13.0
+	gopkg.in/yaml.v2 v2.2.4
 )
@@ -1,19 +1,16 @@
 package main
 
 import (
+	"context"
 	"os"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
 
-	"github.com/leosunmo/pagertally/pkg/outputs"
-
 	"github.com/leosunmo/pagertally/pkg/config"
 
-	"github.com/leosunmo/pagertally/pkg/datasources"
-
+	"github.com/leosunmo/pagertally/pkg/pagertally"
 	"github.com/leosunmo/pagertally/pkg/pd"
-	"github.com/leosunmo/pagertally/pkg/process"
 )
 
 func main() {
@@ -34,22 +31,52 @@ func main() {
 	default:
 		log.SetLevel(log.InfoLevel)
 	}
+	// "pagertally explain --user X --at T" explains how a single instant was attributed instead of tallying
+	explain := len(os.Args) > 1 && os.Args[1] == "explain"
+	if explain {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
 	// Read config from flags, ENVVARs and config file
 	config.BuildConfig()
 
-	pdClient := pd.NewPDClient(config.PDToken())
+	opts := pagertally.Options{
+		PDToken:   config.PDToken(),
+		Schedules: config.Schedules(),
+		Filter: pd.ScheduleFilter{
+			Teams:              config.Teams(),
+			EscalationPolicies: config.EscalationPolicies(),
+			Match:              config.ScheduleMatch(),
+		},
+		Start:           config.StartDate(),
+		End:             config.EndDate(),
+		PeriodName:      config.PeriodName(),
+		Config:          config.GlobalConfig,
+		ScheduleConfigs: config.ScheduleConfigs,
+		Incidents:       config.Incidents(),
+		OverlapMode:     config.OverlapMode(),
+		UserLabels:      config.UserLabels(),
+	}
+	if explain {
+		if config.ExplainUser() == "" || config.ExplainAt().IsZero() {
+			log.Fatal("explain requires the user to explain ('--user') and the time ('--at')")
+		}
+		explanations, err := pagertally.Explain(context.Background(), opts, config.ExplainUser(), config.ExplainAt())
+		if err != nil {
+			log.Fatal(err)
+		}
+		pagertally.PrintExplanations(os.Stdout, explanations, opts.UserLabels)
+		return
+	}
 
-	scheduleUserShifts, err := pd.ReadShifts(pdClient, config.Schedules(), config.StartDate(), config.EndDate())
+	outputData, err := pagertally.Tally(context.Background(), opts)
 	if err != nil {
-		log.Fatalf("Failed retrieving PagerDuty schedules, %s", err.Error())
+		log.Fatal(err)
+	}
+	if len(outputData.Overlaps) != 0 {
+		log.Warnf("Found %d overlapping shifts across schedules, counted using overlap mode %q", len(outputData.Overlaps), config.OverlapMode())
 	}
 
-	results := process.ScheduleUserShifts(scheduleUserShifts,
-		datasources.NewCompanyDayDataSource(),
-		datasources.NewCalendarDataSource(),
-		datasources.NewWeekendDataSource(),
-		datasources.NewAfterHoursDataSource())
-	outputData := outputs.NewOutputData(results, config.StartDate(), config.EndDate())
 	outputters := config.SelectedOutputs()
 
 	outputErrors := outputData.PrintOutput(outputters)
@@ -39,7 +39,7 @@ type Calendar struct {
 }
 
 // NewCalendar returns an empty calendar
-func NewCalendar(startDate, endDate time.Time, conf *config.ScheduleConfig) *Calendar {
+func NewCalendar(startDate, endDate time.Time, conf *config.ScheduleConfig) (*Calendar, error) {
 
 	// Get a slice of all days between the start and end dates of the schedule
 	calDays := []time.Time{}
@@ -52,7 +52,7 @@ func NewCalendar(startDate, endDate time.Time, conf *config.ScheduleConfig) *Cal
 	loc, err := time.LoadLocation(conf.Timezone)
 
 	if err != nil {
-		panic("Failed loading location from timezone provided")
+		return nil, fmt.Errorf("failed loading location from timezone provided, err: %s", err.Error())
 	}
 	// Get the calendar timezone in second offsets
 
@@ -66,10 +66,10 @@ func NewCalendar(startDate, endDate time.Time, conf *config.ScheduleConfig) *Cal
 	}
 	err = cal.parseAndFilterPublicHolidayiCal(cal.ScheduleConfig.CalendarURL)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	cal.tagAfterhoursAndWeekends()
-	return &cal
+	return &cal, nil
 }
 
 func (c *Calendar) GetBusinessHours() (time.Time, time.Time) {
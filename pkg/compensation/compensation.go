@@ -0,0 +1,353 @@
+package compensation
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+// Rates maps on-call attributes to an hourly amount or multiplier
+type Rates map[timespan.OnCallAttribute]float64
+
+// Amounts is the compensation owed per on-call attribute
+type Amounts map[timespan.OnCallAttribute]float64
+
+// MonthlyAmounts are the amounts owed per calendar month, keyed by the month as 2006-01
+type MonthlyAmounts map[string]Amounts
+
+// Config is the compensation configuration.
+//
+// Each attribute is paid either a flat hourly amount from Rates, or a multiplier
+// of BaseRate from Multipliers. Rates win if an attribute has both.
+// On top of hourly pay, Allowances pay a flat amount per calendar day on call,
+// capped at AllowanceCap per user per calendar month, and CalloutFees
+// pay a flat amount per incident by the attribute at the time of the callout.
+// Schedules can override any of these per schedule, keyed by lower case schedule ID or name.
+// Levels override them per escalation level, see ForLevel.
+type Config struct {
+	Currency     string
+	BaseRate     float64
+	Rates        Rates
+	Multipliers  Rates
+	Allowances   Allowances
+	AllowanceCap float64
+	CalloutFees  Rates
+	Schedules    map[string]ScheduleRates
+	// Levels are the rates per escalation level, applying to that level and any level above it
+	// without its own rates
+	Levels map[int]ScheduleRates
+	// ConcurrentLevels is one of the Concurrent constants and decides how time a user is on call
+	// for more than one escalation level at the same time is paid
+	ConcurrentLevels string
+}
+
+// Ways of paying time a user is on call for more than one escalation level at the same time
+const (
+	// ConcurrentPayAll pays the time on every level, the default
+	ConcurrentPayAll = "all"
+	// ConcurrentPayLowest only pays the time on the lowest level, e.g. only the primary on-call
+	ConcurrentPayLowest = "lowest"
+)
+
+// ScheduleRates overrides the global rates for a single schedule
+type ScheduleRates struct {
+	BaseRate    float64
+	Rates       Rates
+	Multipliers Rates
+	Allowances  Allowances
+	CalloutFees Rates
+}
+
+// Allowance is a flat amount paid for every calendar day a user is on call
+// with a given attribute for at least MinHours
+type Allowance struct {
+	PerDay   float64
+	MinHours float64
+}
+
+// Allowances maps on-call attributes to their daily allowance
+type Allowances map[timespan.OnCallAttribute]Allowance
+
+// Enabled returns true if any compensation has been configured
+func (c Config) Enabled() bool {
+	return c.Currency != "" || c.BaseRate != 0 || len(c.Rates) != 0 || len(c.Multipliers) != 0 || len(c.Schedules) != 0 || len(c.Levels) != 0 || c.AllowancesEnabled() || c.CalloutsEnabled()
+}
+
+// ScheduleKey returns what identifies the schedule with the given ID and name in Schedules,
+// preferring the ID if the schedule has overrides by both
+func (c Config) ScheduleKey(id, name string) string {
+	if _, exists := c.Schedules[strings.ToLower(id)]; exists && id != "" {
+		return id
+	}
+	return name
+}
+
+// CalloutsEnabled returns true if any callout fees have been configured
+func (c Config) CalloutsEnabled() bool {
+	if len(c.CalloutFees) != 0 {
+		return true
+	}
+	for _, override := range c.overrides() {
+		if len(override.CalloutFees) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// CalloutFee returns the fee paid per incident with the callout attribute attr on schedule
+func (c Config) CalloutFee(schedule string, attr timespan.OnCallAttribute) float64 {
+	if override, exists := c.Schedules[strings.ToLower(schedule)]; exists {
+		if fee, exists := override.CalloutFees[attr]; exists {
+			return fee
+		}
+	}
+	return c.CalloutFees[attr]
+}
+
+// CalculateCallouts returns the callout fees owed per attribute for the incidents on schedule
+func (c Config) CalculateCallouts(schedule string, incidents []timespan.AttributedIncident) Amounts {
+	amounts := Amounts{}
+	for _, incident := range incidents {
+		if fee := c.CalloutFee(schedule, incident.Attribute); fee != 0 {
+			amounts[incident.Attribute] += fee
+		}
+	}
+	return amounts
+}
+
+// AllowancesEnabled returns true if any daily allowances have been configured
+func (c Config) AllowancesEnabled() bool {
+	return len(c.AllowanceAttributes()) != 0
+}
+
+// AllowanceAttributes returns all attributes that have a daily allowance on any schedule or escalation level,
+// the built in ones first
+func (c Config) AllowanceAttributes() []timespan.OnCallAttribute {
+	seen := map[timespan.OnCallAttribute]bool{}
+	for attr := range c.Allowances {
+		seen[attr] = true
+	}
+	for _, override := range c.overrides() {
+		for attr := range override.Allowances {
+			seen[attr] = true
+		}
+	}
+	attrs := []timespan.OnCallAttribute{}
+	for attr := range seen {
+		attrs = append(attrs, attr)
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i] < attrs[j] })
+	return attrs
+}
+
+// overrides returns the rates overridden per schedule and per escalation level
+func (c Config) overrides() []ScheduleRates {
+	overrides := []ScheduleRates{}
+	for _, override := range c.Schedules {
+		overrides = append(overrides, override)
+	}
+	for _, override := range c.Levels {
+		overrides = append(overrides, override)
+	}
+	return overrides
+}
+
+// Allowance returns the daily allowance for attr on schedule
+func (c Config) Allowance(schedule string, attr timespan.OnCallAttribute) (Allowance, bool) {
+	if override, exists := c.Schedules[strings.ToLower(schedule)]; exists {
+		if allowance, exists := override.Allowances[attr]; exists {
+			return allowance, true
+		}
+	}
+	allowance, exists := c.Allowances[attr]
+	return allowance, exists
+}
+
+// CalculateAllowances returns the number of qualifying days and the allowance owed per calendar
+// month for each attribute for the attributed spans on schedule, before any cap is applied.
+// Days and months are counted in the timezone loc, the one the spans were attributed in
+func (c Config) CalculateAllowances(schedule string, spans timespan.AttributedSpans, loc *time.Location) (map[timespan.OnCallAttribute]int, MonthlyAmounts) {
+	if loc == nil {
+		loc = time.Local
+	}
+	days := map[timespan.OnCallAttribute]int{}
+	amounts := MonthlyAmounts{}
+	for _, attr := range c.AllowanceAttributes() {
+		allowance, exists := c.Allowance(schedule, attr)
+		if !exists {
+			continue
+		}
+		minDur := time.Duration(allowance.MinHours * float64(time.Hour))
+		for month, count := range spans.MonthlyDayCount(attr, minDur, loc) {
+			days[attr] += count
+			if amounts[month] == nil {
+				amounts[month] = Amounts{}
+			}
+			amounts[month][attr] = float64(count) * allowance.PerDay
+		}
+	}
+	return days, amounts
+}
+
+// CapAllowances scales down a single user's allowances across all their schedules so the
+// total for each calendar month doesn't exceed AllowanceCap, and returns the capped allowances
+// of each schedule for the whole period. A cap of 0 means no cap
+func (c Config) CapAllowances(allowances []MonthlyAmounts) []Amounts {
+	monthTotals := map[string]float64{}
+	for _, a := range allowances {
+		for month, amounts := range a {
+			monthTotals[month] += amounts.Total()
+		}
+	}
+	capped := make([]Amounts, len(allowances))
+	for i, a := range allowances {
+		capped[i] = Amounts{}
+		for month, amounts := range a {
+			ratio := 1.0
+			if c.AllowanceCap > 0 && monthTotals[month] > c.AllowanceCap {
+				ratio = c.AllowanceCap / monthTotals[month]
+			}
+			for attr, amount := range amounts {
+				capped[i][attr] += amount * ratio
+			}
+		}
+	}
+	return capped
+}
+
+// HourlyRate returns the hourly amount paid for time with the attribute attr on schedule
+func (c Config) HourlyRate(schedule string, attr timespan.OnCallAttribute) float64 {
+	baseRate := c.BaseRate
+	if override, exists := c.Schedules[strings.ToLower(schedule)]; exists {
+		if override.BaseRate != 0 {
+			baseRate = override.BaseRate
+		}
+		if rate, exists := override.Rates[attr]; exists {
+			return rate
+		}
+		if multiplier, exists := override.Multipliers[attr]; exists {
+			return baseRate * multiplier
+		}
+	}
+	if rate, exists := c.Rates[attr]; exists {
+		return rate
+	}
+	if multiplier, exists := c.Multipliers[attr]; exists {
+		return baseRate * multiplier
+	}
+	return 0
+}
+
+// ForLevel returns the config used for schedules on the escalation level, with the rates of
+// the highest configured level at or below level replacing the global rates. Schedule
+// overrides still take precedence. Unknown levels (0) are paid the global rates
+func (c Config) ForLevel(level int) Config {
+	best := 0
+	for l := range c.Levels {
+		if l <= level && l > best {
+			best = l
+		}
+	}
+	levelRates, exists := c.Levels[best]
+	if !exists {
+		return c
+	}
+	if levelRates.BaseRate != 0 {
+		c.BaseRate = levelRates.BaseRate
+	}
+	c.Rates = c.Rates.merge(levelRates.Rates)
+	c.Multipliers = c.Multipliers.merge(levelRates.Multipliers)
+	c.CalloutFees = c.CalloutFees.merge(levelRates.CalloutFees)
+	// A level paying an attribute as a multiplier mustn't be beaten by a global flat rate
+	for attr := range levelRates.Multipliers {
+		if _, exists := levelRates.Rates[attr]; !exists {
+			delete(c.Rates, attr)
+		}
+	}
+	allowances := Allowances{}
+	for attr, allowance := range c.Allowances {
+		allowances[attr] = allowance
+	}
+	for attr, allowance := range levelRates.Allowances {
+		allowances[attr] = allowance
+	}
+	c.Allowances = allowances
+	return c
+}
+
+// ScheduleHourlyRate returns the hourly amount paid for time with the attribute attr on the schedule
+// with the given ID and name, which is on call for the escalation level
+func (c Config) ScheduleHourlyRate(id, name string, level int, attr timespan.OnCallAttribute) float64 {
+	return c.ForLevel(level).HourlyRate(c.ScheduleKey(id, name), attr)
+}
+
+// PaysConcurrentLevels returns true if time a user is on call for more than one escalation
+// level at the same time is paid on every level
+func (c Config) PaysConcurrentLevels() bool {
+	return c.ConcurrentLevels != ConcurrentPayLowest
+}
+
+// merge returns a copy of r with the rates in o added or replaced
+func (r Rates) merge(o Rates) Rates {
+	merged := Rates{}
+	for attr, rate := range r {
+		merged[attr] = rate
+	}
+	for attr, rate := range o {
+		merged[attr] = rate
+	}
+	return merged
+}
+
+// Calculate returns the amount owed for each attribute for the attributed spans on schedule.
+// Time with stacked attributes is owed under the one paying the most
+func (c Config) Calculate(schedule string, spans timespan.AttributedSpans) Amounts {
+	amounts := Amounts{}
+	for _, span := range spans {
+		// Time with stacked attributes is paid at the highest rate of them
+		attr, rate := span.SpanType, c.HourlyRate(schedule, span.SpanType)
+		for _, stacked := range span.Stacked {
+			if stackedRate := c.HourlyRate(schedule, stacked); stackedRate > rate {
+				attr, rate = stacked, stackedRate
+			}
+		}
+		if rate == 0 {
+			continue
+		}
+		amounts[attr] += span.Duration().Hours() * rate
+	}
+	return amounts
+}
+
+// Total returns the sum of all amounts
+func (a Amounts) Total() float64 {
+	var total float64
+	for _, amount := range a {
+		total += amount
+	}
+	return total
+}
+
+// Add returns the sum of a and b per attribute
+func (a Amounts) Add(b Amounts) Amounts {
+	sum := Amounts{}
+	for attr, amount := range a {
+		sum[attr] += amount
+	}
+	for attr, amount := range b {
+		sum[attr] += amount
+	}
+	return sum
+}
+
+// Total returns the amounts of all months added together per attribute
+func (m MonthlyAmounts) Total() Amounts {
+	total := Amounts{}
+	for _, amounts := range m {
+		total = total.Add(amounts)
+	}
+	return total
+}
@@ -0,0 +1,194 @@
+package compensation
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+var testConfig = Config{
+	Currency: "NZD",
+	BaseRate: 10,
+	Rates: Rates{
+		timespan.Business: 0,
+	},
+	Multipliers: Rates{
+		timespan.AfterHours:  1,
+		timespan.Weekend:     1.5,
+		timespan.StatHoliday: 2,
+	},
+	Schedules: map[string]ScheduleRates{
+		"secondary": {
+			BaseRate: 5,
+			Rates: Rates{
+				timespan.StatHoliday: 12,
+			},
+		},
+	},
+}
+
+func TestHourlyRate(t *testing.T) {
+	tests := []struct {
+		schedule string
+		attr     timespan.OnCallAttribute
+		rate     float64
+	}{
+		{"Primary", timespan.Business, 0},
+		{"Primary", timespan.AfterHours, 10},
+		{"Primary", timespan.Weekend, 15},
+		{"Primary", timespan.StatHoliday, 20},
+		{"Primary", timespan.CompanyDay, 0},
+		{"Secondary", timespan.AfterHours, 5},
+		{"Secondary", timespan.Weekend, 7.5},
+		{"Secondary", timespan.StatHoliday, 12},
+	}
+	for _, test := range tests {
+		rate := testConfig.HourlyRate(test.schedule, test.attr)
+		if rate != test.rate {
+			t.Errorf("%s %s: expected rate %.2f, got %.2f", test.schedule, test.attr, test.rate, rate)
+		}
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	start := time.Date(2019, time.January, 4, 8, 0, 0, 0, time.UTC)
+	spans := timespan.AttributedSpans{
+		{Span: timespan.New(start, start.Add(9*time.Hour+30*time.Minute)), SpanType: timespan.Business},
+		{Span: timespan.New(start.Add(9*time.Hour+30*time.Minute), start.Add(64*time.Hour)), SpanType: timespan.Weekend},
+	}
+	amounts := testConfig.Calculate("Primary", spans)
+	if amounts[timespan.Business] != 0 {
+		t.Errorf("Expected no business hours amount, got %.2f", amounts[timespan.Business])
+	}
+	if math.Abs(amounts[timespan.Weekend]-817.5) > 0.001 {
+		t.Errorf("Expected weekend amount 817.50, got %.2f", amounts[timespan.Weekend])
+	}
+	if math.Abs(amounts.Total()-817.5) > 0.001 {
+		t.Errorf("Expected total 817.50, got %.2f", amounts.Total())
+	}
+}
+
+func TestCalculateStacked(t *testing.T) {
+	start := time.Date(2019, time.January, 5, 0, 0, 0, 0, time.UTC)
+	spans := timespan.AttributedSpans{
+		// A weekend stat holiday is paid at the stat holiday rate, whichever comes first
+		{Span: timespan.New(start, start.Add(10*time.Hour)), SpanType: timespan.Weekend, Stacked: []timespan.OnCallAttribute{timespan.StatHoliday}},
+		{Span: timespan.New(start.Add(10*time.Hour), start.Add(20*time.Hour)), SpanType: timespan.StatHoliday, Stacked: []timespan.OnCallAttribute{timespan.Weekend}},
+	}
+	amounts := testConfig.Calculate("Primary", spans)
+	if amounts[timespan.Weekend] != 0 || math.Abs(amounts[timespan.StatHoliday]-400) > 0.001 {
+		t.Errorf("Expected 400.00 stat holiday amount only, got %.2f weekend and %.2f stat holiday", amounts[timespan.Weekend], amounts[timespan.StatHoliday])
+	}
+}
+
+func TestCalculateAllowances(t *testing.T) {
+	comp := Config{
+		Allowances: Allowances{
+			timespan.AfterHours: {PerDay: 20, MinHours: 2},
+			timespan.Weekend:    {PerDay: 50},
+		},
+		AllowanceCap: 200,
+	}
+	// Thursday evening, then Friday evening until Monday morning
+	thursday := time.Date(2019, time.January, 3, 0, 0, 0, 0, time.UTC)
+	spans := timespan.AttributedSpans{
+		{Span: timespan.New(thursday.Add(17*time.Hour), thursday.Add(18*time.Hour)), SpanType: timespan.AfterHours},
+		{Span: timespan.New(thursday.Add(22*time.Hour), thursday.Add(32*time.Hour)), SpanType: timespan.AfterHours},
+		{Span: timespan.New(thursday.Add(41*time.Hour+30*time.Minute), thursday.Add(104*time.Hour)), SpanType: timespan.Weekend},
+	}
+	days, amounts := comp.CalculateAllowances("Primary", spans, time.UTC)
+	// Thursday has 3 hours after hours, Friday morning 8 hours
+	if days[timespan.AfterHours] != 2 {
+		t.Errorf("Expected 2 after hours allowance days, got %d", days[timespan.AfterHours])
+	}
+	// Friday evening, Saturday, Sunday and Monday morning
+	if days[timespan.Weekend] != 4 {
+		t.Errorf("Expected 4 weekend allowance days, got %d", days[timespan.Weekend])
+	}
+	if amounts.Total().Total() != 240 {
+		t.Errorf("Expected 240 in allowances before the cap, got %.2f", amounts.Total().Total())
+	}
+
+	capped := comp.CapAllowances([]MonthlyAmounts{amounts, {"2019-01": {timespan.Weekend: 60}}})
+	if math.Abs(capped[0].Total()+capped[1].Total()-200) > 0.001 {
+		t.Errorf("Expected allowances to be capped at 200, got %.2f", capped[0].Total()+capped[1].Total())
+	}
+}
+
+func TestCapAllowancesPerMonth(t *testing.T) {
+	nzt := time.FixedZone("NZT", 12*60*60)
+	comp := Config{
+		Allowances:   Allowances{timespan.Weekend: {PerDay: 50}},
+		AllowanceCap: 200,
+	}
+	// Saturday the 23rd of February until Sunday the 3rd of March in NZT, 6 days in February
+	// and 3 in March, even though the 1st of March starts on the 28th of February in UTC
+	start := time.Date(2019, time.February, 23, 0, 0, 0, 0, nzt)
+	spans := timespan.AttributedSpans{
+		{Span: timespan.New(start, start.AddDate(0, 0, 9)), SpanType: timespan.Weekend},
+	}
+	days, amounts := comp.CalculateAllowances("Primary", spans, nzt)
+	if days[timespan.Weekend] != 9 {
+		t.Errorf("Expected 9 weekend allowance days, got %d", days[timespan.Weekend])
+	}
+	if amounts["2019-02"][timespan.Weekend] != 300 || amounts["2019-03"][timespan.Weekend] != 150 {
+		t.Errorf("Expected 300 in February and 150 in March before the cap, got %v", amounts)
+	}
+
+	// A second schedule adds 100 in March, which puts March over the cap as well
+	capped := comp.CapAllowances([]MonthlyAmounts{amounts, {"2019-03": {timespan.Weekend: 100}}})
+	// February is capped at 200, March's 250 is capped at 200 and split 120/80 between the schedules
+	if math.Abs(capped[0].Total()-320) > 0.001 {
+		t.Errorf("Expected 320 in allowances on the first schedule, got %.2f", capped[0].Total())
+	}
+	if math.Abs(capped[1].Total()-80) > 0.001 {
+		t.Errorf("Expected 80 in allowances on the second schedule, got %.2f", capped[1].Total())
+	}
+
+	// A month under the cap is paid in full
+	uncapped := comp.CapAllowances([]MonthlyAmounts{{"2019-03": {timespan.Weekend: 150}}})
+	if uncapped[0].Total() != 150 {
+		t.Errorf("Expected 150 in allowances for March without a cap, got %.2f", uncapped[0].Total())
+	}
+}
+
+func TestForLevel(t *testing.T) {
+	comp := testConfig
+	comp.Levels = map[int]ScheduleRates{
+		2: {
+			BaseRate: 4,
+			Rates: Rates{
+				timespan.AfterHours: 3,
+			},
+			Multipliers: Rates{
+				timespan.Business: 0.5,
+			},
+		},
+	}
+	tests := []struct {
+		level    int
+		schedule string
+		attr     timespan.OnCallAttribute
+		rate     float64
+	}{
+		{0, "Primary", timespan.Weekend, 15},
+		{1, "Primary", timespan.AfterHours, 10},
+		{2, "Primary", timespan.AfterHours, 3},
+		{2, "Primary", timespan.Weekend, 6},
+		{2, "Primary", timespan.Business, 2},
+		{3, "Primary", timespan.Weekend, 6},
+		{2, "Secondary", timespan.Weekend, 7.5},
+		{2, "Secondary", timespan.StatHoliday, 12},
+	}
+	for _, test := range tests {
+		rate := comp.ForLevel(test.level).HourlyRate(test.schedule, test.attr)
+		if rate != test.rate {
+			t.Errorf("level %d %s %s: expected rate %.2f, got %.2f", test.level, test.schedule, test.attr, test.rate, rate)
+		}
+	}
+	if testConfig.Rates[timespan.Business] != 0 || len(testConfig.Multipliers) != 3 {
+		t.Errorf("Expected ForLevel to leave the global rates untouched")
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+// attributionConfig is the "attribution" section of the config file
+type attributionConfig struct {
+	Precedence []string `mapstructure:"precedence"`
+	Stacking   *bool    `mapstructure:"stacking"`
+}
+
+// parsePrecedence returns the attributes of known with the names in precedence. Business hours
+// can't be given a precedence, it's always whatever time no other attribute applies to
+func parsePrecedence(precedence []string, known *timespan.Attributes) ([]timespan.OnCallAttribute, error) {
+	attrs := []timespan.OnCallAttribute{}
+	seen := map[timespan.OnCallAttribute]bool{}
+	for _, name := range precedence {
+		attr, err := known.Parse(name)
+		if err != nil {
+			return nil, err
+		}
+		if attr == timespan.Business {
+			return nil, fmt.Errorf("%s is always attributed last and can't be given a precedence", name)
+		}
+		if seen[attr] {
+			return nil, fmt.Errorf("%s is listed more than once", name)
+		}
+		seen[attr] = true
+		attrs = append(attrs, attr)
+	}
+	return attrs, nil
+}
+
+// apply sets the precedence and stacking of sc to the configured ones
+func (ac attributionConfig) apply(sc *ScheduleConfig) error {
+	if ac.Precedence != nil {
+		precedence, err := parsePrecedence(ac.Precedence, sc.Attributes)
+		if err != nil {
+			return fmt.Errorf("failed to parse attribution precedence, err: %s", err.Error())
+		}
+		sc.Precedence = precedence
+	}
+	if ac.Stacking != nil {
+		sc.Stacking = *ac.Stacking
+	}
+	return nil
+}
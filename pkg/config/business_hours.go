@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// closedDay marks a weekday without business hours in the "business_hours.week" config
+const closedDay = "closed"
+
+// DayHours are the business hours of a single weekday in timeShortForm. The hours of a closed day are
+// the configured start and end
+type DayHours struct {
+	Start  string
+	End    string
+	Closed bool
+}
+
+// WeekTemplate returns the business hours of every weekday. By default the business is open from Start
+// until End Monday to Friday and closed on weekends. Week overrides single weekdays with either
+// "closed" or the opening hours, like "08:00-16:00"
+func (bh BusinessHoursStruct) WeekTemplate() (map[time.Weekday]DayHours, error) {
+	week := map[time.Weekday]DayHours{}
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		week[weekday] = DayHours{Start: bh.Start, End: bh.End, Closed: weekday == time.Saturday || weekday == time.Sunday}
+	}
+	for name, hours := range bh.Week {
+		weekday, err := parseWeekday(name)
+		if err != nil {
+			return nil, err
+		}
+		if strings.ToLower(strings.TrimSpace(hours)) == closedDay {
+			week[weekday] = DayHours{Start: bh.Start, End: bh.End, Closed: true}
+			continue
+		}
+		dayHours, err := parseDayHours(hours)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err.Error())
+		}
+		week[weekday] = dayHours
+	}
+	return week, nil
+}
+
+// On returns the opening and closing time of the business hours on the date of day in the timezone loc
+func (h DayHours) On(day time.Time, loc *time.Location) (time.Time, time.Time, error) {
+	refDate := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+	start, err := time.Parse(timeShortForm, h.Start)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("failed to parse business hour time, string: %s, layout: %s", h.Start, timeShortForm)
+	}
+	end, err := time.Parse(timeShortForm, h.End)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("failed to parse business hour time, string: %s, layout: %s", h.End, timeShortForm)
+	}
+	opening := refDate.Add((time.Hour * time.Duration(start.Hour())) + (time.Minute * time.Duration(start.Minute())))
+	closing := refDate.Add((time.Hour * time.Duration(end.Hour())) + (time.Minute * time.Duration(end.Minute())))
+	return opening, closing, nil
+}
+
+// parseDayHours parses opening hours like "08:00-16:00"
+func parseDayHours(hours string) (DayHours, error) {
+	parts := strings.Split(hours, "-")
+	if len(parts) != 2 {
+		return DayHours{}, fmt.Errorf("expected %q or opening hours like \"08:00-16:00\", got %q", closedDay, hours)
+	}
+	dayHours := DayHours{Start: strings.TrimSpace(parts[0]), End: strings.TrimSpace(parts[1])}
+	start, err := time.Parse(timeShortForm, dayHours.Start)
+	if err != nil {
+		return DayHours{}, err
+	}
+	end, err := time.Parse(timeShortForm, dayHours.End)
+	if err != nil {
+		return DayHours{}, err
+	}
+	if !end.After(start) {
+		return DayHours{}, fmt.Errorf("closing time %s isn't after opening time %s", dayHours.End, dayHours.Start)
+	}
+	return dayHours, nil
+}
+
+// parseWeekday returns the weekday with the case insensitive name
+func parseWeekday(name string) (time.Weekday, error) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.EqualFold(weekday.String(), name) {
+			return weekday, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %q", name)
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"reflect"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// companyDayDecodeHook decodes plain strings in the "company_days" config as a CompanyDay with just a date,
+// on top of viper's own decode hooks
+var companyDayDecodeHook = mapstructure.ComposeDecodeHookFunc(
+	mapstructure.StringToTimeDurationHookFunc(),
+	mapstructure.StringToSliceHookFunc(","),
+	func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		if from.Kind() != reflect.String || to != reflect.TypeOf(CompanyDay{}) {
+			return data, nil
+		}
+		return CompanyDay{Date: data.(string)}, nil
+	},
+)
+
+// splitCompanyDays returns the dates of the company days that are just a date,
+// and the company days with a name or start and end times
+func splitCompanyDays(days []CompanyDay) ([]string, []CompanyDay) {
+	var plain []string
+	var named []CompanyDay
+	for _, day := range days {
+		if day.Name == "" && day.Start == "" && day.End == "" {
+			plain = append(plain, day.Date)
+		} else {
+			named = append(named, day)
+		}
+	}
+	return plain, named
+}
+
+// CompanyDayEntries returns all company days of the schedule, including the ones that are just a date
+func (sc ScheduleConfig) CompanyDayEntries() []CompanyDay {
+	days := []CompanyDay{}
+	for _, date := range sc.CompanyDays {
+		days = append(days, CompanyDay{Date: date})
+	}
+	return append(days, sc.NamedCompanyDays...)
+}
@@ -3,9 +3,12 @@ package config
 import (
 	"fmt"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/leosunmo/pagertally/pkg/compensation"
 	"github.com/leosunmo/pagertally/pkg/outputs"
 	"github.com/leosunmo/pagertally/pkg/timespan"
 	log "github.com/sirupsen/logrus"
@@ -15,8 +18,8 @@ import (
 
 const timeShortForm = "15:04"
 
-// CompanyDayDateFormat is the date format we expect in the "company_days" config array
-// TODO: support wildcard/recurring company days.
+// CompanyDayDateFormat is the date format we expect in the "company_days" config array.
+// Recurring company days leave out the year, see CompanyDay
 const CompanyDayDateFormat = "02/01/2006"
 
 // GlobalConfig is the currently active configuration
@@ -28,22 +31,90 @@ type SecretString string
 
 // Config is the application config
 type ScheduleConfig struct {
-	Holidays       []string            `json:"holidays,omitempty"`
-	BusinessHours  BusinessHoursStruct `json:"business_hours"`
-	CalendarURL    string              `json:"ical_url"`
-	Timezone       string              `json:"timezone"`
-	CompanyDays    []string            `json:"company_days,omitempty"`
-	CsvDir         string
-	ScheduleSpan   timespan.Span
-	ParsedTimezone *time.Location
-	Debug          bool
-	RoundShiftsUp  bool                `json:"round_shifts_up"`
+	Holidays      []string            `json:"holidays,omitempty"`
+	BusinessHours BusinessHoursStruct `json:"business_hours"`
+	CalendarURL   string              `json:"ical_url"`
+	// CalendarURLs are more iCal URLs or files merged with CalendarURL
+	CalendarURLs []string `json:"ical_urls,omitempty"`
+	// HolidayRules are the names of the bundled holiday rule sets stat holidays are generated from
+	HolidayRules []string `json:"holiday_rules,omitempty"`
+	// HolidayDates are stat holidays configured inline rather than in an iCal
+	HolidayDates []HolidayDate `json:"holiday_dates,omitempty"`
+	Timezone     string        `json:"timezone"`
+	CompanyDays  []string      `json:"company_days,omitempty"`
+	// NamedCompanyDays are the company days in "company_days" with a name or start and end times
+	NamedCompanyDays []CompanyDay `json:"named_company_days,omitempty"`
+	CsvDir           string
+	ScheduleSpan     timespan.Span
+	ParsedTimezone   *time.Location
+	Debug            bool
+	RoundShiftsUp    bool                `json:"round_shifts_up"`
+	Compensation     compensation.Config `json:"compensation,omitempty"`
+	// ForceTimezone attributes everyone's time in Timezone instead of their own PagerDuty timezone
+	ForceTimezone bool `json:"force_timezone"`
+	// UserTimezones maps lower case PagerDuty user IDs or emails to the timezone their time is attributed in
+	UserTimezones map[string]*time.Location `json:"user_timezones,omitempty"`
+	// TimeBands are the user-defined on-call attributes, in order of precedence
+	TimeBands []TimeBand `json:"time_bands,omitempty"`
+	// Attributes are where the attributes of the time bands are registered, shared with the config's profiles.
+	// Only the built in attributes are known without it
+	Attributes *timespan.Attributes `json:"-"`
+	// Precedence is the order time is attributed in. Attributes left out follow in their default order
+	Precedence []timespan.OnCallAttribute `json:"precedence,omitempty"`
+	// Stacking attributes time with every attribute that applies to it rather than only the first,
+	// and it's paid at the highest rate of them
+	Stacking bool `json:"stacking,omitempty"`
+	// DataSources are the datasources in the "datasources" config, attributing time on top of the built in ones
+	DataSources []DataSourceConfig `json:"datasources,omitempty"`
+}
+
+// compensationConfig is the "compensation" section of the config file
+type compensationConfig struct {
+	scheduleRatesConfig `mapstructure:",squash"`
+	Currency            string                         `mapstructure:"currency"`
+	AllowanceCap        float64                        `mapstructure:"allowance_cap"`
+	Schedules           map[string]scheduleRatesConfig `mapstructure:"schedules"`
+	EscalationLevels    map[string]scheduleRatesConfig `mapstructure:"escalation_levels"`
+	ConcurrentLevels    string                         `mapstructure:"concurrent_levels"`
+}
+
+// scheduleRatesConfig is a set of rates with attributes referred to by name
+type scheduleRatesConfig struct {
+	BaseRate    float64                    `mapstructure:"base_rate"`
+	Rates       map[string]float64         `mapstructure:"rates"`
+	Multipliers map[string]float64         `mapstructure:"multipliers"`
+	Allowances  map[string]allowanceConfig `mapstructure:"allowances"`
+	CalloutFees map[string]float64         `mapstructure:"callout_fees"`
+}
+
+// allowanceConfig is a daily allowance for a single attribute
+type allowanceConfig struct {
+	PerDay   float64 `mapstructure:"per_day"`
+	MinHours float64 `mapstructure:"min_hours"`
+}
+
+// HolidayDate is a single stat holiday in the "holiday_dates" config, with Date in CompanyDayDateFormat
+type HolidayDate struct {
+	Name string `json:"name" mapstructure:"name"`
+	Date string `json:"date" mapstructure:"date"`
+}
+
+// CompanyDay is a single entry in the "company_days" config. Date is a date in CompanyDayDateFormat,
+// a range of dates or a recurrence expression such as "every 24/12" or "last Friday of each quarter".
+// Start and End limit the company day to part of the day, in timeShortForm
+type CompanyDay struct {
+	Name  string `json:"name,omitempty" mapstructure:"name"`
+	Date  string `json:"date" mapstructure:"date"`
+	Start string `json:"start,omitempty" mapstructure:"start"`
+	End   string `json:"end,omitempty" mapstructure:"end"`
 }
 
 // BusinessHoursStruct is a struct of string representations of business hours start and end
 type BusinessHoursStruct struct {
 	Start string `json:"start"`
 	End   string `json:"end"`
+	// Week overrides the hours of single weekdays by lower case weekday name, see WeekTemplate
+	Week map[string]string `json:"week,omitempty"`
 }
 
 // BuildConfig parses command-line flags, config file and environment variables and builds up the application configuration
@@ -51,12 +122,31 @@ func BuildConfig() {
 	var pdToken SecretString
 	// First look up flags
 	flag.StringSliceP("schedules", "s", nil, "Comma separated list of PagerDuty schedule IDs")
+	flag.StringSlice("team", nil, "(Optional) Comma separated list of PagerDuty team IDs or names to process all schedules of")
+	flag.StringSlice("escalation-policy", nil, "(Optional) Comma separated list of PagerDuty escalation policy IDs or names to process all schedules of")
+	flag.String("schedule-match", "", "(Optional) Process schedules with names matching this regular expression, only from the selected teams and escalation policies if any")
 	flag.StringP("config", "c", "", "(Optional) Provide config file path. Looks for \"config.yaml\" by default")
 	flag.String("csvdir", "", "(Optional) Print as CSVs to this directory")
 	flag.String("gsheetid", "", "(Optional) Print to Google Sheet ID provided")
+	flag.Bool("details", false, "(Optional) Also print every shift with its attributed spans to the terminal")
+	flag.String("json", "", "(Optional) Print the full report as JSON to this file, or - for stdout")
+	flag.String("yaml", "", "(Optional) Print the full report as YAML to this file, or - for stdout")
+	flag.String("html", "", "(Optional) Print the report as a self-contained HTML page to this file")
+	flag.String("xlsx", "", "(Optional) Print the report as an Excel workbook to this file")
 	flag.String("google-safile", "", "(Optional) Google Service Account token JSON file")
 	flag.VarP(&pdToken, "pagerduty-token", "t", "PagerDuty API token")
 	flag.StringP("month", "m", "", "(Optional) Provide the month and year you want to process. Format: March 2018. Default: previous month")
+	flag.String("week", "", "(Optional) Process an ISO week. Format: 2018-W10")
+	flag.String("quarter", "", "(Optional) Process a quarter. Format: 2018-Q1")
+	flag.String("since", "", "(Optional) Process from this date, inclusive. Format: 2018-03-01")
+	flag.String("until", "", "(Optional) Process until this date, inclusive. Format: 2018-03-14. Default: today")
+	flag.String("period", "", "(Optional) Process a named period, e.g. previous-week, previous-fortnight, current-quarter, year-to-date. With pay periods configured: current, previous or N periods ago")
+	flag.Bool("incidents", false, "(Optional) Also fetch incidents and report callouts and time engaged per user")
+	flag.String("user-column", outputs.UserColumnName, "(Optional) Identify users by name, email or employee_number. Employee numbers are mapped from \"employee_numbers\"")
+	flag.String("overlap-mode", "sum", "(Optional) How to count time a user is on call for more than one schedule at once: sum, union or highest. All overlaps are reported")
+	flag.String("pay-period", "", "(Optional) Name of the pay period in \"pay_periods\" to use for relative periods. Default: previous pay period")
+	flag.String("user", "", "(explain) PagerDuty user ID, email or name to explain the on-call time of")
+	flag.String("at", "", "(explain) Time to explain, in the schedule timezone. Format: 2018-03-06 18:00. Default reporting period: the month of this time")
 	printHelp := flag.BoolP("help", "h", false, "Print usage")
 
 	// Parse flags
@@ -70,6 +160,12 @@ func BuildConfig() {
 	viper.RegisterAlias("sheet-id", "gsheetid")
 	viper.RegisterAlias("start-month", "month")
 	viper.RegisterAlias("pagerduty-schedules", "schedules")
+	viper.RegisterAlias("pay_period", "pay-period")
+	viper.RegisterAlias("user_column", "user-column")
+	viper.RegisterAlias("teams", "team")
+	viper.RegisterAlias("escalation_policies", "escalation-policy")
+	viper.RegisterAlias("schedule_match", "schedule-match")
+	viper.RegisterAlias("overlap_mode", "overlap-mode")
 
 	// Bind the resulting flags to Viper values
 	viper.BindPFlags(flag.CommandLine)
@@ -113,8 +209,22 @@ func BuildConfig() {
 		// Should this be a fatal? Technically you could provide everything through other means
 		log.Warn("no config file found")
 	}
+	configFileRead := err == nil
 	log.Debug("Using config file: ", viper.ConfigFileUsed())
 
+	// The "schedules" config can be a map of schedule IDs to per schedule profiles rather than a list of IDs
+	profiles := map[string]scheduleProfileConfig{}
+	if configFileRead {
+		profiles, err = readScheduleProfiles(viper.ConfigFileUsed())
+		if err != nil {
+			log.Fatalf("Failed to parse schedules config, err: %s", err.Error())
+		}
+		if _, isMap := viper.Get("schedules").(map[string]interface{}); isMap {
+			// No schedules provided through flags or ENVVARs, use the ones in the map
+			viper.Set("schedules", profileScheduleIDs(profiles))
+		}
+	}
+
 	// Set defaults
 	viper.SetDefault("timezone", time.Local.String())
 	viper.SetDefault("business_hours.start", "09:00")
@@ -127,29 +237,70 @@ func BuildConfig() {
 		log.Fatalf("Failed to parse timezone. use IANA TZ format, err: %s", err.Error())
 	}
 
-	// If start-month is not set, default to previous month
-	if !viper.IsSet("start-month") || viper.GetString("start-month") == "" {
-		viper.Set("start-month", fmt.Sprintf("%s %d", time.Now().AddDate(0, -1, 0).Month(), time.Now().AddDate(0, -1, 0).Year()))
+	payPeriods := map[string]PayPeriod{}
+	err = viper.UnmarshalKey("pay_periods", &payPeriods)
+	if err != nil {
+		log.Fatalf("Failed to parse pay_periods, err: %s", err.Error())
+	}
+
+	// The time to explain defaults the reporting period to its month
+	month := viper.GetString("start-month")
+	var explainAt time.Time
+	if viper.GetString("at") != "" {
+		explainAt, err = parseExplainTime(viper.GetString("at"), loc)
+		if err != nil {
+			log.Fatalf("Failed to parse --at, err: %s", err.Error())
+		}
+		if month == "" && viper.GetString("week") == "" && viper.GetString("quarter") == "" && viper.GetString("since") == "" && viper.GetString("until") == "" && viper.GetString("period") == "" {
+			month = explainAt.Format(MonthFormat)
+		}
 	}
 
-	// Create a time.Time from the start-month
-	startDate, err := time.ParseInLocation("January 2006", viper.GetString("start-month"), loc)
-	endDate := startDate.AddDate(0, +1, 0)
+	// Work out the reporting period, defaults to previous pay period or previous month
+	period, err := ResolvePeriod(PeriodOptions{
+		Month:      month,
+		Week:       viper.GetString("week"),
+		Quarter:    viper.GetString("quarter"),
+		Since:      viper.GetString("since"),
+		Until:      viper.GetString("until"),
+		Period:     viper.GetString("period"),
+		PayPeriod:  viper.GetString("pay-period"),
+		PayPeriods: payPeriods,
+	}, time.Now(), loc)
+	if err != nil {
+		log.Fatalf("Failed to work out reporting period, err: %s", err.Error())
+	}
+	startDate := period.Start()
+	endDate := period.End()
+	log.Infof("Reporting period %s (%s - %s)", period.Name, startDate, endDate)
 
 	// Let's add start and end dates to viper as well for convenience
 	viper.Set("start_date", startDate)
 	viper.Set("end_date", endDate)
+	viper.Set("period_name", period.Name)
+	viper.Set("explain_at", explainAt)
 
 	// fail on mandatory config
 	if !viper.IsSet("pagerduty-token") || string(viper.Get("pagerduty-token").(SecretString)) == "" {
 		log.Fatal("PagerDuty access token not provided. Use 'PDS_PAGERDUTY_TOKEN' or flag '--pagerduty-token' / '-t'")
 	}
-	if !viper.IsSet("schedules") || len(viper.GetStringSlice("schedules")) == 0 {
-		log.Fatal("PagerDuty schedules not specified. Use comma separated list in envvar 'PDS_PAGERDUTY_SCHEDULES' or flag '--schedules'")
+	discovering := len(viper.GetStringSlice("team")) != 0 || len(viper.GetStringSlice("escalation-policy")) != 0 || viper.GetString("schedule-match") != ""
+	if !discovering && (!viper.IsSet("schedules") || len(viper.GetStringSlice("schedules")) == 0) {
+		log.Fatal("PagerDuty schedules not specified. Use comma separated list in envvar 'PDS_PAGERDUTY_SCHEDULES' or flag '--schedules', or select them with '--team', '--escalation-policy' or '--schedule-match'")
 	}
 
 	// Kind of a hack because of https://github.com/spf13/viper/issues/380
 	viper.Set("schedules", commaSeparatedStringToSlice(viper.GetStringSlice("schedules")))
+	viper.Set("team", commaSeparatedStringToSlice(viper.GetStringSlice("team")))
+	viper.Set("escalation-policy", commaSeparatedStringToSlice(viper.GetStringSlice("escalation-policy")))
+
+	if viper.GetString("schedule-match") != "" {
+		match, rerr := regexp.Compile(viper.GetString("schedule-match"))
+		if rerr != nil {
+			log.Fatalf("Failed to parse schedule-match regular expression, err: %s", rerr.Error())
+		}
+		viper.Set("schedule_match_regexp", match)
+	}
 
 	if viper.IsSet("gsheetid") {
 		if !viper.IsSet("google-safile") {
@@ -157,57 +308,263 @@ func BuildConfig() {
 		}
 	}
 
+	switch viper.GetString("user-column") {
+	case outputs.UserColumnName, outputs.UserColumnEmail, outputs.UserColumnEmployeeNumber:
+	default:
+		log.Fatalf("Unknown user column %q, use %s, %s or %s", viper.GetString("user-column"), outputs.UserColumnName, outputs.UserColumnEmail, outputs.UserColumnEmployeeNumber)
+	}
+
 	viper.Set("parsed_timezone", loc)
 
+	// Time bands have to be registered before their names can be used in the compensation config
+	attrs := timespan.NewAttributes()
+	timeBands, err := readTimeBands(attrs)
+	if err != nil {
+		log.Fatalf("Failed to parse time_bands, err: %s", err.Error())
+	}
+
+	dataSources, err := readDataSources(attrs)
+	if err != nil {
+		log.Fatalf("Failed to parse datasources, err: %s", err.Error())
+	}
+
+	comp, err := readCompensation(attrs)
+	if err != nil {
+		log.Fatalf("Failed to parse compensation config, err: %s", err.Error())
+	}
+
+	var holidayDates []HolidayDate
+	err = viper.UnmarshalKey("holiday_dates", &holidayDates)
+	if err != nil {
+		log.Fatalf("Failed to parse holiday_dates, err: %s", err.Error())
+	}
+
+	var companyDays []CompanyDay
+	err = viper.UnmarshalKey("company_days", &companyDays, viper.DecodeHook(companyDayDecodeHook))
+	if err != nil {
+		log.Fatalf("Failed to parse company_days, err: %s", err.Error())
+	}
+	plainCompanyDays, namedCompanyDays := splitCompanyDays(companyDays)
+
+	userTimezones, err := readUserTimezones(loc)
+	if err != nil {
+		log.Fatalf("Failed to parse user_timezones, use IANA TZ format, err: %s", err.Error())
+	}
+
 	GlobalConfig = ScheduleConfig{
 		Holidays: viper.GetStringSlice("holidays"),
 		BusinessHours: BusinessHoursStruct{
 			Start: viper.GetString("business_hours.start"),
 			End:   viper.GetString("business_hours.end"),
+			Week:  viper.GetStringMapString("business_hours.week"),
 		},
-		CalendarURL:    viper.GetString("ical_url"),
-		Timezone:       viper.GetString("timezone"),
-		CompanyDays:    viper.GetStringSlice("company_days"),
-		ParsedTimezone: viper.Get("parsed_timezone").(*time.Location),
-		ScheduleSpan:   timespan.New(viper.GetTime("start_date"), viper.GetTime("end_date")),
-		Debug:          viper.GetBool("debug"),
+		CalendarURL:      viper.GetString("ical_url"),
+		CalendarURLs:     viper.GetStringSlice("ical_urls"),
+		HolidayRules:     viper.GetStringSlice("holiday_rules"),
+		HolidayDates:     holidayDates,
+		Timezone:         viper.GetString("timezone"),
+		CompanyDays:      plainCompanyDays,
+		NamedCompanyDays: namedCompanyDays,
+		ParsedTimezone:   viper.Get("parsed_timezone").(*time.Location),
+		ScheduleSpan:     timespan.New(viper.GetTime("start_date"), viper.GetTime("end_date")),
+		Debug:            viper.GetBool("debug"),
+		Compensation:     comp,
+		ForceTimezone:    viper.GetBool("force_timezone"),
+		UserTimezones:    userTimezones,
+		TimeBands:        timeBands,
+		Attributes:       attrs,
+		DataSources:      dataSources,
+	}
+
+	var attribution attributionConfig
+	if err = viper.UnmarshalKey("attribution", &attribution); err != nil {
+		log.Fatalf("Failed to parse attribution config, err: %s", err.Error())
+	}
+	if err = attribution.apply(&GlobalConfig); err != nil {
+		log.Fatalf("%s", err.Error())
+	}
+
+	if err = GlobalConfig.Validate(); err != nil {
+		log.Fatalf("Invalid config, err: %s", err.Error())
+	}
+
+	ScheduleConfigs, err = buildScheduleConfigs(GlobalConfig, profiles, &GlobalConfig.Compensation)
+	if err != nil {
+		log.Fatalf("Failed to parse schedules config, err: %s", err.Error())
 	}
 
 	log.Debug(fmt.Sprintf("Viper Configuration: %+v", viper.AllSettings()))
 }
 
-// BusinessHoursForDate returns the business hours start and end timestamp
-// by taking the provided day's date combined with the configured tz
-func BusinessHoursForDate(day time.Time) (startTime time.Time, endTime time.Time) {
+// readUserTimezones reads the "user_timezones" config section, sharing a single Location per timezone
+func readUserTimezones(loc *time.Location) (map[string]*time.Location, error) {
+	locations := map[string]*time.Location{loc.String(): loc}
+	userTimezones := map[string]*time.Location{}
+	for user, timezone := range viper.GetStringMapString("user_timezones") {
+		if _, exists := locations[timezone]; !exists {
+			userLoc, err := time.LoadLocation(timezone)
+			if err != nil {
+				return nil, err
+			}
+			locations[timezone] = userLoc
+		}
+		userTimezones[strings.ToLower(user)] = locations[timezone]
+	}
+	return userTimezones, nil
+}
+
+// readCompensation reads the "compensation" config section and converts attribute names to OnCallAttributes
+func readCompensation(attrs *timespan.Attributes) (compensation.Config, error) {
+	var raw compensationConfig
+	err := viper.UnmarshalKey("compensation", &raw)
+	if err != nil {
+		return compensation.Config{}, err
+	}
+	global, err := raw.scheduleRatesConfig.toScheduleRates(attrs)
+	if err != nil {
+		return compensation.Config{}, err
+	}
+	comp := compensation.Config{
+		Currency:     raw.Currency,
+		BaseRate:     global.BaseRate,
+		Rates:        global.Rates,
+		Multipliers:  global.Multipliers,
+		Allowances:   global.Allowances,
+		AllowanceCap: raw.AllowanceCap,
+		CalloutFees:  global.CalloutFees,
+		Schedules:    map[string]compensation.ScheduleRates{},
+		Levels:       map[int]compensation.ScheduleRates{},
+	}
+	switch raw.ConcurrentLevels {
+	case "":
+		comp.ConcurrentLevels = compensation.ConcurrentPayAll
+	case compensation.ConcurrentPayAll, compensation.ConcurrentPayLowest:
+		comp.ConcurrentLevels = raw.ConcurrentLevels
+	default:
+		return compensation.Config{}, fmt.Errorf("concurrent_levels must be %q or %q, got %q", compensation.ConcurrentPayAll, compensation.ConcurrentPayLowest, raw.ConcurrentLevels)
+	}
+	for schedule, rawRates := range raw.Schedules {
+		rates, rerr := rawRates.toScheduleRates(attrs)
+		if rerr != nil {
+			return compensation.Config{}, fmt.Errorf("schedule %s: %s", schedule, rerr.Error())
+		}
+		comp.Schedules[strings.ToLower(schedule)] = rates
+	}
+	for rawLevel, rawRates := range raw.EscalationLevels {
+		level, lerr := strconv.Atoi(rawLevel)
+		if lerr != nil || level < 1 {
+			return compensation.Config{}, fmt.Errorf("escalation level %q must be a number from 1", rawLevel)
+		}
+		rates, rerr := rawRates.toScheduleRates(attrs)
+		if rerr != nil {
+			return compensation.Config{}, fmt.Errorf("escalation level %d: %s", level, rerr.Error())
+		}
+		comp.Levels[level] = rates
+	}
+	return comp, nil
+}
+
+func (sr scheduleRatesConfig) toScheduleRates(attrs *timespan.Attributes) (compensation.ScheduleRates, error) {
 	var err error
-	var start, end time.Time
-	refDate := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, Timezone())
-	startTime, err = time.ParseInLocation(timeShortForm, GlobalConfig.BusinessHours.Start, refDate.Location())
+	rates := compensation.ScheduleRates{BaseRate: sr.BaseRate}
+	rates.Rates, err = attributeRates(sr.Rates, attrs)
+	if err != nil {
+		return rates, err
+	}
+	rates.Multipliers, err = attributeRates(sr.Multipliers, attrs)
 	if err != nil {
-		log.Fatalf("failed to parse business hour time, string: %s, layout: %s", GlobalConfig.BusinessHours.Start, timeShortForm)
+		return rates, err
 	}
-	endTime, err = time.ParseInLocation(timeShortForm, GlobalConfig.BusinessHours.End, refDate.Location())
+	rates.CalloutFees, err = attributeRates(sr.CalloutFees, attrs)
 	if err != nil {
-		log.Fatalf("failed to parse business hour time, string: %s, layout: %s", GlobalConfig.BusinessHours.End, timeShortForm)
+		return rates, err
+	}
+	rates.Allowances = compensation.Allowances{}
+	for name, allowance := range sr.Allowances {
+		attr, aerr := attrs.Parse(name)
+		if aerr != nil {
+			return rates, aerr
+		}
+		rates.Allowances[attr] = compensation.Allowance{
+			PerDay:   allowance.PerDay,
+			MinHours: allowance.MinHours,
+		}
+	}
+	return rates, nil
+}
+
+// attributeRates converts a map of attribute names to a map of the OnCallAttributes in attrs
+func attributeRates(named map[string]float64, attrs *timespan.Attributes) (compensation.Rates, error) {
+	rates := compensation.Rates{}
+	for name, rate := range named {
+		attr, err := attrs.Parse(name)
+		if err != nil {
+			return nil, err
+		}
+		rates[attr] = rate
+	}
+	return rates, nil
+}
+
+// CalendarSources returns all iCal URLs and files of the schedule
+func (sc ScheduleConfig) CalendarSources() []string {
+	sources := []string{}
+	if sc.CalendarURL != "" {
+		sources = append(sources, sc.CalendarURL)
 	}
-	start = refDate.Add((time.Hour * time.Duration(startTime.Hour())) + (time.Minute * time.Duration(startTime.Minute())))
-	end = refDate.Add((time.Hour * time.Duration(endTime.Hour())) + (time.Minute * time.Duration(endTime.Minute())))
-	return start, end
+	return append(sources, sc.CalendarURLs...)
 }
 
 // Timezone returns the configured local timezone
 func Timezone() *time.Location {
-	if GlobalConfig.ParsedTimezone != nil {
-		return GlobalConfig.ParsedTimezone
-	}
-	loc, err := time.LoadLocation(GlobalConfig.Timezone)
+	loc, err := GlobalConfig.Location()
 	if err != nil {
-		log.Fatal("config/config.go failed to parse timezone")
+		log.Fatal(err.Error())
 	}
-	GlobalConfig.ParsedTimezone = loc
 	return loc
 }
 
+// Location returns the schedule's local timezone, or an error if it can't be loaded
+func (sc *ScheduleConfig) Location() (*time.Location, error) {
+	if sc.ParsedTimezone != nil {
+		return sc.ParsedTimezone, nil
+	}
+	loc, err := time.LoadLocation(sc.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timezone, use IANA TZ format, err: %s", err.Error())
+	}
+	sc.ParsedTimezone = loc
+	return loc, nil
+}
+
+// Validate returns an error if the timezone, business hours, time bands or datasources of the schedule
+// config can't be parsed, and loads its timezone
+func (sc *ScheduleConfig) Validate() error {
+	if _, err := sc.Location(); err != nil {
+		return err
+	}
+	week, err := sc.BusinessHours.WeekTemplate()
+	if err != nil {
+		return fmt.Errorf("failed to parse business hours, err: %s", err.Error())
+	}
+	for weekday, hours := range week {
+		if _, _, err = hours.On(time.Time{}, time.UTC); err != nil {
+			return fmt.Errorf("failed to parse business hours on %s, err: %s", weekday, err.Error())
+		}
+	}
+	for _, band := range sc.TimeBands {
+		if err = band.Validate(); err != nil {
+			return fmt.Errorf("failed to parse time band %s, err: %s", band.Name, err.Error())
+		}
+	}
+	// The entries are copied before their attributes are parsed, as configs may be shared between tallies
+	sc.DataSources = append([]DataSourceConfig(nil), sc.DataSources...)
+	if err = parseDataSources(sc.DataSources, sc.Attributes); err != nil {
+		return fmt.Errorf("failed to parse datasources, err: %s", err.Error())
+	}
+	return nil
+}
+
 // SelectedOutputs returns a list of all configured outputs
 func SelectedOutputs() []outputs.Outputter {
 	var o []outputs.Outputter
@@ -218,8 +575,20 @@ func SelectedOutputs() []outputs.Outputter {
 	if viper.IsSet("gsheetid") && viper.GetString("gsheetid") != "" {
 		o = append(o, outputs.NewGSheetOutputter(viper.GetString("gsheetid"), viper.GetString("google-safile")))
 	}
-	if len(o) < 1 {
-		o = append(o, outputs.NewStdoutOutputter(false))
+	if viper.GetString("json") != "" {
+		o = append(o, outputs.NewJSONOutputter(viper.GetString("json")))
+	}
+	if viper.GetString("yaml") != "" {
+		o = append(o, outputs.NewYAMLOutputter(viper.GetString("yaml")))
+	}
+	if viper.GetString("html") != "" {
+		o = append(o, outputs.NewHTMLOutputter(viper.GetString("html")))
+	}
+	if viper.GetString("xlsx") != "" {
+		o = append(o, outputs.NewXLSXOutputter(viper.GetString("xlsx")))
+	}
+	if len(o) < 1 || viper.GetBool("details") {
+		o = append(o, outputs.NewStdoutOutputter(viper.GetBool("details")))
 	}
 	return o
 }
@@ -229,6 +598,22 @@ func Schedules() []string {
 	return viper.GetStringSlice("schedules")
 }
 
+// Teams returns the PagerDuty team IDs or names to process all schedules of
+func Teams() []string {
+	return viper.GetStringSlice("team")
+}
+
+// EscalationPolicies returns the PagerDuty escalation policy IDs or names to process all schedules of
+func EscalationPolicies() []string {
+	return viper.GetStringSlice("escalation-policy")
+}
+
+// ScheduleMatch returns the regular expression schedule names should match, nil if not configured
+func ScheduleMatch() *regexp.Regexp {
+	match, _ := viper.Get("schedule_match_regexp").(*regexp.Regexp)
+	return match
+}
+
 // StartDate returns the configured startdate
 func StartDate() time.Time {
 	return viper.GetTime("start_date")
@@ -239,6 +624,52 @@ func EndDate() time.Time {
 	return viper.GetTime("end_date")
 }
 
+// PeriodName returns the human readable name of the reporting period, e.g. "March 2018"
+func PeriodName() string {
+	return viper.GetString("period_name")
+}
+
+// ExplainUser returns the PagerDuty user ID, email or name to explain the on-call time of
+func ExplainUser() string {
+	return viper.GetString("user")
+}
+
+// ExplainAt returns the time to explain, the zero time if none was given
+func ExplainAt() time.Time {
+	return viper.GetTime("explain_at")
+}
+
+// explainTimeFormats are the accepted formats of the time to explain
+var explainTimeFormats = []string{"2006-01-02 15:04", "2006-01-02 15:04:05", time.RFC3339}
+
+// parseExplainTime parses the time to explain in loc, unless it has its own offset
+func parseExplainTime(value string, loc *time.Location) (time.Time, error) {
+	for _, format := range explainTimeFormats {
+		if at, err := time.ParseInLocation(format, value, loc); err == nil {
+			return at, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected a time like \"2018-03-06 18:00\", got %q", value)
+}
+
+// Incidents returns true if incidents should be fetched and reported
+func Incidents() bool {
+	return viper.GetBool("incidents")
+}
+
+// OverlapMode returns how time a user is on call for more than one schedule at once is counted
+func OverlapMode() string {
+	return viper.GetString("overlap-mode")
+}
+
+// UserLabels returns how users should be identified in outputs
+func UserLabels() outputs.UserLabels {
+	return outputs.UserLabels{
+		Column:          viper.GetString("user-column"),
+		EmployeeNumbers: viper.GetStringMapString("employee_numbers"),
+	}
+}
+
 // PDToken returns the configured PagerDuty API token
 func PDToken() string {
 	return string(viper.Get("pagerduty-token").(SecretString))
@@ -250,9 +681,12 @@ func GToken() string {
 }
 
 func commaSeparatedStringToSlice(s []string) []string {
-	if len(s) > 1 {
+	if len(s) != 1 {
 		return s
 	}
+	if s[0] == "" {
+		return []string{}
+	}
 	return strings.Split(s[0], ",")
 }
 
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/leosunmo/pagertally/pkg/timespan"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// DataSourceConfig is an entry of the "datasources" config, a named datasource of the registered Type
+// attributing its spans with Attribute. Options are the settings of the type, see DecodeOptions
+type DataSourceConfig struct {
+	Name      string                 `json:"name" mapstructure:"name"`
+	Type      string                 `json:"type" mapstructure:"type"`
+	Attribute string                 `json:"attribute" mapstructure:"attribute"`
+	Options   map[string]interface{} `json:"options,omitempty" mapstructure:"options"`
+	// ParsedAttribute is the parsed Attribute
+	ParsedAttribute timespan.OnCallAttribute `json:"-" mapstructure:"-"`
+}
+
+// readDataSources reads the "datasources" config. Time bands have to be registered first,
+// so datasources can attribute their time
+func readDataSources(attrs *timespan.Attributes) ([]DataSourceConfig, error) {
+	var entries []DataSourceConfig
+	if err := viper.UnmarshalKey("datasources", &entries); err != nil {
+		return nil, err
+	}
+	if err := parseDataSources(entries, attrs); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// parseDataSources checks the entries have unique names and a type, and parses their attributes from attrs.
+// Business hours are whatever time no datasource applies to, so they can't have a datasource
+func parseDataSources(entries []DataSourceConfig, attrs *timespan.Attributes) error {
+	seen := map[string]bool{}
+	for i, entry := range entries {
+		if entry.Name == "" {
+			return fmt.Errorf("datasource %d has no name", i+1)
+		}
+		if seen[strings.ToLower(entry.Name)] {
+			return fmt.Errorf("%s is listed more than once", entry.Name)
+		}
+		seen[strings.ToLower(entry.Name)] = true
+		if entry.Type == "" {
+			return fmt.Errorf("%s: missing type", entry.Name)
+		}
+		attr, err := attrs.Parse(entry.Attribute)
+		if err != nil {
+			return fmt.Errorf("%s: %s", entry.Name, err.Error())
+		}
+		if attr == timespan.Business {
+			return fmt.Errorf("%s: %s is whatever time no datasource applies to and can't have a datasource", entry.Name, entry.Attribute)
+		}
+		entries[i].ParsedAttribute = attr
+	}
+	return nil
+}
+
+// DecodeOptions decodes the options of the datasource in to out, a pointer to a struct with mapstructure tags.
+// Company days in options can be plain strings like in the "company_days" config
+func (entry DataSourceConfig) DecodeOptions(out interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       companyDayDecodeHook,
+		WeaklyTypedInput: true,
+		Result:           out,
+	})
+	if err != nil {
+		return err
+	}
+	if err = decoder.Decode(entry.Options); err != nil {
+		return fmt.Errorf("%s: failed to parse options, err: %s", entry.Name, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,372 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+// MonthFormat is the format expected by "--month", e.g. "March 2018"
+const MonthFormat = "January 2006"
+
+// DateFormat is the format expected by "--since" and "--until"
+const DateFormat = "2006-01-02"
+
+// Named reporting periods accepted by "--period"
+const (
+	PeriodPreviousMonth     = "previous-month"
+	PeriodCurrentMonth      = "current-month"
+	PeriodPreviousWeek      = "previous-week"
+	PeriodCurrentWeek       = "current-week"
+	PeriodPreviousFortnight = "previous-fortnight"
+	PeriodPreviousQuarter   = "previous-quarter"
+	PeriodCurrentQuarter    = "current-quarter"
+	PeriodYearToDate        = "year-to-date"
+	PeriodPreviousYear      = "previous-year"
+)
+
+// Relative pay periods accepted by "--period" when pay periods are configured.
+// A plain number N is also accepted and means N pay periods before the current one.
+const (
+	PayPeriodCurrent  = "current"
+	PayPeriodPrevious = "previous"
+)
+
+// Period is a resolved reporting period with a human readable name,
+// e.g. "March 2018", "2018-W10" or "fortnightly 2018-03-05 - 2018-03-18"
+type Period struct {
+	timespan.Span
+	Name string
+}
+
+// PayPeriod is a recurring pay period calendar configured in "pay_periods".
+// Either Every and Anchor are set for fixed length periods, e.g. every 14 days
+// starting 2026-01-05, or DaysOfMonth is set for periods starting on the same days
+// every month, e.g. [1, 16] for semi-monthly.
+type PayPeriod struct {
+	Every       int    `mapstructure:"every"`
+	Anchor      string `mapstructure:"anchor"`
+	DaysOfMonth []int  `mapstructure:"days_of_month"`
+}
+
+// PeriodOptions holds the different ways a reporting period can be requested.
+// Only one of them can be set at a time, except Since and Until which go together.
+type PeriodOptions struct {
+	Month   string
+	Week    string
+	Quarter string
+	Since   string
+	Until   string
+	Period  string
+	// PayPeriod is the name of the pay period calendar that relative periods use
+	PayPeriod  string
+	PayPeriods map[string]PayPeriod
+}
+
+// ResolvePeriod returns the reporting period described by opts, relative to now in loc.
+// If nothing is set it defaults to the previous pay period if one has been selected,
+// otherwise the previous calendar month.
+func ResolvePeriod(opts PeriodOptions, now time.Time, loc *time.Location) (Period, error) {
+	now = now.In(loc)
+	set := []string{}
+	if opts.Month != "" {
+		set = append(set, "month")
+	}
+	if opts.Week != "" {
+		set = append(set, "week")
+	}
+	if opts.Quarter != "" {
+		set = append(set, "quarter")
+	}
+	if opts.Since != "" || opts.Until != "" {
+		set = append(set, "since/until")
+	}
+	if opts.Period != "" {
+		set = append(set, "period")
+	}
+	if len(set) > 1 {
+		return Period{}, fmt.Errorf("only one reporting period can be specified, got %s", strings.Join(set, ", "))
+	}
+
+	var span timespan.Span
+	var err error
+	switch {
+	case opts.Month != "":
+		start, perr := time.ParseInLocation(MonthFormat, opts.Month, loc)
+		if perr != nil {
+			return Period{}, fmt.Errorf("failed to parse month %q, expected format %q", opts.Month, MonthFormat)
+		}
+		span = timespan.New(start, start.AddDate(0, 1, 0))
+	case opts.Week != "":
+		span, err = parseWeek(opts.Week, loc)
+	case opts.Quarter != "":
+		span, err = parseQuarter(opts.Quarter, loc)
+	case opts.Since != "" || opts.Until != "":
+		span, err = parseSinceUntil(opts.Since, opts.Until, now, loc)
+	case opts.Period != "":
+		if isRelativePayPeriod(opts.Period) {
+			return resolvePayPeriod(opts, opts.Period, now)
+		}
+		span, err = namedPeriod(opts.Period, now)
+	case opts.PayPeriod != "":
+		return resolvePayPeriod(opts, PayPeriodPrevious, now)
+	default:
+		span, err = namedPeriod(PeriodPreviousMonth, now)
+	}
+	if err != nil {
+		return Period{}, err
+	}
+	return Period{Span: span, Name: spanName(span)}, nil
+}
+
+// isRelativePayPeriod returns true if period refers to a pay period rather than a named period
+func isRelativePayPeriod(period string) bool {
+	switch strings.ToLower(period) {
+	case PayPeriodCurrent, PayPeriodPrevious:
+		return true
+	}
+	_, err := strconv.Atoi(period)
+	return err == nil
+}
+
+// resolvePayPeriod finds the pay period calendar to use and returns the period
+// that is "current", "previous" or N periods before the current one
+func resolvePayPeriod(opts PeriodOptions, period string, now time.Time) (Period, error) {
+	name := opts.PayPeriod
+	if name == "" {
+		if len(opts.PayPeriods) != 1 {
+			return Period{}, fmt.Errorf("period %q needs a pay period, configure exactly one in \"pay_periods\" or select one with \"--pay-period\"", period)
+		}
+		for n := range opts.PayPeriods {
+			name = n
+		}
+	}
+	pp, exists := opts.PayPeriods[name]
+	if !exists {
+		return Period{}, fmt.Errorf("pay period %q is not configured in \"pay_periods\"", name)
+	}
+
+	var offset int
+	switch strings.ToLower(period) {
+	case PayPeriodCurrent:
+		offset = 0
+	case PayPeriodPrevious:
+		offset = 1
+	default:
+		var err error
+		offset, err = strconv.Atoi(period)
+		if err != nil || offset < 0 {
+			return Period{}, fmt.Errorf("failed to parse pay period offset %q", period)
+		}
+	}
+
+	span, err := pp.Period(now, offset)
+	if err != nil {
+		return Period{}, fmt.Errorf("pay period %q: %s", name, err.Error())
+	}
+	return Period{Span: span, Name: name + " " + spanName(span)}, nil
+}
+
+// Period returns the pay period offset periods before the one containing now
+func (pp PayPeriod) Period(now time.Time, offset int) (timespan.Span, error) {
+	today := timespan.StartOfDay(now)
+	switch {
+	case pp.Every > 0:
+		if len(pp.DaysOfMonth) != 0 {
+			return timespan.Span{}, fmt.Errorf("only one of \"every\" and \"days_of_month\" can be set")
+		}
+		anchor, err := time.ParseInLocation(DateFormat, pp.Anchor, now.Location())
+		if err != nil {
+			return timespan.Span{}, fmt.Errorf("failed to parse anchor %q, expected format %q", pp.Anchor, DateFormat)
+		}
+		// Count whole days rather than hours so DST changes don't shift the boundaries
+		days := daysBetween(anchor, today)
+		index := days / pp.Every
+		if days < 0 && days%pp.Every != 0 {
+			index--
+		}
+		start := anchor.AddDate(0, 0, (index-offset)*pp.Every)
+		return timespan.New(start, start.AddDate(0, 0, pp.Every)), nil
+	case len(pp.DaysOfMonth) != 0:
+		for _, d := range pp.DaysOfMonth {
+			if d < 1 || d > 31 {
+				return timespan.Span{}, fmt.Errorf("day of month %d is out of range", d)
+			}
+		}
+		days := append([]int{}, pp.DaysOfMonth...)
+		sort.Ints(days)
+		// Walk back from the start of next month until we find the boundary the current period started on
+		year, month := today.Year(), today.Month()
+		boundaries := []time.Time{}
+		for m := 1; m >= -(offset/len(days) + 2); m-- {
+			for i := len(days) - 1; i >= 0; i-- {
+				boundaries = append(boundaries, dayOfMonth(year, month+time.Month(m), days[i], today.Location()))
+			}
+		}
+		for i, boundary := range boundaries {
+			if boundary.After(today) {
+				continue
+			}
+			if i+offset >= len(boundaries) || i == 0 {
+				break
+			}
+			return timespan.New(boundaries[i+offset], boundaries[i+offset-1]), nil
+		}
+		return timespan.Span{}, fmt.Errorf("unable to find pay period")
+	}
+	return timespan.Span{}, fmt.Errorf("either \"every\" and \"anchor\" or \"days_of_month\" must be set")
+}
+
+// dayOfMonth returns midnight on day of the month, clamped to the last day of the month
+func dayOfMonth(year int, month time.Month, day int, loc *time.Location) time.Time {
+	firstOfMonth := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return firstOfMonth.AddDate(0, 0, day-1)
+}
+
+// daysBetween returns the number of calendar days from a to b
+func daysBetween(a, b time.Time) int {
+	ua := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	ub := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(ub.Sub(ua).Hours() / 24)
+}
+
+// spanName returns a human readable name for a reporting span.
+// Whole calendar months are named "March 2018", ISO weeks "2018-W10",
+// quarters "2018-Q1" and anything else by its first and last date
+func spanName(span timespan.Span) string {
+	start, end := span.Start(), span.End()
+	if start.Day() == 1 && timespan.IsStartOfDay(start) {
+		if end.Equal(start.AddDate(0, 1, 0)) {
+			return start.Format(MonthFormat)
+		}
+		if end.Equal(start.AddDate(0, 3, 0)) && (start.Month()-1)%3 == 0 {
+			return fmt.Sprintf("%d-Q%d", start.Year(), (start.Month()-1)/3+1)
+		}
+	}
+	if start.Weekday() == time.Monday && timespan.IsStartOfDay(start) && end.Equal(start.AddDate(0, 0, 7)) {
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}
+	// Periods end at midnight, so the last day of the period is the day before
+	lastDay := end
+	if timespan.IsStartOfDay(end) {
+		lastDay = end.AddDate(0, 0, -1)
+	}
+	return start.Format(DateFormat) + " - " + lastDay.Format(DateFormat)
+}
+
+// parseWeek parses an ISO 8601 week such as "2026-W10" in to a span from Monday 00:00 to the following Monday 00:00
+func parseWeek(week string, loc *time.Location) (timespan.Span, error) {
+	parts := strings.Split(strings.ToUpper(week), "-W")
+	if len(parts) != 2 {
+		return timespan.Span{}, fmt.Errorf("failed to parse week %q, expected ISO week format \"2006-W01\"", week)
+	}
+	year, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return timespan.Span{}, fmt.Errorf("failed to parse year in week %q, err: %s", week, err.Error())
+	}
+	weekNum, err := strconv.Atoi(parts[1])
+	if err != nil || weekNum < 1 || weekNum > 53 {
+		return timespan.Span{}, fmt.Errorf("failed to parse week number in week %q", week)
+	}
+	// The 4th of January is always in ISO week 1
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, loc)
+	start := startOfWeek(jan4).AddDate(0, 0, (weekNum-1)*7)
+	return timespan.New(start, start.AddDate(0, 0, 7)), nil
+}
+
+// parseQuarter parses a quarter such as "2026-Q1" or "Q1 2026"
+func parseQuarter(quarter string, loc *time.Location) (timespan.Span, error) {
+	var yearPart, quarterPart string
+	q := strings.ToUpper(strings.TrimSpace(quarter))
+	switch {
+	case strings.Contains(q, "-Q"):
+		parts := strings.SplitN(q, "-Q", 2)
+		yearPart, quarterPart = parts[0], parts[1]
+	case strings.HasPrefix(q, "Q") && strings.Contains(q, " "):
+		parts := strings.SplitN(q[1:], " ", 2)
+		quarterPart, yearPart = parts[0], parts[1]
+	default:
+		return timespan.Span{}, fmt.Errorf("failed to parse quarter %q, expected format \"2006-Q1\" or \"Q1 2006\"", quarter)
+	}
+	year, err := strconv.Atoi(strings.TrimSpace(yearPart))
+	if err != nil {
+		return timespan.Span{}, fmt.Errorf("failed to parse year in quarter %q, err: %s", quarter, err.Error())
+	}
+	qNum, err := strconv.Atoi(strings.TrimSpace(quarterPart))
+	if err != nil || qNum < 1 || qNum > 4 {
+		return timespan.Span{}, fmt.Errorf("failed to parse quarter number in %q", quarter)
+	}
+	start := time.Date(year, time.Month((qNum-1)*3+1), 1, 0, 0, 0, 0, loc)
+	return timespan.New(start, start.AddDate(0, 3, 0)), nil
+}
+
+// parseSinceUntil parses an arbitrary date range. Both dates are inclusive,
+// so "--since 2026-03-01 --until 2026-03-14" covers 14 whole days.
+// If until isn't provided it defaults to today.
+func parseSinceUntil(since, until string, now time.Time, loc *time.Location) (timespan.Span, error) {
+	if since == "" {
+		return timespan.Span{}, fmt.Errorf("\"--until\" requires \"--since\"")
+	}
+	start, err := time.ParseInLocation(DateFormat, since, loc)
+	if err != nil {
+		return timespan.Span{}, fmt.Errorf("failed to parse since date %q, expected format %q", since, DateFormat)
+	}
+	end := timespan.StartOfDay(now)
+	if until != "" {
+		end, err = time.ParseInLocation(DateFormat, until, loc)
+		if err != nil {
+			return timespan.Span{}, fmt.Errorf("failed to parse until date %q, expected format %q", until, DateFormat)
+		}
+	}
+	end = end.AddDate(0, 0, 1)
+	if !end.After(start) {
+		return timespan.Span{}, fmt.Errorf("until date %s is before since date %s", until, since)
+	}
+	return timespan.New(start, end), nil
+}
+
+// namedPeriod returns the span of one of the named periods relative to now
+func namedPeriod(name string, now time.Time) (timespan.Span, error) {
+	today := timespan.StartOfDay(now)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	quarter := time.Date(now.Year(), time.Month((int(now.Month())-1)/3*3+1), 1, 0, 0, 0, 0, now.Location())
+	year := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, now.Location())
+	week := startOfWeek(today)
+
+	switch strings.ToLower(name) {
+	case PeriodPreviousMonth:
+		return timespan.New(month.AddDate(0, -1, 0), month), nil
+	case PeriodCurrentMonth:
+		return timespan.New(month, month.AddDate(0, 1, 0)), nil
+	case PeriodPreviousWeek:
+		return timespan.New(week.AddDate(0, 0, -7), week), nil
+	case PeriodCurrentWeek:
+		return timespan.New(week, week.AddDate(0, 0, 7)), nil
+	case PeriodPreviousFortnight:
+		return timespan.New(week.AddDate(0, 0, -14), week), nil
+	case PeriodPreviousQuarter:
+		return timespan.New(quarter.AddDate(0, -3, 0), quarter), nil
+	case PeriodCurrentQuarter:
+		return timespan.New(quarter, quarter.AddDate(0, 3, 0)), nil
+	case PeriodYearToDate:
+		return timespan.New(year, today.AddDate(0, 0, 1)), nil
+	case PeriodPreviousYear:
+		return timespan.New(year.AddDate(-1, 0, 0), year), nil
+	}
+	return timespan.Span{}, fmt.Errorf("unknown period %q", name)
+}
+
+// startOfWeek returns midnight on the Monday of the week t is in
+func startOfWeek(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return timespan.StartOfDay(t).AddDate(0, 0, -offset)
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+var aklTz, _ = time.LoadLocation("Pacific/Auckland")
+
+// Friday 6th March 2026, 18:00
+var testNow = time.Date(2026, time.March, 6, 18, 0, 0, 0, aklTz)
+
+func TestResolvePeriod(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  PeriodOptions
+		start string
+		end   string
+	}{
+		{"default", PeriodOptions{}, "2026-02-01", "2026-03-01"},
+		{"month", PeriodOptions{Month: "March 2018"}, "2018-03-01", "2018-04-01"},
+		{"week", PeriodOptions{Week: "2026-W10"}, "2026-03-02", "2026-03-09"},
+		{"week in previous year", PeriodOptions{Week: "2021-W01"}, "2021-01-04", "2021-01-11"},
+		{"quarter", PeriodOptions{Quarter: "2026-Q2"}, "2026-04-01", "2026-07-01"},
+		{"quarter alt format", PeriodOptions{Quarter: "Q4 2025"}, "2025-10-01", "2026-01-01"},
+		{"since until", PeriodOptions{Since: "2026-01-05", Until: "2026-01-18"}, "2026-01-05", "2026-01-19"},
+		{"since", PeriodOptions{Since: "2026-03-01"}, "2026-03-01", "2026-03-07"},
+		{"previous week", PeriodOptions{Period: PeriodPreviousWeek}, "2026-02-23", "2026-03-02"},
+		{"previous fortnight", PeriodOptions{Period: PeriodPreviousFortnight}, "2026-02-16", "2026-03-02"},
+		{"previous quarter", PeriodOptions{Period: PeriodPreviousQuarter}, "2025-10-01", "2026-01-01"},
+		{"year to date", PeriodOptions{Period: PeriodYearToDate}, "2026-01-01", "2026-03-07"},
+	}
+	for _, test := range tests {
+		span, err := ResolvePeriod(test.opts, testNow, aklTz)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err.Error())
+			continue
+		}
+		if span.Start().Format(DateFormat) != test.start || span.End().Format(DateFormat) != test.end {
+			t.Errorf("%s: expected %s to %s, got %s to %s", test.name, test.start, test.end, span.Start(), span.End())
+		}
+		if span.Start().Location() != aklTz {
+			t.Errorf("%s: expected span in %s, got %s", test.name, aklTz, span.Start().Location())
+		}
+	}
+}
+
+func TestResolvePeriodErrors(t *testing.T) {
+	tests := []PeriodOptions{
+		{Month: "March 2018", Week: "2018-W10"},
+		{Until: "2018-03-01"},
+		{Since: "2018-03-10", Until: "2018-03-01"},
+		{Week: "2018-10"},
+		{Quarter: "2018-Q5"},
+		{Period: "next-decade"},
+	}
+	for _, opts := range tests {
+		if _, err := ResolvePeriod(opts, testNow, aklTz); err == nil {
+			t.Errorf("Expected error for %+v", opts)
+		}
+	}
+}
+
+func TestResolvePayPeriod(t *testing.T) {
+	payPeriods := map[string]PayPeriod{
+		"fortnightly":  {Every: 14, Anchor: "2026-01-05"},
+		"semi-monthly": {DaysOfMonth: []int{16, 1}},
+		"monthly-31st": {DaysOfMonth: []int{31}},
+	}
+	tests := []struct {
+		payPeriod string
+		period    string
+		start     string
+		end       string
+	}{
+		{"fortnightly", PayPeriodCurrent, "2026-03-02", "2026-03-16"},
+		{"fortnightly", PayPeriodPrevious, "2026-02-16", "2026-03-02"},
+		{"fortnightly", "", "2026-02-16", "2026-03-02"},
+		{"fortnightly", "5", "2025-12-22", "2026-01-05"},
+		{"semi-monthly", PayPeriodCurrent, "2026-03-01", "2026-03-16"},
+		{"semi-monthly", PayPeriodPrevious, "2026-02-16", "2026-03-01"},
+		{"semi-monthly", "3", "2026-01-16", "2026-02-01"},
+		{"monthly-31st", PayPeriodCurrent, "2026-02-28", "2026-03-31"},
+	}
+	for _, test := range tests {
+		opts := PeriodOptions{Period: test.period, PayPeriod: test.payPeriod, PayPeriods: payPeriods}
+		period, err := ResolvePeriod(opts, testNow, aklTz)
+		if err != nil {
+			t.Errorf("%s %s: unexpected error: %s", test.payPeriod, test.period, err.Error())
+			continue
+		}
+		if period.Start().Format(DateFormat) != test.start || period.End().Format(DateFormat) != test.end {
+			t.Errorf("%s %s: expected %s to %s, got %s to %s", test.payPeriod, test.period, test.start, test.end, period.Start(), period.End())
+		}
+	}
+
+	// Relative periods can't be resolved without knowing which pay period to use
+	if _, err := ResolvePeriod(PeriodOptions{Period: PayPeriodCurrent, PayPeriods: payPeriods}, testNow, aklTz); err == nil {
+		t.Errorf("Expected error when pay period is ambiguous")
+	}
+}
+
+func TestPeriodName(t *testing.T) {
+	tests := []struct {
+		opts PeriodOptions
+		name string
+	}{
+		{PeriodOptions{Month: "March 2018"}, "March 2018"},
+		{PeriodOptions{Week: "2026-W10"}, "2026-W10"},
+		{PeriodOptions{Quarter: "2026-Q2"}, "2026-Q2"},
+		{PeriodOptions{Since: "2026-01-05", Until: "2026-01-18"}, "2026-01-05 - 2026-01-18"},
+		{PeriodOptions{Period: PayPeriodCurrent, PayPeriods: map[string]PayPeriod{"fortnightly": {Every: 14, Anchor: "2026-01-05"}}}, "fortnightly 2026-03-02 - 2026-03-15"},
+	}
+	for _, test := range tests {
+		period, err := ResolvePeriod(test.opts, testNow, aklTz)
+		if err != nil {
+			t.Errorf("%+v: unexpected error: %s", test.opts, err.Error())
+			continue
+		}
+		if period.Name != test.name {
+			t.Errorf("Expected period name %q, got %q", test.name, period.Name)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/compensation"
+	"github.com/spf13/viper"
+)
+
+// ScheduleConfigs are the configs of schedules with a profile in the "schedules" config map,
+// by lower case PagerDuty schedule ID. Schedules without a profile use GlobalConfig
+var ScheduleConfigs map[string]ScheduleConfig
+
+// scheduleProfileConfig is a single schedule's profile in the "schedules" config map.
+// Anything left out falls back to the global config
+type scheduleProfileConfig struct {
+	scheduleRatesConfig `mapstructure:",squash"`
+	Holidays            []string            `mapstructure:"holidays"`
+	BusinessHours       BusinessHoursStruct `mapstructure:"business_hours"`
+	Timezone            string              `mapstructure:"timezone"`
+	CalendarURL         string              `mapstructure:"ical_url"`
+	CalendarURLs        []string            `mapstructure:"ical_urls"`
+	HolidayRules        []string            `mapstructure:"holiday_rules"`
+	HolidayDates        []HolidayDate       `mapstructure:"holiday_dates"`
+	CompanyDays         []CompanyDay        `mapstructure:"company_days"`
+	Attribution         attributionConfig   `mapstructure:"attribution"`
+	DataSources         []DataSourceConfig  `mapstructure:"datasources"`
+}
+
+// readScheduleProfiles reads the "schedules" map from the config file if it's a map rather than a list of IDs.
+// It's read straight from the file as the --schedules flag shadows it in viper
+func readScheduleProfiles(configFile string) (map[string]scheduleProfileConfig, error) {
+	fileConfig := viper.New()
+	fileConfig.SetConfigFile(configFile)
+	if err := fileConfig.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	if _, isMap := fileConfig.Get("schedules").(map[string]interface{}); !isMap {
+		return nil, nil
+	}
+	profiles := map[string]scheduleProfileConfig{}
+	err := fileConfig.UnmarshalKey("schedules", &profiles, viper.DecodeHook(companyDayDecodeHook))
+	return profiles, err
+}
+
+// profileScheduleIDs returns the schedule IDs of the profiles. Viper lower cases map keys,
+// but PagerDuty IDs are always upper case
+func profileScheduleIDs(profiles map[string]scheduleProfileConfig) []string {
+	ids := []string{}
+	for id := range profiles {
+		ids = append(ids, strings.ToUpper(id))
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// buildScheduleConfigs applies every profile on top of global and adds their rates to comp
+func buildScheduleConfigs(global ScheduleConfig, profiles map[string]scheduleProfileConfig, comp *compensation.Config) (map[string]ScheduleConfig, error) {
+	configs := map[string]ScheduleConfig{}
+	for id, profile := range profiles {
+		sc, err := profile.toScheduleConfig(global)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %s: %s", id, err.Error())
+		}
+		configs[strings.ToLower(id)] = sc
+		if profile.scheduleRatesConfig.isSet() {
+			rates, rerr := profile.scheduleRatesConfig.toScheduleRates(global.Attributes)
+			if rerr != nil {
+				return nil, fmt.Errorf("schedule %s: %s", id, rerr.Error())
+			}
+			comp.Schedules[strings.ToLower(id)] = rates
+		}
+	}
+	return configs, nil
+}
+
+// toScheduleConfig returns global with the overrides of the profile applied
+func (p scheduleProfileConfig) toScheduleConfig(global ScheduleConfig) (ScheduleConfig, error) {
+	sc := global
+	if p.Holidays != nil {
+		sc.Holidays = p.Holidays
+	}
+	if p.BusinessHours.Start != "" {
+		sc.BusinessHours.Start = p.BusinessHours.Start
+	}
+	if p.BusinessHours.End != "" {
+		sc.BusinessHours.End = p.BusinessHours.End
+	}
+	if p.BusinessHours.Week != nil {
+		// Weekdays the profile leaves out keep their global hours
+		week := map[string]string{}
+		for day, hours := range global.BusinessHours.Week {
+			week[day] = hours
+		}
+		for day, hours := range p.BusinessHours.Week {
+			week[day] = hours
+		}
+		sc.BusinessHours.Week = week
+	}
+	if err := p.Attribution.apply(&sc); err != nil {
+		return ScheduleConfig{}, err
+	}
+	if p.Timezone != "" {
+		loc, err := time.LoadLocation(p.Timezone)
+		if err != nil {
+			return ScheduleConfig{}, fmt.Errorf("failed to parse timezone, use IANA TZ format, err: %s", err.Error())
+		}
+		sc.Timezone = p.Timezone
+		sc.ParsedTimezone = loc
+	}
+	if p.CalendarURL != "" {
+		sc.CalendarURL = p.CalendarURL
+	}
+	if p.CalendarURLs != nil {
+		sc.CalendarURLs = p.CalendarURLs
+	}
+	if p.HolidayRules != nil {
+		sc.HolidayRules = p.HolidayRules
+	}
+	if p.HolidayDates != nil {
+		sc.HolidayDates = p.HolidayDates
+	}
+	if p.CompanyDays != nil {
+		sc.CompanyDays, sc.NamedCompanyDays = splitCompanyDays(p.CompanyDays)
+	}
+	if p.DataSources != nil {
+		if err := parseDataSources(p.DataSources, sc.Attributes); err != nil {
+			return ScheduleConfig{}, fmt.Errorf("failed to parse datasources, err: %s", err.Error())
+		}
+		sc.DataSources = p.DataSources
+	}
+	if err := sc.Validate(); err != nil {
+		return ScheduleConfig{}, err
+	}
+	return sc, nil
+}
+
+// isSet returns true if any rates have been configured
+func (sr scheduleRatesConfig) isSet() bool {
+	return sr.BaseRate != 0 || len(sr.Rates) != 0 || len(sr.Multipliers) != 0 || len(sr.Allowances) != 0 || len(sr.CalloutFees) != 0
+}
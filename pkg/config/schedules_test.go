@@ -0,0 +1,92 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/leosunmo/pagertally/pkg/compensation"
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+const testSchedulesConfig = `
+schedules:
+  PNZ1234:
+    holidays:
+      - "Waitangi Day"
+  PAU5678:
+    timezone: "Australia/Sydney"
+    business_hours:
+      start: "09:00"
+    ical_url: "https://example.com/au.ics"
+    multipliers:
+      weekend: 2
+`
+
+func TestScheduleProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pagertally")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(configFile, []byte(testSchedulesConfig), 0644); err != nil {
+		t.Fatalf("Failed to write config: %s", err.Error())
+	}
+
+	profiles, err := readScheduleProfiles(configFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	ids := profileScheduleIDs(profiles)
+	if len(ids) != 2 || ids[0] != "PAU5678" || ids[1] != "PNZ1234" {
+		t.Errorf("Expected schedule IDs [PAU5678 PNZ1234], got %v", ids)
+	}
+
+	global := ScheduleConfig{
+		Holidays:       []string{"Christmas Day"},
+		BusinessHours:  BusinessHoursStruct{Start: "08:00", End: "17:30"},
+		CalendarURL:    "https://example.com/nz.ics",
+		Timezone:       "Pacific/Auckland",
+		ParsedTimezone: aklTz,
+	}
+	comp := compensation.Config{BaseRate: 10, Schedules: map[string]compensation.ScheduleRates{}}
+	configs, err := buildScheduleConfigs(global, profiles, &comp)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	nz := configs["pnz1234"]
+	if len(nz.Holidays) != 1 || nz.Holidays[0] != "Waitangi Day" || nz.CalendarURL != "https://example.com/nz.ics" {
+		t.Errorf("Expected NZ schedule to override holidays only, got %+v", nz)
+	}
+	au := configs["pau5678"]
+	if loc, err := au.Location(); err != nil || loc.String() != "Australia/Sydney" || au.BusinessHours.Start != "09:00" || au.BusinessHours.End != "17:30" || au.Holidays[0] != "Christmas Day" {
+		t.Errorf("Expected AU schedule to override timezone and business hours start, got %+v", au)
+	}
+	if rate := comp.HourlyRate(comp.ScheduleKey("PAU5678", "AU primary"), timespan.Weekend); rate != 20 {
+		t.Errorf("Expected AU weekend rate of 20, got %.2f", rate)
+	}
+	if _, exists := comp.Schedules["pau5678"]; !exists {
+		t.Errorf("Expected AU rates to be added to the compensation config")
+	}
+	if _, exists := comp.Schedules["pnz1234"]; exists {
+		t.Errorf("Expected no rates for the NZ schedule")
+	}
+}
+
+func TestScheduleLocation(t *testing.T) {
+	sc := ScheduleConfig{Timezone: "Pacific/Auckland"}
+	if loc, err := sc.Location(); err != nil || loc.String() != "Pacific/Auckland" {
+		t.Errorf("Expected Pacific/Auckland, got %v and %v", loc, err)
+	}
+	// Schedules that haven't been validated don't fall back to UTC either
+	invalid := ScheduleConfig{Timezone: "Pacific/Nowhere"}
+	if loc, err := invalid.Location(); err == nil {
+		t.Errorf("Expected an error for an unknown timezone, got %s", loc)
+	}
+	if err := invalid.Validate(); err == nil {
+		t.Errorf("Expected validating an unknown timezone to fail")
+	}
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/timespan"
+	"github.com/spf13/viper"
+)
+
+// TimeBand is a user-defined on-call attribute in the "time_bands" config, like a night premium.
+// Time is attributed to the band during its weekly windows and on its dates
+type TimeBand struct {
+	Name string `json:"name" mapstructure:"name"`
+	// Before is the name of the built in attribute the band takes precedence over, along with every
+	// attribute after it. Bands without it take precedence over all built in attributes
+	Before string         `json:"before,omitempty" mapstructure:"before"`
+	Weekly []WeeklyWindow `json:"weekly,omitempty" mapstructure:"weekly"`
+	// Dates are whole days in any of the formats of company days, including recurrences like "every 24/12"
+	Dates []string `json:"dates,omitempty" mapstructure:"dates"`
+	// Attribute and BeforeAttribute are the registered attribute of the band and the parsed Before
+	Attribute       timespan.OnCallAttribute `json:"-" mapstructure:"-"`
+	BeforeAttribute timespan.OnCallAttribute `json:"-" mapstructure:"-"`
+}
+
+// WeeklyWindow is a window of time on the listed weekdays, or every day if there are none.
+// The window continues into the next day if End isn't after Start, so "22:00" to "06:00" is overnight
+type WeeklyWindow struct {
+	Days  []string `json:"days,omitempty" mapstructure:"days"`
+	Start string   `json:"start" mapstructure:"start"`
+	End   string   `json:"end" mapstructure:"end"`
+}
+
+// readTimeBands reads the "time_bands" config and registers an on-call attribute for every band in attrs,
+// so they can be used in the compensation config like the built in ones
+func readTimeBands(attrs *timespan.Attributes) ([]TimeBand, error) {
+	var bands []TimeBand
+	if err := viper.UnmarshalKey("time_bands", &bands); err != nil {
+		return nil, err
+	}
+	for i, band := range bands {
+		if err := band.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %s", band.Name, err.Error())
+		}
+		attr, err := attrs.Register(band.Name)
+		if err != nil {
+			return nil, err
+		}
+		bands[i].Attribute = attr
+		if band.Before != "" {
+			before, berr := timespan.ParseOnCallAttribute(band.Before)
+			if berr != nil || !before.IsBuiltIn() {
+				return nil, fmt.Errorf("%s: before must be a built in on-call attribute, got %q", band.Name, band.Before)
+			}
+			bands[i].BeforeAttribute = before
+		}
+	}
+	return bands, nil
+}
+
+// Validate returns an error if the times or weekdays of the band's windows can't be parsed
+func (band TimeBand) Validate() error {
+	for _, window := range band.Weekly {
+		for _, day := range window.Days {
+			if _, err := parseWeekday(day); err != nil {
+				return err
+			}
+		}
+		if _, err := time.Parse(timeShortForm, window.Start); err != nil {
+			return err
+		}
+		if _, err := time.Parse(timeShortForm, window.End); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OnDay returns true if the window starts on weekday
+func (w WeeklyWindow) OnDay(weekday time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, day := range w.Days {
+		if parsed, err := parseWeekday(day); err == nil && parsed == weekday {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+func TestTimeBandAttributesPerConfig(t *testing.T) {
+	first, second := timespan.NewAttributes(), timespan.NewAttributes()
+	firstNight, err := first.Register("night")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	evening, err := first.Register("evening")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	secondNight, err := second.Register("night")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if again, _ := first.Register("night"); again != firstNight {
+		t.Errorf("Expected registering night again to return the same attribute")
+	}
+	if first.Name(firstNight) != "night" || second.Name(secondNight) != "night" {
+		t.Errorf("Expected both attributes to be named night, got %s and %s", first.Name(firstNight), second.Name(secondNight))
+	}
+	// Names are only kept by the config that registered them
+	if name := second.Name(evening); name != "unknown" {
+		t.Errorf("Expected the second config not to name the first config's evening, got %s", name)
+	}
+	if name := firstNight.String(); name != "unknown" {
+		t.Errorf("Expected time bands not to be named outside their config, got %s", name)
+	}
+
+	firstRates, err := attributeRates(map[string]float64{"night": 10, "weekend": 5}, first)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	secondRates, err := attributeRates(map[string]float64{"night": 20}, second)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if firstRates[firstNight] != 10 || firstRates[timespan.Weekend] != 5 || secondRates[secondNight] != 20 || len(secondRates) != 1 {
+		t.Errorf("Expected each config's night rate on its own attribute, got %v and %v", firstRates, secondRates)
+	}
+	// The second config doesn't know the first config's bands
+	if _, err = attributeRates(map[string]float64{"evening": 10}, second); err == nil {
+		t.Errorf("Expected an error for a time band of another config")
+	}
+	if attrs := second.All(); len(attrs) != len(timespan.BuiltInAttributes())+1 || attrs[len(attrs)-1] != secondNight {
+		t.Errorf("Expected the built in attributes and night, got %v", attrs)
+	}
+}
@@ -3,7 +3,10 @@ package datasources
 // iCal calendar parser (for public holidays off NZ public holidays source)
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	ics "github.com/leosunmo/ics-golang"
@@ -16,35 +19,100 @@ import (
 const YmdHis string = "2006-01-02 15:04:05"
 
 // CalendarDataSource is an iCal Datasource that has lists of timespans
-// based on a whitelist of events provided in configuration
+// based on a whitelist of events provided in configuration, and holidays configured inline
 type CalendarDataSource struct {
 	CalTotalSpan  timespan.Span
 	CalendarSpans []timespan.Span
 	CalTimezone   *time.Location
+	// CalendarURLs are the iCal URLs, file:// URLs or file paths the events are read from
+	CalendarURLs []string
+	Holidays     []string
+	// HolidayRules are the names of the HolidayRuleSets to generate holidays from
+	HolidayRules []string
+	HolidayDates []config.HolidayDate
+	// events are where the spans of the calendar come from, like the iCal event
+	events []CompanyDayEvent
+	// loaded are the calendars already read, shared between copies of the datasource
+	loaded *loadedCalendars
 }
 
-// NewCalendarDataSource returns a DataSource populated by events provided in configured iCal
-func NewCalendarDataSource() CalendarDataSource {
-	startDate := config.GlobalConfig.ScheduleSpan.Start()
-	endDate := config.GlobalConfig.ScheduleSpan.End()
-	loc := config.Timezone()
+// loadedCalendars are the holidays of a calendar by reporting span, so the iCal is only downloaded
+// and parsed once per reporting period
+type loadedCalendars struct {
+	mu        sync.Mutex
+	calendars map[spansKey]CalendarDataSource
+}
 
-	cal := CalendarDataSource{
-		CalTotalSpan: timespan.New(startDate, endDate),
-		CalTimezone:  loc,
+// NewCalendarDataSourceFor returns a DataSource populated by events provided in the schedule's iCal,
+// or an error if the schedule's timezone can't be loaded
+func NewCalendarDataSourceFor(sc config.ScheduleConfig) (CalendarDataSource, error) {
+	loc, err := sc.Location()
+	if err != nil {
+		return CalendarDataSource{}, err
 	}
-	err := cal.parseAndFilterPublicHolidayiCal()
+	return CalendarDataSource{
+		CalTimezone:  loc,
+		CalendarURLs: sc.CalendarSources(),
+		Holidays:     sc.Holidays,
+		HolidayRules: sc.HolidayRules,
+		HolidayDates: sc.HolidayDates,
+		loaded:       &loadedCalendars{calendars: map[spansKey]CalendarDataSource{}},
+	}, nil
+}
+
+// Spans returns the timespans of the calendar during span. Holidays last whole days in the calendar's
+// timezone, whatever timezone loc is
+func (c CalendarDataSource) Spans(ctx context.Context, span timespan.Span, loc *time.Location) ([]timespan.Span, error) {
+	loaded, err := c.loadOnce(ctx, span)
 	if err != nil {
-		log.Fatalf("datasources/calendar: failed to retrieve public holidays, err: %s", err.Error())
-		return cal
+		return nil, err
 	}
+	return loaded.CalendarSpans, nil
+}
 
-	return cal
+// loadOnce returns the calendar with the holidays during span, only reading them the first time
+// it's asked for span
+func (c CalendarDataSource) loadOnce(ctx context.Context, span timespan.Span) (CalendarDataSource, error) {
+	if err := ctx.Err(); err != nil {
+		return CalendarDataSource{}, err
+	}
+	if c.loaded == nil {
+		err := c.load(span)
+		return c, err
+	}
+	key := spansKey{start: span.Start().UnixNano(), end: span.End().UnixNano()}
+	c.loaded.mu.Lock()
+	defer c.loaded.mu.Unlock()
+	if loaded, exists := c.loaded.calendars[key]; exists {
+		return loaded, nil
+	}
+	if err := c.load(span); err != nil {
+		return CalendarDataSource{}, err
+	}
+	c.loaded.calendars[key] = c
+	return c, nil
 }
 
-// Spans returns the timespans from the CalendarSource
-func (c CalendarDataSource) Spans() []timespan.Span {
-	return c.CalendarSpans
+// load reads the holidays of the calendar during span
+func (c *CalendarDataSource) load(span timespan.Span) error {
+	c.CalTotalSpan = span
+	c.CalendarSpans = nil
+	c.events = nil
+	if len(c.CalendarURLs) != 0 {
+		err := c.parseAndFilterPublicHolidayiCal()
+		if err != nil {
+			return fmt.Errorf("failed to retrieve public holidays, err: %s", err.Error())
+		}
+	}
+	err := c.addGeneratedHolidays()
+	if err != nil {
+		return fmt.Errorf("failed to generate holidays, err: %s", err.Error())
+	}
+	err = c.addHolidayDates()
+	if err != nil {
+		return fmt.Errorf("failed to parse holiday_dates, err: %s", err.Error())
+	}
+	return nil
 }
 
 func (c *CalendarDataSource) parseAndFilterPublicHolidayiCal() error {
@@ -57,7 +125,9 @@ func (c *CalendarDataSource) parseAndFilterPublicHolidayiCal() error {
 
 	// send the calendar urls to be parsed
 	//inputChan <- "http://apps.employment.govt.nz/ical/public-holidays-all.ics"
-	inputChan <- config.GlobalConfig.CalendarURL
+	for _, calendarURL := range c.CalendarURLs {
+		inputChan <- calendarPath(calendarURL)
+	}
 	//  wait for the calendar to be parsed
 	parser.Wait()
 
@@ -89,7 +159,7 @@ func (c *CalendarDataSource) parseAndFilterPublicHolidayiCal() error {
 				// See if event is in event whitelist
 				if c.filterEvent(event.GetSummary()) {
 					span := timespan.New(event.GetStart(), event.GetEnd())
-					c.addSpan(span)
+					c.addEvent(span, fmt.Sprintf("iCal event %q", event.GetSummary()))
 				}
 			}
 		}
@@ -97,11 +167,62 @@ func (c *CalendarDataSource) parseAndFilterPublicHolidayiCal() error {
 	return nil
 }
 
+// addGeneratedHolidays adds the whitelisted holidays generated from the holiday rule sets for every year
+// of the calendar, each lasting the whole day in the calendar's timezone. All holidays are added if
+// there is no whitelist
+func (c *CalendarDataSource) addGeneratedHolidays() error {
+	if len(c.HolidayRules) == 0 {
+		return nil
+	}
+	rules, err := HolidayRulesFor(c.HolidayRules)
+	if err != nil {
+		return err
+	}
+	first := c.CalTotalSpan.Start().In(c.CalTimezone).Year()
+	last := c.CalTotalSpan.End().In(c.CalTimezone).Year()
+	for year := first; year <= last; year++ {
+		for _, holiday := range GenerateHolidays(rules, year, c.CalTimezone) {
+			if len(c.Holidays) != 0 && !c.filterEvent(holiday.Name) {
+				continue
+			}
+			span := timespan.New(holiday.Date, holiday.Date.AddDate(0, 0, 1))
+			if !span.Overlaps(c.CalTotalSpan) {
+				continue
+			}
+			c.addEvent(span, fmt.Sprintf("holiday rule %q", holiday.Name))
+		}
+	}
+	return nil
+}
+
+// addHolidayDates adds the holidays configured inline, each lasting the whole day in the calendar's timezone
+func (c *CalendarDataSource) addHolidayDates() error {
+	for _, holiday := range c.HolidayDates {
+		day, err := time.ParseInLocation(config.CompanyDayDateFormat, holiday.Date, c.CalTimezone)
+		if err != nil {
+			return fmt.Errorf("holiday %q: %s", holiday.Name, err.Error())
+		}
+		span := timespan.New(day, day.AddDate(0, 0, 1))
+		if !span.Overlaps(c.CalTotalSpan) {
+			continue
+		}
+		log.Debugf("Adding holiday %s on %s", holiday.Name, holiday.Date)
+		c.addEvent(span, fmt.Sprintf("holiday date %q", holiday.Name))
+	}
+	return nil
+}
+
+// calendarPath returns where the iCal parser reads the calendar from. It downloads
+// http(s) URLs and reads anything else from disk, so file:// URLs are turned in to paths
+func calendarPath(calendarURL string) string {
+	return strings.TrimPrefix(calendarURL, "file://")
+}
+
 // filterEvent compares the given event name against the whitelist of events
 // specified in the config.
 // returns true if it's whitelisted, false if it should be ignored
 func (c *CalendarDataSource) filterEvent(eventName string) bool {
-	for _, h := range config.GlobalConfig.Holidays {
+	for _, h := range c.Holidays {
 		if eventName == h {
 			return true
 		}
@@ -109,6 +230,12 @@ func (c *CalendarDataSource) filterEvent(eventName string) bool {
 	return false
 }
 
+// addEvent adds the span of a holiday to the calendar, remembering where it comes from
+func (c *CalendarDataSource) addEvent(span timespan.Span, source string) {
+	c.addSpan(span)
+	c.events = append(c.events, CompanyDayEvent{Span: span, Name: source})
+}
+
 // addSpan adds the span to the calendar slice of spans
 //
 // If the new span overlaps with any existing span in the calendar
@@ -1,128 +1,127 @@
 package datasources
 
 import (
+	"context"
+	"fmt"
 	"time"
 
 	"github.com/leosunmo/pagertally/pkg/config"
 	"github.com/leosunmo/pagertally/pkg/timespan"
-	timerange "github.com/leosunmo/timerange-go"
 )
 
-// WeekendDataSource is a datasource for generic after-hours and weekend
-// spans.
+// WeekendDataSource is a datasource for the weekends of a schedule's business hours
 type WeekendDataSource struct {
-	WeekendSpans []timespan.Span
+	Schedule config.ScheduleConfig
 }
 
-// AfterHoursDataSource is a datasource for generic after-hours and weekend
-// spans.
+// AfterHoursDataSource is a datasource for the after hours of a schedule's business hours
 type AfterHoursDataSource struct {
-	AfterHoursSpans []timespan.Span
+	Schedule config.ScheduleConfig
 }
 
-// NewWeekendDataSource returns a DataSource with weekend spans
-func NewWeekendDataSource() WeekendDataSource {
+// NewWeekendDataSourceFor returns a DataSource with weekend spans for the schedule config sc
+func NewWeekendDataSourceFor(sc config.ScheduleConfig) WeekendDataSource {
+	return WeekendDataSource{Schedule: sc}
+}
 
-	wds := WeekendDataSource{
-		WeekendSpans: []timespan.Span{},
-	}
-	// Create and iterate of a timerange of the entire (usually month-long) schedule that we are processing
-	tr := timerange.New(config.GlobalConfig.ScheduleSpan.Start(), config.GlobalConfig.ScheduleSpan.End(), time.Hour*24)
-	for tr.Next() {
-		wds.attributeWeekends(tr.Current())
+// NewAfterHoursDataSourceFor returns a DataSource with afterhours spans for the schedule config sc
+func NewAfterHoursDataSourceFor(sc config.ScheduleConfig) AfterHoursDataSource {
+	return AfterHoursDataSource{Schedule: sc}
+}
+
+// localDays returns the start of every day in loc that overlaps span. The end of span is exclusive,
+// so a span ending at midnight doesn't include the day starting then
+func localDays(span timespan.Span, loc *time.Location) []time.Time {
+	start := span.Start().In(loc)
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	days := []time.Time{}
+	for ; day.Before(span.End()); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
 	}
-	wds.WeekendSpans = timespan.MergeSpans(wds.WeekendSpans)
+	return days
+}
 
-	return wds
+// Spans returns weekend attributed spans of every day of span in loc
+func (wds WeekendDataSource) Spans(ctx context.Context, span timespan.Span, loc *time.Location) ([]timespan.Span, error) {
+	return businessHoursSpans(wds.Schedule, span, loc, weekendSpans)
 }
 
-// NewAfterHoursDataSource returns a DataSource with afterhours spans
-func NewAfterHoursDataSource() AfterHoursDataSource {
-	ahds := AfterHoursDataSource{
-		AfterHoursSpans: []timespan.Span{},
-	}
-	// Create and iterate of a timerange of the entire (usually month-long) schedule that we are processing
-	tr := timerange.New(config.GlobalConfig.ScheduleSpan.Start(), config.GlobalConfig.ScheduleSpan.End(), time.Hour*24)
-	for tr.Next() {
-		ahds.attributeAfterHours(tr.Current())
-	}
-	ahds.AfterHoursSpans = timespan.MergeSpans(ahds.Spans())
-	return ahds
+// Spans returns out of business hours attributed spans of every day of span in loc
+func (ahds AfterHoursDataSource) Spans(ctx context.Context, span timespan.Span, loc *time.Location) ([]timespan.Span, error) {
+	return businessHoursSpans(ahds.Schedule, span, loc, afterHoursSpans)
 }
 
-// Spans returns weekend attributed spans
-func (wds WeekendDataSource) Spans() []timespan.Span {
-	return wds.WeekendSpans
+// businessHoursSpans returns the merged spans daySpans returns for every day of span in loc,
+// given the business hours of the schedule config sc
+func businessHoursSpans(sc config.ScheduleConfig, span timespan.Span, loc *time.Location, daySpans func(day time.Time, week map[time.Weekday]config.DayHours) ([]timespan.Span, error)) ([]timespan.Span, error) {
+	week, err := sc.BusinessHours.WeekTemplate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse business hours, err: %s", err.Error())
+	}
+	spans := []timespan.Span{}
+	for _, day := range localDays(span, loc) {
+		found, derr := daySpans(day, week)
+		if derr != nil {
+			return nil, derr
+		}
+		spans = append(spans, found...)
+	}
+	return timespan.MergeSpans(spans), nil
 }
 
-// Spans returns out of business hours attributed spans
-func (ahds AfterHoursDataSource) Spans() []timespan.Span {
-	return ahds.AfterHoursSpans
+// openOn returns true if the business is open on the weekday of day in the business week
+func openOn(week map[time.Weekday]config.DayHours, day time.Time) bool {
+	return !week[day.Weekday()].Closed
 }
 
-func (wds *WeekendDataSource) attributeWeekends(day time.Time) {
+// weekendSpans returns the weekend spans of day. Days the business is closed are weekend, and so is the
+// time before opening after a closed day and the time after closing before a closed day,
+// so with the default week the weekend lasts from Friday close of business (COB) to Monday opening of business (OOB)
+func weekendSpans(day time.Time, week map[time.Weekday]config.DayHours) ([]timespan.Span, error) {
+	if !openOn(week, day) {
+		// 24 hour weekend spans for closed days
+		return []timespan.Span{timespan.New(day, day.AddDate(0, 0, 1))}, nil
+	}
 
 	// Get configured business open hours
-	bStart, bEnd := config.BusinessHoursForDate(day)
-
-	switch day.Weekday() {
-	case time.Saturday, time.Sunday:
-		// Add 24 hour weekend spans for Saturday and Sunday
-		startTime := day
-		//endTime := timespan.EndOfDay(day)
-		endTime := day.AddDate(0, 0, 1)
-		span := timespan.New(startTime, endTime)
-		wds.WeekendSpans = append(wds.WeekendSpans, span)
-	case time.Friday:
-		// If it's Friday we add a weekend span from close of business (COB) to 23:59
-		startTime := time.Date(day.Year(), day.Month(), day.Day(), bEnd.Hour(), bEnd.Minute(), bEnd.Second(), bEnd.Nanosecond(), day.Location())
-		//endTime := timespan.EndOfDay(day)
-		endTime := day.AddDate(0, 0, 1)
-		span := timespan.New(startTime, endTime)
-
-		wds.WeekendSpans = append(wds.WeekendSpans, span)
-	case time.Monday:
-		// If it's Monday we add a weekend span from 00:00 to opening of business (OOB)
-		startTime := day
-		endTime := time.Date(day.Year(), day.Month(), day.Day(), bStart.Hour(), bStart.Minute(), bStart.Second(), bStart.Nanosecond(), day.Location())
-		span := timespan.New(startTime, endTime)
-		wds.WeekendSpans = append(wds.WeekendSpans, span)
+	bStart, bEnd, err := week[day.Weekday()].On(day, day.Location())
+	if err != nil {
+		return nil, err
+	}
+
+	spans := []timespan.Span{}
+	if !openOn(week, day.AddDate(0, 0, -1)) {
+		// If yesterday was closed we add a weekend span from 00:00 to OOB
+		spans = append(spans, timespan.New(day, bStart))
+	}
+	if !openOn(week, day.AddDate(0, 0, 1)) {
+		// If tomorrow is closed we add a weekend span from COB to midnight
+		spans = append(spans, timespan.New(bEnd, day.AddDate(0, 0, 1)))
 	}
+	return spans, nil
 }
 
-func (ahds *AfterHoursDataSource) attributeAfterHours(day time.Time) {
+// afterHoursSpans returns the after hours spans of day, the time outside business hours
+// on days the business is open that isn't weekend, see weekendSpans
+func afterHoursSpans(day time.Time, week map[time.Weekday]config.DayHours) ([]timespan.Span, error) {
+	if !openOn(week, day) {
+		return nil, nil
+	}
+
 	// Get configured business open hours
-	bStart, bEnd := config.BusinessHoursForDate(day)
-
-	switch day.Weekday() {
-	case time.Friday:
-		// If it's Friday, add only a monrning AfterHours span since the time after COB is weekend.
-		startTime := day
-		endTime := time.Date(day.Year(), day.Month(), day.Day(), bStart.Hour(), bStart.Minute(), bStart.Second(), bStart.Nanosecond(), day.Location())
-		span := timespan.New(startTime, endTime)
-		ahds.AfterHoursSpans = append(ahds.AfterHoursSpans, span)
-	case time.Monday:
-		// If it's Monday, add only an evening AfterHours span since the time before OOB is weekend.
-		startTime := time.Date(day.Year(), day.Month(), day.Day(), bEnd.Hour(), bEnd.Minute(), bEnd.Second(), bEnd.Nanosecond(), day.Location())
-		//endTime := timespan.EndOfDay(day)
-		endTime := day.AddDate(0, 0, 1)
-		span := timespan.New(startTime, endTime)
-		ahds.AfterHoursSpans = append(ahds.AfterHoursSpans, span)
-	case time.Tuesday, time.Wednesday, time.Thursday:
-		// If it's not Sunday or Saturday (and not Friday since we caught that above) we add morning and evening AfterHours spans
-
-		// Morning span
-		startTime := day
-		endTime := time.Date(day.Year(), day.Month(), day.Day(), bStart.Hour(), bStart.Minute(), bStart.Second(), bStart.Nanosecond(), day.Location())
-		span := timespan.New(startTime, endTime)
-		ahds.AfterHoursSpans = append(ahds.AfterHoursSpans, span)
-
-		// Afternoon span
-		startTime = time.Date(day.Year(), day.Month(), day.Day(), bEnd.Hour(), bEnd.Minute(), bEnd.Second(), bEnd.Nanosecond(), day.Location())
-		//endTime = timespan.EndOfDay(day)
-		endTime = day.AddDate(0, 0, 1)
-		span = timespan.New(startTime, endTime)
-		ahds.AfterHoursSpans = append(ahds.AfterHoursSpans, span)
+	bStart, bEnd, err := week[day.Weekday()].On(day, day.Location())
+	if err != nil {
+		return nil, err
+	}
 
+	spans := []timespan.Span{}
+	if openOn(week, day.AddDate(0, 0, -1)) {
+		// Morning span, unless it's the weekend until OOB
+		spans = append(spans, timespan.New(day, bStart))
+	}
+	if openOn(week, day.AddDate(0, 0, 1)) {
+		// Evening span, unless the weekend starts at COB
+		spans = append(spans, timespan.New(bEnd, day.AddDate(0, 0, 1)))
 	}
+	return spans, nil
 }
@@ -1,48 +1,271 @@
 package datasources
 
 import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/leosunmo/pagertally/pkg/config"
 	"github.com/leosunmo/pagertally/pkg/timespan"
-	log "github.com/sirupsen/logrus"
 )
 
+// dayMonthFormat is the format of the dates of recurring company days, CompanyDayDateFormat without the year
+const dayMonthFormat = "02/01"
+
 // CompanyDaysDataSource is a datasource for company days
 type CompanyDaysDataSource struct {
-	CompanyDays []timespan.Span
+	CompanyDays []config.CompanyDay
+	// Timezone is the timezone the company days last whole days in
+	Timezone *time.Location
 }
 
-// NewCompanyDayDataSource returns a new company day datasource
-func NewCompanyDayDataSource() CompanyDaysDataSource {
-	vd := CompanyDaysDataSource{}
-
-	vd.readCompanyDaysFromConfig()
+// CompanyDayEvent is a single day of a named company day
+type CompanyDayEvent struct {
+	timespan.Span
+	Name string
+}
 
-	return vd
+// NewCompanyDayDataSourceFor returns a new company day datasource for the schedule config sc,
+// or an error if the schedule's timezone can't be loaded
+func NewCompanyDayDataSourceFor(sc config.ScheduleConfig) (CompanyDaysDataSource, error) {
+	loc, err := sc.Location()
+	if err != nil {
+		return CompanyDaysDataSource{}, err
+	}
+	return CompanyDaysDataSource{CompanyDays: sc.CompanyDayEntries(), Timezone: loc}, nil
 }
 
-// readCompanyDaysFromConfig parses the company days from config in to time.Time and creates timespans
-func (vd *CompanyDaysDataSource) readCompanyDaysFromConfig() {
+// companyDays returns the spans of the company days and the days of the named ones, which may overlap each other
+// unlike the spans. Recurring company days are created for every year of span and the year before,
+// so ranges over new year are included
+func (vd CompanyDaysDataSource) companyDays(span timespan.Span) ([]timespan.Span, []CompanyDayEvent, error) {
 	spans := []timespan.Span{}
-	tz, err := time.LoadLocation(config.GlobalConfig.Timezone)
+	events := []CompanyDayEvent{}
+	firstYear := span.Start().In(vd.Timezone).Year() - 1
+	lastYear := span.End().In(vd.Timezone).Year()
+	for _, companyDay := range vd.CompanyDays {
+		days, derr := companyDayDates(companyDay.Date, firstYear, lastYear, vd.Timezone)
+		if derr != nil {
+			return nil, nil, fmt.Errorf("failed to parse company day %q, err: %s", companyDay.Date, derr.Error())
+		}
+		for _, day := range days {
+			daySpan, serr := companyDaySpan(day, companyDay.Start, companyDay.End)
+			if serr != nil {
+				return nil, nil, fmt.Errorf("failed to parse times of company day %q, err: %s", companyDay.Date, serr.Error())
+			}
+			// Company days configured more than once are only counted once
+			spans = append(spans, timespan.Subtract([]timespan.Span{daySpan}, spans)...)
+			if companyDay.Name != "" {
+				events = append(events, CompanyDayEvent{Span: daySpan, Name: companyDay.Name})
+			}
+		}
+	}
+	sort.Sort(timespan.Spans(spans))
+	return spans, events, nil
+}
+
+// Spans returns the timespans of the company days around span. Company days last whole days
+// in the company day timezone, whatever timezone loc is
+func (vd CompanyDaysDataSource) Spans(ctx context.Context, span timespan.Span, loc *time.Location) ([]timespan.Span, error) {
+	spans, _, err := vd.companyDays(span)
+	return spans, err
+}
+
+// SpanName returns the names of the named company days during span, empty if they can't be parsed
+func (vd CompanyDaysDataSource) SpanName(span timespan.Span) string {
+	_, events, err := vd.companyDays(span)
 	if err != nil {
-		log.Fatalf("datasources/company_days: failed to parse timezone from global config, err: %s", err.Error())
+		return ""
 	}
-	for _, day := range config.GlobalConfig.CompanyDays {
-		parsedDay, terr := time.ParseInLocation(config.CompanyDayDateFormat, day, tz)
-		if terr != nil {
-			log.Fatalf("datasources/company_days: failed to parse company day datetime, err: %s", terr.Error())
+	names := []string{}
+	seen := map[string]bool{}
+	for _, event := range events {
+		if event.Overlaps(span) && !seen[event.Name] {
+			seen[event.Name] = true
+			names = append(names, event.Name)
 		}
-		// We currently ignore any date restrictions and just create whatever is configured.
-		// Ideally we wouldn't use absolute dates anyway so this will do for now.
-		span := timespan.New(parsedDay, parsedDay.AddDate(0, 0, 1))
-		spans = append(spans, span)
 	}
-	vd.CompanyDays = spans
+	return strings.Join(names, ", ")
 }
 
-// Spans returns the timespans from the CompanyDays source
-func (vd CompanyDaysDataSource) Spans() []timespan.Span {
-	return vd.CompanyDays
+// companyDaySpan returns the span of the company day on day, from start until end.
+// Company days without a start or end last from or until midnight
+func companyDaySpan(day time.Time, start, end string) (timespan.Span, error) {
+	from := day
+	until := day.AddDate(0, 0, 1)
+	if start != "" {
+		t, err := time.Parse("15:04", start)
+		if err != nil {
+			return timespan.Span{}, err
+		}
+		from = time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location())
+	}
+	if end != "" {
+		t, err := time.Parse("15:04", end)
+		if err != nil {
+			return timespan.Span{}, err
+		}
+		until = time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location())
+	}
+	if !until.After(from) {
+		return timespan.Span{}, fmt.Errorf("end %s isn't after start %s", end, start)
+	}
+	return timespan.New(from, until), nil
+}
+
+// companyDayDates returns the days of a company day in loc. expr is one of:
+// - a date in config.CompanyDayDateFormat, "24/12/2019"
+// - a range of dates, "24/12/2019 - 02/01/2020" or "between 24/12/2019 and 02/01/2020"
+// - a yearly date, "every 24/12"
+// - a yearly range of dates, "24/12 - 02/01" or "between 24/12 and 02/01"
+// - a weekday every week, "every Friday"
+// - the nth weekday of each month, quarter or year, or of a month, "last Friday of each quarter" or "first Monday of June"
+// Recurring company days are returned for the years from firstYear to lastYear
+func companyDayDates(expr string, firstYear, lastYear int, loc *time.Location) ([]time.Time, error) {
+	fields := strings.Fields(strings.ToLower(expr))
+	switch {
+	case len(fields) == 4 && fields[0] == "between" && fields[2] == "and":
+		return dateRange(fields[1], fields[3], firstYear, lastYear, loc)
+	case len(fields) == 3 && fields[1] == "-":
+		return dateRange(fields[0], fields[2], firstYear, lastYear, loc)
+	case len(fields) == 2 && fields[0] == "every":
+		if weekday, isWeekday := weekdays[fields[1]]; isWeekday {
+			return everyWeekday(weekday, firstYear, lastYear, loc), nil
+		}
+		return dateRange(fields[1], fields[1], firstYear, lastYear, loc)
+	case len(fields) >= 4 && fields[2] == "of":
+		return nthWeekdays(fields, firstYear, lastYear, loc)
+	case len(fields) == 1:
+		day, err := time.ParseInLocation(config.CompanyDayDateFormat, fields[0], loc)
+		if err != nil {
+			return nil, err
+		}
+		return []time.Time{day}, nil
+	}
+	return nil, fmt.Errorf("unknown company day format, use a date, a range of dates or a recurrence like \"every 24/12\"")
+}
+
+// dateRange returns the days from start until end inclusive. If both are in dayMonthFormat
+// the range recurs every year, continuing into the next year if end is before start
+func dateRange(start, end string, firstYear, lastYear int, loc *time.Location) ([]time.Time, error) {
+	from, ferr := time.ParseInLocation(config.CompanyDayDateFormat, start, loc)
+	until, uerr := time.ParseInLocation(config.CompanyDayDateFormat, end, loc)
+	if ferr == nil && uerr == nil {
+		if until.Before(from) {
+			return nil, fmt.Errorf("%s is before %s", end, start)
+		}
+		return daysBetween(from, until), nil
+	}
+	from, ferr = time.ParseInLocation(dayMonthFormat, start, loc)
+	if ferr != nil {
+		return nil, ferr
+	}
+	until, uerr = time.ParseInLocation(dayMonthFormat, end, loc)
+	if uerr != nil {
+		return nil, uerr
+	}
+	days := []time.Time{}
+	for year := firstYear; year <= lastYear; year++ {
+		yearFrom := time.Date(year, from.Month(), from.Day(), 0, 0, 0, 0, loc)
+		// Leave out the 29th of February when it's not a leap year
+		if from.Equal(until) && yearFrom.Month() != from.Month() {
+			continue
+		}
+		untilYear := year
+		if until.Before(from) {
+			untilYear++
+		}
+		yearUntil := time.Date(untilYear, until.Month(), until.Day(), 0, 0, 0, 0, loc)
+		// Ranges until the 29th of February end on the 28th when it's not a leap year
+		if yearUntil.Month() != until.Month() {
+			yearUntil = yearUntil.AddDate(0, 0, -yearUntil.Day())
+		}
+		days = append(days, daysBetween(yearFrom, yearUntil)...)
+	}
+	return days, nil
+}
+
+// daysBetween returns every day from start until end inclusive
+func daysBetween(start, end time.Time) []time.Time {
+	days := []time.Time{}
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	return days
+}
+
+// everyWeekday returns every weekday from firstYear until lastYear
+func everyWeekday(weekday time.Weekday, firstYear, lastYear int, loc *time.Location) []time.Time {
+	days := []time.Time{}
+	for _, day := range daysBetween(time.Date(firstYear, time.January, 1, 0, 0, 0, 0, loc), time.Date(lastYear, time.December, 31, 0, 0, 0, 0, loc)) {
+		if day.Weekday() == weekday {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// nthWeekdays returns the days of "<nth> <weekday> of each <month|quarter|year>" or "<nth> <weekday> of <month>"
+func nthWeekdays(fields []string, firstYear, lastYear int, loc *time.Location) ([]time.Time, error) {
+	nth, isOrdinal := ordinals[fields[0]]
+	if !isOrdinal {
+		return nil, fmt.Errorf("unknown ordinal %q, use first, second, third, fourth or last", fields[0])
+	}
+	weekday, isWeekday := weekdays[fields[1]]
+	if !isWeekday {
+		return nil, fmt.Errorf("unknown weekday %q", fields[1])
+	}
+	period := fields[3]
+	if len(fields) == 5 && (fields[3] == "each" || fields[3] == "every") {
+		period = fields[4]
+	} else if len(fields) != 4 {
+		return nil, fmt.Errorf("unknown period %q, use each month, quarter or year", strings.Join(fields[3:], " "))
+	}
+	var months []time.Month
+	switch period {
+	case "month":
+		months = []time.Month{time.January, time.February, time.March, time.April, time.May, time.June,
+			time.July, time.August, time.September, time.October, time.November, time.December}
+	case "quarter":
+		// The first weekday of a quarter is in its first month, the last in its last month
+		months = []time.Month{time.January, time.April, time.July, time.October}
+		if nth < 0 {
+			months = []time.Month{time.March, time.June, time.September, time.December}
+		}
+	case "year":
+		months = []time.Month{time.January}
+		if nth < 0 {
+			months = []time.Month{time.December}
+		}
+	default:
+		month, err := time.Parse("January", strings.Title(period))
+		if err != nil {
+			return nil, fmt.Errorf("unknown period %q, use each month, quarter or year, or a month", period)
+		}
+		months = []time.Month{month.Month()}
+	}
+	days := []time.Time{}
+	for year := firstYear; year <= lastYear; year++ {
+		for _, month := range months {
+			day, _ := HolidayRule{Month: month, Weekday: weekday, Nth: nth}.Date(year, loc)
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+// ordinals are the ordinals allowed in recurring company days, with the last counting back from the end
+var ordinals = map[string]int{"first": 1, "second": 2, "third": 3, "fourth": 4, "last": -1}
+
+// weekdays are the weekdays by lower case name
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
 }
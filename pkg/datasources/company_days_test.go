@@ -0,0 +1,100 @@
+package datasources
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/config"
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+func TestCompanyDayDates(t *testing.T) {
+	tests := []struct {
+		expr  string
+		dates []string
+	}{
+		{"24/12/2019", []string{"2019-12-24"}},
+		{"30/12/2019 - 02/01/2020", []string{"2019-12-30", "2019-12-31", "2020-01-01", "2020-01-02"}},
+		{"every 24/12", []string{"2019-12-24", "2020-12-24"}},
+		{"every 29/02", []string{"2020-02-29"}},
+		{"between 31/12 and 01/01", []string{"2019-12-31", "2020-01-01", "2020-12-31", "2021-01-01"}},
+		{"28/02 - 29/02", []string{"2019-02-28", "2020-02-28", "2020-02-29"}},
+		{"between 27/02 and 29/02", []string{"2019-02-27", "2019-02-28", "2020-02-27", "2020-02-28", "2020-02-29"}},
+		{"last Friday of each quarter", []string{
+			"2019-03-29", "2019-06-28", "2019-09-27", "2019-12-27",
+			"2020-03-27", "2020-06-26", "2020-09-25", "2020-12-25",
+		}},
+		{"first Monday of June", []string{"2019-06-03", "2020-06-01"}},
+		{"second Tuesday of every year", []string{"2019-01-08", "2020-01-14"}},
+	}
+	for _, test := range tests {
+		days, err := companyDayDates(test.expr, 2019, 2020, time.UTC)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.expr, err.Error())
+			continue
+		}
+		if len(days) != len(test.dates) {
+			t.Errorf("%s: expected %v, got %v", test.expr, test.dates, days)
+			continue
+		}
+		for i, day := range days {
+			if day.Format(dateFormat) != test.dates[i] {
+				t.Errorf("%s: expected %s, got %s", test.expr, test.dates[i], day.Format(dateFormat))
+			}
+		}
+	}
+
+	// Ranges over new year until the 29th of February end on the 28th unless the next year is a leap year
+	for year, last := range map[int]string{2019: "2020-02-29", 2020: "2021-02-28"} {
+		days, err := companyDayDates("01/12 - 29/02", year, year, time.UTC)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if days[len(days)-1].Format(dateFormat) != last {
+			t.Errorf("%d: expected the range to end on %s, got %s", year, last, days[len(days)-1].Format(dateFormat))
+		}
+	}
+
+	for _, expr := range []string{"every 32/12", "someday", "last Funday of each month", "24/12/2019 - 23/12/2019"} {
+		if _, err := companyDayDates(expr, 2019, 2020, time.UTC); err == nil {
+			t.Errorf("%s: expected an error", expr)
+		}
+	}
+}
+
+func TestNamedCompanyDaySpans(t *testing.T) {
+	start := time.Date(2019, time.December, 1, 0, 0, 0, 0, aklTz)
+	end := time.Date(2019, time.December, 31, 0, 0, 0, 0, aklTz)
+
+	vds := mustCompanyDays(t, config.ScheduleConfig{
+		ScheduleSpan: timespan.New(start, end),
+		Timezone:     "Pacific/Auckland",
+		CompanyDays:  []string{"every 27/12"},
+		NamedCompanyDays: []config.CompanyDay{
+			{Name: "Christmas Eve", Date: "every 24/12", Start: "12:00"},
+			{Name: "Summer shutdown", Date: "between 27/12 and 31/12"},
+		},
+	})
+
+	// Two Christmas Eves and the 27th to the 31st of December in 2018 and 2019
+	if len(mustSpans(t, vds, timespan.New(start, end), aklTz)) != 12 {
+		t.Errorf("Should have 12 company day spans, got %d", len(mustSpans(t, vds, timespan.New(start, end), aklTz)))
+	}
+	eve := timespan.New(time.Date(2019, time.December, 24, 12, 0, 0, 0, aklTz), time.Date(2019, time.December, 25, 0, 0, 0, 0, aklTz))
+	found := false
+	for _, span := range mustSpans(t, vds, timespan.New(start, end), aklTz) {
+		if span.Equal(eve) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected Christmas Eve from noon, got %v", mustSpans(t, vds, timespan.New(start, end), aklTz))
+	}
+	if name := vds.SpanName(eve); name != "Christmas Eve" {
+		t.Errorf("Expected the span to be named Christmas Eve, got %q", name)
+	}
+	shutdown := timespan.New(time.Date(2019, time.December, 27, 9, 0, 0, 0, aklTz), time.Date(2019, time.December, 27, 10, 0, 0, 0, aklTz))
+	if name := vds.SpanName(shutdown); name != "Summer shutdown" {
+		t.Errorf("Expected the span to be named Summer shutdown, got %q", name)
+	}
+}
@@ -1,10 +1,229 @@
 package datasources
 
 import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/config"
 	"github.com/leosunmo/pagertally/pkg/timespan"
 )
 
-// DataSource returns a slice of Spans from it's external datasource such as a calendar of stat days
+// DataSource returns a slice of Spans from it's external datasource such as a calendar of stat days,
+// for the reporting span in the timezone loc. Spans may reach outside the reporting span.
+// Datasources calling out to other services or programs stop once ctx is done
 type DataSource interface {
-	Spans() []timespan.Span
+	Spans(ctx context.Context, span timespan.Span, loc *time.Location) ([]timespan.Span, error)
+}
+
+// NamedDataSource is a DataSource with named spans, like named company days
+type NamedDataSource interface {
+	DataSource
+	// SpanName returns the names of the spans during span, empty if they have no name
+	SpanName(span timespan.Span) string
+}
+
+// AttributeDataSource is a named DataSource attributing its spans with Attribute, taking precedence over Before
+type AttributeDataSource struct {
+	DataSource
+	Name      string
+	Attribute timespan.OnCallAttribute
+	Before    timespan.OnCallAttribute
+}
+
+// ScheduleDataSources are the datasources used to attribute a single schedule's shifts
+type ScheduleDataSources struct {
+	// Sources are the datasources of every attribute but business hours, in order of precedence
+	Sources []AttributeDataSource
+	// Period is the reporting span the sources are asked for
+	Period timespan.Span
+	// Location is the schedule's timezone, used for users without one
+	Location *time.Location
+	// Stacking attributes time with every attribute that applies to it, not just the first
+	Stacking bool
+	// Attributes are where the attributes of the schedule's time bands were registered
+	Attributes *timespan.Attributes
+}
+
+// TimezoneFor returns the timezone time is attributed in for a user in loc, the schedule's timezone if loc is nil
+func (ds ScheduleDataSources) TimezoneFor(loc *time.Location) *time.Location {
+	if loc == nil {
+		return ds.Location
+	}
+	return loc
+}
+
+// BuiltInSources returns the datasources of the built in attributes but business hours in their default order of precedence:
+// company days, stat holidays, weekends and after hours
+func BuiltInSources(companyDay, calendar, weekend, afterHours DataSource) []AttributeDataSource {
+	return []AttributeDataSource{
+		{DataSource: companyDay, Name: "company_days", Attribute: timespan.CompanyDay},
+		{DataSource: calendar, Name: "calendar", Attribute: timespan.StatHoliday},
+		{DataSource: weekend, Name: "weekends", Attribute: timespan.Weekend},
+		{DataSource: afterHours, Name: "after_hours", Attribute: timespan.AfterHours},
+	}
+}
+
+// Ordered returns sources with the time bands inserted before the built in attribute they take precedence over,
+// and then sorted by precedence. Attributes that aren't in precedence follow the ones that are, in their existing order
+func Ordered(sources []AttributeDataSource, timeBands []AttributeDataSource, precedence []timespan.OnCallAttribute) []AttributeDataSource {
+	ordered := append([]AttributeDataSource{}, sources...)
+	for _, band := range timeBands {
+		ordered = insertBefore(ordered, band)
+	}
+	rank := map[timespan.OnCallAttribute]int{}
+	for i, attr := range precedence {
+		rank[attr] = i + 1
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		rankI, rankJ := rank[ordered[i].Attribute], rank[ordered[j].Attribute]
+		return rankI != 0 && (rankJ == 0 || rankI < rankJ)
+	})
+	return ordered
+}
+
+// insertBefore returns sources with band inserted before the built in attribute it takes precedence over,
+// or before all of them if it doesn't name one. Bands taking precedence over business hours go last
+func insertBefore(sources []AttributeDataSource, band AttributeDataSource) []AttributeDataSource {
+	i := len(sources)
+	for j, source := range sources {
+		if source.Attribute.IsBuiltIn() && (band.Before == timespan.Unknown || source.Attribute == band.Before) {
+			i = j
+			break
+		}
+	}
+	return append(sources[:i], append([]AttributeDataSource{band}, sources[i:]...)...)
+}
+
+// WithConfigured returns sources with every configured datasource inserted after the last of sources
+// with the same attribute, so they share its precedence. Any others go last
+func WithConfigured(sources []AttributeDataSource, configured []AttributeDataSource) []AttributeDataSource {
+	ordered := append([]AttributeDataSource{}, sources...)
+	for _, source := range configured {
+		i := len(ordered)
+		for j := len(ordered) - 1; j >= 0; j-- {
+			if ordered[j].Attribute == source.Attribute {
+				i = j + 1
+				break
+			}
+		}
+		ordered = append(ordered[:i], append([]AttributeDataSource{source}, ordered[i:]...)...)
+	}
+	return ordered
+}
+
+// NewScheduleDataSources returns all datasources for the schedule config sc, the built in ones,
+// the time bands and the datasources in the "datasources" config, each only asked once for its spans
+// per timezone
+func NewScheduleDataSources(sc config.ScheduleConfig) (ScheduleDataSources, error) {
+	loc, err := sc.Location()
+	if err != nil {
+		return ScheduleDataSources{}, err
+	}
+	companyDays, err := NewCompanyDayDataSourceFor(sc)
+	if err != nil {
+		return ScheduleDataSources{}, err
+	}
+	calendar, err := NewCalendarDataSourceFor(sc)
+	if err != nil {
+		return ScheduleDataSources{}, err
+	}
+	builtIns := BuiltInSources(companyDays, calendar, NewWeekendDataSourceFor(sc), NewAfterHoursDataSourceFor(sc))
+	configured, err := NewConfigured(sc)
+	if err != nil {
+		return ScheduleDataSources{}, err
+	}
+	sources := WithConfigured(Ordered(builtIns, NewTimeBands(sc), sc.Precedence), configured)
+	for i := range sources {
+		sources[i].DataSource = Cached(sources[i].DataSource)
+	}
+	return ScheduleDataSources{
+		Sources:    sources,
+		Period:     sc.ScheduleSpan,
+		Location:   loc,
+		Stacking:   sc.Stacking,
+		Attributes: sc.Attributes,
+	}, nil
+}
+
+// PerSchedule returns the datasources of a PagerDuty schedule by ID. Schedules without
+// their own config in profiles, by lower case schedule ID, share the datasources built from global
+func PerSchedule(global config.ScheduleConfig, profiles map[string]config.ScheduleConfig) func(scheduleID string) (ScheduleDataSources, error) {
+	created := map[string]ScheduleDataSources{}
+	return func(scheduleID string) (ScheduleDataSources, error) {
+		key := strings.ToLower(scheduleID)
+		sc, exists := profiles[key]
+		if !exists {
+			key = ""
+			sc = global
+		}
+		if ds, exists := created[key]; exists {
+			return ds, nil
+		}
+		ds, err := NewScheduleDataSources(sc)
+		if err != nil {
+			return ScheduleDataSources{}, err
+		}
+		created[key] = ds
+		return ds, nil
+	}
+}
+
+// cachedDataSource is a DataSource remembering the spans of every reporting span and timezone it's been asked for
+type cachedDataSource struct {
+	DataSource
+	mu    sync.Mutex
+	spans map[spansKey][]timespan.Span
+	// anyLocation is set for datasources with the same spans whatever timezone they're asked for in
+	anyLocation bool
+}
+
+// spansKey is a reporting span in a timezone, or in any timezone if loc is empty
+type spansKey struct {
+	start, end int64
+	loc        string
+}
+
+// Cached returns a DataSource that asks ds for the spans of each reporting span and timezone only once,
+// as datasources like calendars and commands are slow
+func Cached(ds DataSource) DataSource {
+	if _, isCached := ds.(*cachedDataSource); isCached {
+		return ds
+	}
+	cached := &cachedDataSource{DataSource: ds, spans: map[spansKey][]timespan.Span{}}
+	switch ds.(type) {
+	case CalendarDataSource, CompanyDaysDataSource:
+		// Holidays and company days last whole days in their own timezone
+		cached.anyLocation = true
+	}
+	return cached
+}
+
+// Spans returns the spans of the underlying DataSource, asking it only until it succeeds
+func (c *cachedDataSource) Spans(ctx context.Context, span timespan.Span, loc *time.Location) ([]timespan.Span, error) {
+	key := spansKey{start: span.Start().UnixNano(), end: span.End().UnixNano()}
+	if !c.anyLocation {
+		key.loc = loc.String()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if spans, exists := c.spans[key]; exists {
+		return spans, nil
+	}
+	spans, err := c.DataSource.Spans(ctx, span, loc)
+	if err != nil {
+		return nil, err
+	}
+	c.spans[key] = spans
+	return spans, nil
+}
+
+// SpanName returns the names of the spans during span if the underlying DataSource is a NamedDataSource
+func (c *cachedDataSource) SpanName(span timespan.Span) string {
+	if named, isNamed := c.DataSource.(NamedDataSource); isNamed {
+		return named.SpanName(span)
+	}
+	return ""
 }
@@ -1,8 +1,12 @@
 package datasources
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -20,6 +24,36 @@ const (
 var aklTz, _ = time.LoadLocation("Pacific/Auckland")
 var nyTz, _ = time.LoadLocation("America/New_York")
 
+// mustSpans returns the spans of ds during span in the timezone loc, failing the test if there's an error
+func mustSpans(t *testing.T, ds DataSource, span timespan.Span, loc *time.Location) []timespan.Span {
+	t.Helper()
+	spans, err := ds.Spans(context.Background(), span, loc)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	return spans
+}
+
+// mustCalendar returns the calendar datasource of sc, failing the test if there's an error
+func mustCalendar(t *testing.T, sc config.ScheduleConfig) CalendarDataSource {
+	t.Helper()
+	cal, err := NewCalendarDataSourceFor(sc)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	return cal
+}
+
+// mustCompanyDays returns the company day datasource of sc, failing the test if there's an error
+func mustCompanyDays(t *testing.T, sc config.ScheduleConfig) CompanyDaysDataSource {
+	t.Helper()
+	vds, err := NewCompanyDayDataSourceFor(sc)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	return vds
+}
+
 func TestCompanyDaySpans(t *testing.T) {
 	var err error
 	start, err := time.ParseInLocation(timeFormat, firstJan, time.UTC)
@@ -37,10 +71,10 @@ func TestCompanyDaySpans(t *testing.T) {
 		CompanyDays:  []string{"15/01/2019", "23/01/2019", "31/01/2019", "01/04/2019"},
 	}
 
-	vds := NewCompanyDayDataSource()
+	vds := mustCompanyDays(t, config.GlobalConfig)
 
-	if len(vds.Spans()) != 4 {
-		t.Errorf("Should have 4 company day spans, got %d", len(vds.Spans()))
+	if len(mustSpans(t, vds, config.GlobalConfig.ScheduleSpan, config.Timezone())) != 4 {
+		t.Errorf("Should have 4 company day spans, got %d", len(mustSpans(t, vds, config.GlobalConfig.ScheduleSpan, config.Timezone())))
 	}
 }
 func TestCalendarSpans(t *testing.T) {
@@ -61,10 +95,69 @@ func TestCalendarSpans(t *testing.T) {
 		ScheduleSpan: timespan.New(start, end),
 	}
 
-	cal := NewCalendarDataSource()
+	cal := mustCalendar(t, config.GlobalConfig)
 
-	if len(cal.Spans()) != 2 {
-		t.Errorf("Calendar should contain 2 spans, got %d", len(cal.Spans()))
+	if len(mustSpans(t, cal, config.GlobalConfig.ScheduleSpan, config.Timezone())) != 2 {
+		t.Errorf("Calendar should contain 2 spans, got %d", len(mustSpans(t, cal, config.GlobalConfig.ScheduleSpan, config.Timezone())))
+	}
+}
+
+func TestHolidayDateSpans(t *testing.T) {
+	start := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2019, time.January, 31, 0, 0, 0, 0, time.UTC)
+
+	cal := mustCalendar(t, config.ScheduleConfig{
+		HolidayDates: []config.HolidayDate{
+			{Name: "New Year's Day", Date: "01/01/2019"},
+			{Name: "Wellington Anniversary", Date: "21/01/2019"},
+			{Name: "Waitangi Day", Date: "06/02/2019"},
+		},
+		Timezone:     "Pacific/Auckland",
+		ScheduleSpan: timespan.New(start, end),
+	})
+
+	spans := mustSpans(t, cal, timespan.New(start, end), aklTz)
+	if len(spans) != 2 {
+		t.Fatalf("Calendar should contain 2 spans, got %d", len(spans))
+	}
+	if spans[1].Duration() != 24*time.Hour || spans[1].Start().Day() != 21 {
+		t.Errorf("Expected Wellington Anniversary to last all of the 21st, got %s to %s", spans[1].Start(), spans[1].End())
+	}
+}
+
+func TestCalendarLoadedOnce(t *testing.T) {
+	var downloads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downloads, 1)
+		w.Write([]byte("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:waitangi\r\nDTSTART;VALUE=DATE:20190206\r\n" +
+			"DTEND;VALUE=DATE:20190207\r\nSUMMARY:Waitangi Day\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"))
+	}))
+	defer server.Close()
+	start := time.Date(2019, time.February, 1, 0, 0, 0, 0, aklTz)
+	end := time.Date(2019, time.March, 1, 0, 0, 0, 0, aklTz)
+
+	cal := Cached(mustCalendar(t, config.ScheduleConfig{
+		CalendarURL:  server.URL + "/holidays.ics",
+		Holidays:     []string{"Waitangi Day"},
+		Timezone:     "Pacific/Auckland",
+		ScheduleSpan: timespan.New(start, end),
+	}))
+
+	// Users in every timezone and every explanation share the same calendar
+	for _, loc := range []*time.Location{aklTz, nyTz, time.UTC} {
+		if spans := mustSpans(t, cal, timespan.New(start, end), loc); len(spans) != 1 {
+			t.Fatalf("Calendar should contain 1 span in %s, got %d", loc, len(spans))
+		}
+	}
+	description, err := cal.(DescribedDataSource).Describe(context.Background(), timespan.New(start, end), start.AddDate(0, 0, 5).Add(12*time.Hour), nyTz)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if description != `iCal event "Waitangi Day"` {
+		t.Errorf("Expected Waitangi Day to be described, got %q", description)
+	}
+	if downloaded := atomic.LoadInt32(&downloads); downloaded != 1 {
+		t.Errorf("Expected the calendar to be downloaded once, got %d downloads", downloaded)
 	}
 }
 
@@ -88,9 +181,9 @@ func TestCommonWeekendsSpans(t *testing.T) {
 		Timezone: "Pacific/Auckland",
 	}
 
-	wkds := NewWeekendDataSource()
-	if len(wkds.Spans()) != 4 {
-		t.Errorf("Weekend datasource should contain 4 spans, got %d", len(wkds.Spans()))
+	wkds := NewWeekendDataSourceFor(config.GlobalConfig)
+	if len(mustSpans(t, wkds, config.GlobalConfig.ScheduleSpan, config.Timezone())) != 4 {
+		t.Errorf("Weekend datasource should contain 4 spans, got %d", len(mustSpans(t, wkds, config.GlobalConfig.ScheduleSpan, config.Timezone())))
 	}
 }
 
@@ -113,10 +206,11 @@ func TestCommonAfterhoursSpans(t *testing.T) {
 		},
 	}
 
-	oobds := NewAfterHoursDataSource()
-	spans := oobds.Spans()
-	if len(spans) != 20 {
-		t.Errorf("Expected 20 spans, got %d\nFirst span: %s to %s\nLast span: %s to %s\n", len(spans), spans[0].Start(), spans[0].End(), spans[len(spans)-1].Start(), spans[len(spans)-1].End())
+	oobds := NewAfterHoursDataSourceFor(config.GlobalConfig)
+	spans := mustSpans(t, oobds, config.GlobalConfig.ScheduleSpan, config.Timezone())
+	// The schedule span ends at the start of the 31st, so the 31st itself has no after hours
+	if len(spans) != 19 {
+		t.Errorf("Expected 19 spans, got %d\nFirst span: %s to %s\nLast span: %s to %s\n", len(spans), spans[0].Start(), spans[0].End(), spans[len(spans)-1].Start(), spans[len(spans)-1].End())
 	}
 }
 
@@ -150,8 +244,8 @@ func TestWeekendSpanTimestamps(t *testing.T) {
 		t.Errorf("Time parse error: %s", err.Error())
 	}
 
-	wkds := NewWeekendDataSource()
-	spans := wkds.Spans()
+	wkds := NewWeekendDataSourceFor(config.GlobalConfig)
+	spans := mustSpans(t, wkds, config.GlobalConfig.ScheduleSpan, config.Timezone())
 	for _, span := range spans {
 		switch span.Start().Weekday() {
 		case time.Friday:
@@ -197,8 +291,8 @@ func TestAfterHoursSpanTimestamps(t *testing.T) {
 		t.Errorf("Time parse error: %s", err.Error())
 	}
 
-	ahds := NewAfterHoursDataSource()
-	spans := ahds.Spans()
+	ahds := NewAfterHoursDataSourceFor(config.GlobalConfig)
+	spans := mustSpans(t, ahds, config.GlobalConfig.ScheduleSpan, config.Timezone())
 	firstSpanDone := false
 	if len(spans) != 19 {
 		t.Errorf("Expected 19 spans, got %d\nFirst span: %s to %s\nLast span: %s to %s\n", len(spans), spans[0].Start(), spans[0].End(), spans[len(spans)-1].Start(), spans[len(spans)-1].End())
@@ -262,8 +356,8 @@ func TestCalendarSpanTimestamps(t *testing.T) {
 		t.Errorf("Time parse error: %s", err.Error())
 	}
 
-	cal := NewCalendarDataSource()
-	spans := cal.Spans()
+	cal := mustCalendar(t, config.GlobalConfig)
+	spans := mustSpans(t, cal, config.GlobalConfig.ScheduleSpan, config.Timezone())
 	testDate := time.Time{}
 	for _, span := range spans {
 		switch span.Start().Day() {
@@ -317,8 +411,8 @@ func TestCompanyDaySpansTimestamps(t *testing.T) {
 		CompanyDays:  []string{"15/01/2019", "23/01/2019", "31/01/2019", "01/04/2019"},
 	}
 
-	vds := NewCompanyDayDataSource()
-	spans := vds.Spans()
+	vds := mustCompanyDays(t, config.GlobalConfig)
+	spans := mustSpans(t, vds, config.GlobalConfig.ScheduleSpan, config.Timezone())
 	testDate := time.Time{}
 
 	for _, span := range spans {
@@ -384,9 +478,9 @@ func TestCalendarTimezones(t *testing.T) {
 		ScheduleSpan: timespan.New(start, end),
 	}
 
-	cal := NewCalendarDataSource()
+	cal := mustCalendar(t, config.GlobalConfig)
 
-	for _, span := range cal.Spans() {
+	for _, span := range mustSpans(t, cal, config.GlobalConfig.ScheduleSpan, config.Timezone()) {
 		day := span.Start()
 		spanTz := time.Date(day.Year(), day.Month(), day.Day(), day.Hour(), day.Minute(), day.Second(), day.Nanosecond(), day.Location())
 		testTz := time.Date(day.Year(), day.Month(), day.Day(), day.Hour(), day.Minute(), day.Second(), day.Nanosecond(), nyTz)
@@ -396,3 +490,140 @@ func TestCalendarTimezones(t *testing.T) {
 		}
 	}
 }
+
+func TestWeekendSpansInUserTimezone(t *testing.T) {
+	sydTz, _ := time.LoadLocation("Australia/Sydney")
+	start, _ := time.ParseInLocation(timeFormat, firstJan, aklTz)
+	end, _ := time.ParseInLocation(timeFormat, lastJan, aklTz)
+
+	config.GlobalConfig = config.ScheduleConfig{
+		ScheduleSpan: timespan.New(start, end),
+		BusinessHours: config.BusinessHoursStruct{
+			Start: "08:00",
+			End:   "17:30",
+		},
+		Timezone: "Pacific/Auckland",
+	}
+
+	weekends := NewWeekendDataSourceFor(config.GlobalConfig)
+	ds, err := NewScheduleDataSources(config.GlobalConfig)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	tests := []struct {
+		loc           *time.Location
+		expectedStart time.Time
+	}{
+		{sydTz, time.Date(2019, time.January, 4, 17, 30, 0, 0, sydTz)},
+		{nil, time.Date(2019, time.January, 4, 17, 30, 0, 0, aklTz)},
+	}
+	for _, test := range tests {
+		// Find the weekend containing Saturday noon UTC
+		saturday := time.Date(2019, time.January, 5, 12, 0, 0, 0, time.UTC)
+		found := false
+		for _, span := range mustSpans(t, weekends, ds.Period, ds.TimezoneFor(test.loc)) {
+			if span.ContainsTime(saturday) {
+				found = true
+				if !span.Start().Equal(test.expectedStart) {
+					t.Errorf("Expected weekend to start at %s, got %s", test.expectedStart, span.Start())
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Expected a weekend span in %s containing %s", test.loc, saturday)
+		}
+	}
+}
+
+func TestWeekTemplateSpans(t *testing.T) {
+	// Sunday to Thursday, closing early on Thursdays
+	sc := config.ScheduleConfig{
+		ScheduleSpan: timespan.New(time.Date(2019, time.January, 6, 0, 0, 0, 0, aklTz), time.Date(2019, time.January, 13, 0, 0, 0, 0, aklTz)),
+		BusinessHours: config.BusinessHoursStruct{
+			Start: "08:00",
+			End:   "17:30",
+			Week: map[string]string{
+				"sunday":   "08:00-17:30",
+				"thursday": "08:00-15:00",
+				"friday":   "closed",
+			},
+		},
+		Timezone: "Pacific/Auckland",
+	}
+
+	weekend := mustSpans(t, NewWeekendDataSourceFor(sc), sc.ScheduleSpan, aklTz)
+	// Sunday morning follows the closed Saturday, and the weekend starts after closing on Thursday
+	expected := []timespan.Span{
+		timespan.New(time.Date(2019, time.January, 6, 0, 0, 0, 0, aklTz), time.Date(2019, time.January, 6, 8, 0, 0, 0, aklTz)),
+		timespan.New(time.Date(2019, time.January, 10, 15, 0, 0, 0, aklTz), time.Date(2019, time.January, 13, 0, 0, 0, 0, aklTz)),
+	}
+	if len(weekend) != len(expected) {
+		t.Fatalf("Expected %d weekend spans, got %d", len(expected), len(weekend))
+	}
+	for i := range expected {
+		if !weekend[i].Equal(expected[i]) {
+			t.Errorf("Expected weekend from %s to %s, got %s to %s", expected[i].Start(), expected[i].End(), weekend[i].Start(), weekend[i].End())
+		}
+	}
+
+	afterHours := mustSpans(t, NewAfterHoursDataSourceFor(sc), sc.ScheduleSpan, aklTz)
+	// Sunday evening until Thursday morning, with no after hours on Thursday evening
+	first := afterHours[0]
+	last := afterHours[len(afterHours)-1]
+	if !first.Start().Equal(time.Date(2019, time.January, 6, 17, 30, 0, 0, aklTz)) {
+		t.Errorf("Expected after hours to start Sunday after closing, got %s", first.Start())
+	}
+	if !last.Equal(timespan.New(time.Date(2019, time.January, 9, 17, 30, 0, 0, aklTz), time.Date(2019, time.January, 10, 8, 0, 0, 0, aklTz))) {
+		t.Errorf("Expected the last after hours span to be Wednesday night, got %s to %s", last.Start(), last.End())
+	}
+}
+
+func TestPerSchedule(t *testing.T) {
+	start := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2019, time.March, 1, 0, 0, 0, 0, time.UTC)
+	global := config.ScheduleConfig{
+		Timezone:      "Pacific/Auckland",
+		BusinessHours: config.BusinessHoursStruct{Start: "09:00", End: "17:00"},
+		HolidayDates:  []config.HolidayDate{{Name: "Waitangi Day", Date: "06/02/2019"}},
+		ScheduleSpan:  timespan.New(start, end),
+	}
+	au := global
+	au.Timezone = "Australia/Sydney"
+	au.HolidayDates = []config.HolidayDate{{Name: "Australia Day", Date: "26/01/2019"}}
+	forSchedule := PerSchedule(global, map[string]config.ScheduleConfig{"pau5678": au})
+
+	tests := []struct {
+		scheduleID string
+		timezone   string
+		holiday    int
+	}{
+		// Profiles are looked up by lower case schedule ID
+		{scheduleID: "PAU5678", timezone: "Australia/Sydney", holiday: 26},
+		{scheduleID: "POTHER1", timezone: "Pacific/Auckland", holiday: 6},
+	}
+	for _, test := range tests {
+		ds, err := forSchedule(test.scheduleID)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.scheduleID, err.Error())
+		}
+		if ds.Location.String() != test.timezone {
+			t.Errorf("%s: expected timezone %s, got %s", test.scheduleID, test.timezone, ds.Location)
+		}
+		for _, source := range ds.Sources {
+			if source.Name != "calendar" {
+				continue
+			}
+			spans := mustSpans(t, source, ds.Period, ds.Location)
+			if len(spans) != 1 || spans[0].Start().In(ds.Location).Day() != test.holiday {
+				t.Errorf("%s: expected a holiday on the %d, got %v", test.scheduleID, test.holiday, spans)
+			}
+		}
+	}
+
+	// Schedules without a profile share the global datasources
+	other, _ := forSchedule("POTHER1")
+	another, _ := forSchedule("PANOTHER")
+	if other.Sources[0].DataSource != another.Sources[0].DataSource {
+		t.Errorf("Expected schedules without a profile to share their datasources")
+	}
+}
@@ -0,0 +1,204 @@
+package datasources
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/config"
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+// DescribedDataSource is a DataSource that can tell where its spans come from, like the calendar event,
+// company day entry or business hours rule
+type DescribedDataSource interface {
+	DataSource
+	// Describe returns where the spans at the time at come from, when asked for the spans of the reporting span
+	// in the timezone loc. It's empty if none of the spans are at that time
+	Describe(ctx context.Context, span timespan.Span, at time.Time, loc *time.Location) (string, error)
+}
+
+// SpanAt returns the span of spans that at is in, from its start until just before its end
+func SpanAt(spans []timespan.Span, at time.Time) (timespan.Span, bool) {
+	for _, span := range spans {
+		if !at.Before(span.Start()) && at.Before(span.End()) {
+			return span, true
+		}
+	}
+	return timespan.Span{}, false
+}
+
+// localDay returns the start of the day of at in loc
+func localDay(at time.Time, loc *time.Location) time.Time {
+	local := at.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+}
+
+// Describe returns the weekend rule that applies at the time at in loc
+func (wds WeekendDataSource) Describe(ctx context.Context, span timespan.Span, at time.Time, loc *time.Location) (string, error) {
+	week, err := wds.Schedule.BusinessHours.WeekTemplate()
+	if err != nil {
+		return "", fmt.Errorf("failed to parse business hours, err: %s", err.Error())
+	}
+	day := localDay(at, loc)
+	spans, err := weekendSpans(day, week)
+	if err != nil {
+		return "", err
+	}
+	if _, found := SpanAt(spans, at); !found {
+		return "", nil
+	}
+	hours := week[day.Weekday()]
+	if !openOn(week, day) {
+		return fmt.Sprintf("closed on %s", day.Weekday()), nil
+	}
+	opening, _, err := hours.On(day, loc)
+	if err != nil {
+		return "", err
+	}
+	if at.Before(opening) {
+		return fmt.Sprintf("before opening at %s on %s, after closed %s", hours.Start, day.Weekday(), day.AddDate(0, 0, -1).Weekday()), nil
+	}
+	return fmt.Sprintf("after closing at %s on %s, before closed %s", hours.End, day.Weekday(), day.AddDate(0, 0, 1).Weekday()), nil
+}
+
+// Describe returns the after hours rule that applies at the time at in loc
+func (ahds AfterHoursDataSource) Describe(ctx context.Context, span timespan.Span, at time.Time, loc *time.Location) (string, error) {
+	week, err := ahds.Schedule.BusinessHours.WeekTemplate()
+	if err != nil {
+		return "", fmt.Errorf("failed to parse business hours, err: %s", err.Error())
+	}
+	day := localDay(at, loc)
+	spans, err := afterHoursSpans(day, week)
+	if err != nil {
+		return "", err
+	}
+	if _, found := SpanAt(spans, at); !found {
+		return "", nil
+	}
+	hours := week[day.Weekday()]
+	opening, _, err := hours.On(day, loc)
+	if err != nil {
+		return "", err
+	}
+	if at.Before(opening) {
+		return fmt.Sprintf("before opening at %s on %s", hours.Start, day.Weekday()), nil
+	}
+	return fmt.Sprintf("after closing at %s on %s", hours.End, day.Weekday()), nil
+}
+
+// Describe returns the calendar events, generated holidays and holiday dates at the time at
+func (c CalendarDataSource) Describe(ctx context.Context, span timespan.Span, at time.Time, loc *time.Location) (string, error) {
+	loaded, err := c.loadOnce(ctx, span)
+	if err != nil {
+		return "", err
+	}
+	return describeEvents(loaded.events, at), nil
+}
+
+// Describe returns the company day entries at the time at
+func (vd CompanyDaysDataSource) Describe(ctx context.Context, span timespan.Span, at time.Time, loc *time.Location) (string, error) {
+	firstYear := span.Start().In(vd.Timezone).Year() - 1
+	lastYear := span.End().In(vd.Timezone).Year()
+	descriptions := []string{}
+	for _, companyDay := range vd.CompanyDays {
+		days, err := companyDayDates(companyDay.Date, firstYear, lastYear, vd.Timezone)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse company day %q, err: %s", companyDay.Date, err.Error())
+		}
+		for _, day := range days {
+			daySpan, err := companyDaySpan(day, companyDay.Start, companyDay.End)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse times of company day %q, err: %s", companyDay.Date, err.Error())
+			}
+			if _, found := SpanAt([]timespan.Span{daySpan}, at); found {
+				descriptions = append(descriptions, describeCompanyDay(companyDay))
+				break
+			}
+		}
+	}
+	return strings.Join(descriptions, ", "), nil
+}
+
+// describeCompanyDay returns the company day entry as it's configured, e.g. `company day "every 24/12" 13:00-24:00 (Christmas Eve)`
+func describeCompanyDay(companyDay config.CompanyDay) string {
+	description := fmt.Sprintf("company day %q", companyDay.Date)
+	if companyDay.Start != "" || companyDay.End != "" {
+		start, end := companyDay.Start, companyDay.End
+		if start == "" {
+			start = "00:00"
+		}
+		if end == "" {
+			end = "24:00"
+		}
+		description += " " + start + "-" + end
+	}
+	if companyDay.Name != "" {
+		description += " (" + companyDay.Name + ")"
+	}
+	return description
+}
+
+// Describe returns the weekly windows and dates of the time band at the time at in loc
+func (tbds TimeBandDataSource) Describe(ctx context.Context, span timespan.Span, at time.Time, loc *time.Location) (string, error) {
+	descriptions := []string{}
+	day := localDay(at, loc)
+	for _, window := range tbds.Band.Weekly {
+		// Overnight windows starting the day before count as well
+		for _, windowDay := range []time.Time{day.AddDate(0, 0, -1), day} {
+			if !window.OnDay(windowDay.Weekday()) {
+				continue
+			}
+			start, end := windowOn(windowDay, window)
+			if _, found := SpanAt([]timespan.Span{timespan.New(start, end)}, at); found {
+				descriptions = append(descriptions, describeWindow(tbds.Band.Name, window))
+				break
+			}
+		}
+	}
+	for _, expr := range tbds.Band.Dates {
+		dates, err := companyDayDates(expr, day.Year()-1, day.Year(), loc)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse date %q of time band %s, err: %s", expr, tbds.Band.Name, err.Error())
+		}
+		for _, date := range dates {
+			if _, found := SpanAt([]timespan.Span{timespan.New(date, date.AddDate(0, 0, 1))}, at); found {
+				descriptions = append(descriptions, fmt.Sprintf("time band %s date %q", tbds.Band.Name, expr))
+				break
+			}
+		}
+	}
+	return strings.Join(descriptions, ", "), nil
+}
+
+// describeWindow returns the weekly window of a time band as it's configured, e.g. "time band night Mon, Tue 22:00-06:00"
+func describeWindow(band string, window config.WeeklyWindow) string {
+	days := "every day"
+	if len(window.Days) != 0 {
+		days = strings.Join(window.Days, ", ")
+	}
+	return fmt.Sprintf("time band %s %s %s-%s", band, days, window.Start, window.End)
+}
+
+// describeEvents returns the names of the events at the time at
+func describeEvents(events []CompanyDayEvent, at time.Time) string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, event := range events {
+		if _, found := SpanAt([]timespan.Span{event.Span}, at); found && !seen[event.Name] {
+			seen[event.Name] = true
+			names = append(names, event.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// Describe returns the description of the underlying DataSource if it's a DescribedDataSource,
+// or the names of its spans at the time at if it's a NamedDataSource
+func (c *cachedDataSource) Describe(ctx context.Context, span timespan.Span, at time.Time, loc *time.Location) (string, error) {
+	if described, isDescribed := c.DataSource.(DescribedDataSource); isDescribed {
+		return described.Describe(ctx, span, at, loc)
+	}
+	return c.SpanName(timespan.New(at, at.Add(time.Second))), nil
+}
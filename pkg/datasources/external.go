@@ -0,0 +1,203 @@
+package datasources
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/config"
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+// defaultExternalTimeout is how long http-json and command datasources get to return their spans by default
+const defaultExternalTimeout = 30 * time.Second
+
+// externalSpan is a single span returned by http-json and command datasources, a JSON object
+// with RFC 3339 "start" and "end" times and an optional "name"
+type externalSpan struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Name  string    `json:"name"`
+}
+
+// externalEvents are the named spans an external datasource has returned so far
+type externalEvents struct {
+	events []CompanyDayEvent
+}
+
+// parse returns the spans of the JSON array of externalSpans in body, remembering the named ones
+func (e *externalEvents) parse(body []byte) ([]timespan.Span, error) {
+	var external []externalSpan
+	if err := json.Unmarshal(body, &external); err != nil {
+		return nil, fmt.Errorf("expected a JSON array of spans with a start and end, err: %s", err.Error())
+	}
+	spans := []timespan.Span{}
+	for _, span := range external {
+		if !span.End.After(span.Start) {
+			return nil, fmt.Errorf("span end %s isn't after start %s", span.End, span.Start)
+		}
+		spans = append(spans, timespan.New(span.Start, span.End))
+		if span.Name != "" {
+			e.events = append(e.events, CompanyDayEvent{Span: timespan.New(span.Start, span.End), Name: span.Name})
+		}
+	}
+	return timespan.MergeSpans(spans), nil
+}
+
+// SpanName returns the names of the spans returned so far during span
+func (e *externalEvents) SpanName(span timespan.Span) string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, event := range e.events {
+		if event.Overlaps(span) && !seen[event.Name] {
+			seen[event.Name] = true
+			names = append(names, event.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// HTTPDataSource is a datasource reading its spans from a JSON API. The reporting span is sent as the RFC 3339
+// "start" and "end" query parameters, and the timezone as "timezone"
+type HTTPDataSource struct {
+	*externalEvents
+	URL string
+	// Headers are sent with every request, with environment variables in the values expanded
+	Headers map[string]string
+	Timeout time.Duration
+}
+
+// httpOptions are the options of "http-json" datasources
+type httpOptions struct {
+	URL     string            `mapstructure:"url"`
+	Headers map[string]string `mapstructure:"headers"`
+	Timeout time.Duration     `mapstructure:"timeout"`
+}
+
+// newHTTPDataSource returns a datasource reading its spans from the JSON API in the options
+func newHTTPDataSource(entry config.DataSourceConfig, sc config.ScheduleConfig) (DataSource, error) {
+	var opts httpOptions
+	if err := entry.DecodeOptions(&opts); err != nil {
+		return nil, err
+	}
+	if _, err := url.ParseRequestURI(opts.URL); err != nil {
+		return nil, fmt.Errorf("failed to parse url, err: %s", err.Error())
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultExternalTimeout
+	}
+	return &HTTPDataSource{externalEvents: &externalEvents{}, URL: opts.URL, Headers: opts.Headers, Timeout: opts.Timeout}, nil
+}
+
+// Spans returns the spans the API returns for span in the timezone loc
+func (h *HTTPDataSource) Spans(ctx context.Context, span timespan.Span, loc *time.Location) ([]timespan.Span, error) {
+	spans, err := h.fetch(ctx, span, loc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spans from %s, err: %s", h.URL, err.Error())
+	}
+	return spans, nil
+}
+
+// fetch requests the spans of span in the timezone loc from the API, giving up once ctx is done
+func (h *HTTPDataSource) fetch(ctx context.Context, span timespan.Span, loc *time.Location) ([]timespan.Span, error) {
+	reqURL, err := url.Parse(h.URL)
+	if err != nil {
+		return nil, err
+	}
+	query := reqURL.Query()
+	query.Set("start", span.Start().Format(time.RFC3339))
+	query.Set("end", span.End().Format(time.RFC3339))
+	query.Set("timezone", loc.String())
+	reqURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for name, value := range h.Headers {
+		req.Header.Set(name, os.ExpandEnv(value))
+	}
+	client := http.Client{Timeout: h.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return h.parse(body)
+}
+
+// CommandDataSource is a datasource reading its spans from the JSON output of a command. The reporting span
+// is passed in the RFC 3339 PAGERTALLY_START and PAGERTALLY_END environment variables, and the timezone
+// in PAGERTALLY_TIMEZONE
+type CommandDataSource struct {
+	*externalEvents
+	// Command is the program and its arguments
+	Command []string
+	Timeout time.Duration
+}
+
+// commandOptions are the options of "command" datasources
+type commandOptions struct {
+	Command []string      `mapstructure:"command"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// newCommandDataSource returns a datasource reading its spans from the output of the command in the options
+func newCommandDataSource(entry config.DataSourceConfig, sc config.ScheduleConfig) (DataSource, error) {
+	var opts commandOptions
+	if err := entry.DecodeOptions(&opts); err != nil {
+		return nil, err
+	}
+	if len(opts.Command) == 0 {
+		return nil, fmt.Errorf("missing command")
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultExternalTimeout
+	}
+	return &CommandDataSource{externalEvents: &externalEvents{}, Command: opts.Command, Timeout: opts.Timeout}, nil
+}
+
+// Spans returns the spans the command outputs for span in the timezone loc
+func (c *CommandDataSource) Spans(ctx context.Context, span timespan.Span, loc *time.Location) ([]timespan.Span, error) {
+	spans, err := c.run(ctx, span, loc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spans from %s, err: %s", c.Command[0], err.Error())
+	}
+	return spans, nil
+}
+
+// run runs the command for span in the timezone loc and parses its output. The command is killed
+// once ctx is done or the timeout passes
+func (c *CommandDataSource) run(ctx context.Context, span timespan.Span, loc *time.Location) ([]timespan.Span, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, c.Command[0], c.Command[1:]...)
+	cmd.Env = append(os.Environ(),
+		"PAGERTALLY_START="+span.Start().Format(time.RFC3339),
+		"PAGERTALLY_END="+span.End().Format(time.RFC3339),
+		"PAGERTALLY_TIMEZONE="+loc.String(),
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	return c.parse(out)
+}
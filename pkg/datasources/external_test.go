@@ -0,0 +1,47 @@
+package datasources
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+func TestExternalDataSourcesCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			// Only answers once the client gives up
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(`[{"start": "2019-01-05T00:00:00Z", "end": "2019-01-06T00:00:00Z"}]`))
+	}))
+	defer server.Close()
+	span := timespan.New(time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, time.February, 1, 0, 0, 0, 0, time.UTC))
+
+	fast := &HTTPDataSource{externalEvents: &externalEvents{}, URL: server.URL, Timeout: time.Minute}
+	spans, err := fast.Spans(context.Background(), span, time.UTC)
+	if err != nil || len(spans) != 1 {
+		t.Fatalf("Expected 1 span from the API, got %d and %v", len(spans), err)
+	}
+
+	sources := map[string]DataSource{
+		"http-json": &HTTPDataSource{externalEvents: &externalEvents{}, URL: server.URL + "/slow", Timeout: time.Minute},
+		"command":   &CommandDataSource{externalEvents: &externalEvents{}, Command: []string{"sleep", "60"}, Timeout: time.Minute},
+	}
+
+	for name, source := range sources {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		started := time.Now()
+		if _, err := source.Spans(ctx, span, time.UTC); err == nil {
+			t.Errorf("%s: expected an error once the context is done", name)
+		}
+		if took := time.Since(started); took > 10*time.Second {
+			t.Errorf("%s: expected to stop when the context is done, took %s", name, took)
+		}
+		cancel()
+	}
+}
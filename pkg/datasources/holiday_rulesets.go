@@ -0,0 +1,115 @@
+package datasources
+
+import "time"
+
+// HolidayRuleSets are the bundled holiday rules by ISO 3166 country or subdivision code.
+// Subdivisions include the holidays of their country
+var HolidayRuleSets = map[string][]HolidayRule{
+	"NZ": nzHolidays,
+	// Northland, Auckland, Waikato, Bay of Plenty and Gisborne observe Auckland Anniversary Day
+	"NZ-AUK": nzRegion(HolidayRule{Name: "Auckland Anniversary Day", Month: time.January, Day: 29, Weekday: time.Monday, Nearest: true}),
+	// Wellington, Manawatū-Whanganui and Wairarapa observe Wellington Anniversary Day
+	"NZ-WGN": nzRegion(HolidayRule{Name: "Wellington Anniversary Day", Month: time.January, Day: 22, Weekday: time.Monday, Nearest: true}),
+	"NZ-NSN": nzRegion(HolidayRule{Name: "Nelson Anniversary Day", Month: time.February, Day: 1, Weekday: time.Monday, Nearest: true}),
+	"NZ-TKI": nzRegion(HolidayRule{Name: "Taranaki Anniversary Day", Month: time.March, Weekday: time.Monday, Nth: 2}),
+	// The Friday before Labour Day
+	"NZ-HKB": nzRegion(HolidayRule{Name: "Hawke's Bay Anniversary Day", Month: time.October, Day: 19, Weekday: time.Friday, Nth: 1}),
+	// The Monday after Labour Day
+	"NZ-MBH": nzRegion(HolidayRule{Name: "Marlborough Anniversary Day", Month: time.October, Day: 29, Weekday: time.Monday, Nth: 1}),
+	// The second Friday after the first Tuesday in November
+	"NZ-CAN": nzRegion(HolidayRule{Name: "Canterbury Anniversary Day", Month: time.November, Day: 11, Weekday: time.Friday, Nth: 1}),
+	"NZ-OTA": nzRegion(HolidayRule{Name: "Otago Anniversary Day", Month: time.March, Day: 23, Weekday: time.Monday, Nearest: true}),
+	// Easter Tuesday
+	"NZ-STL": nzRegion(HolidayRule{Name: "Southland Anniversary Day", Easter: true, EasterOffset: 2, From: 2012}),
+	"NZ-WTC": nzRegion(HolidayRule{Name: "Westland Anniversary Day", Month: time.December, Day: 1, Weekday: time.Monday, Nearest: true}),
+	"NZ-CIT": nzRegion(HolidayRule{Name: "Chatham Islands Anniversary Day", Month: time.November, Day: 30, Weekday: time.Monday, Nearest: true}),
+
+	"AU": auHolidays,
+	"AU-NSW": auState(
+		easterSaturday, easterDay,
+		HolidayRule{Name: "King's Birthday", FormerName: "Queen's Birthday", RenamedIn: 2023, Month: time.June, Weekday: time.Monday, Nth: 2},
+		HolidayRule{Name: "Labour Day", Month: time.October, Weekday: time.Monday, Nth: 1},
+	),
+	"AU-VIC": auState(
+		HolidayRule{Name: "Labour Day", Month: time.March, Weekday: time.Monday, Nth: 2},
+		easterSaturday, easterDay,
+		HolidayRule{Name: "King's Birthday", FormerName: "Queen's Birthday", RenamedIn: 2023, Month: time.June, Weekday: time.Monday, Nth: 2},
+		HolidayRule{Name: "Melbourne Cup", Month: time.November, Weekday: time.Tuesday, Nth: 1},
+	),
+	"AU-QLD": auState(
+		easterSaturday, easterDay,
+		HolidayRule{Name: "Labour Day", Month: time.May, Weekday: time.Monday, Nth: 1},
+		HolidayRule{Name: "King's Birthday", FormerName: "Queen's Birthday", RenamedIn: 2023, Month: time.October, Weekday: time.Monday, Nth: 1},
+	),
+	"AU-SA": auState(
+		HolidayRule{Name: "Adelaide Cup Day", Month: time.March, Weekday: time.Monday, Nth: 2},
+		easterSaturday,
+		HolidayRule{Name: "King's Birthday", FormerName: "Queen's Birthday", RenamedIn: 2023, Month: time.June, Weekday: time.Monday, Nth: 2},
+		HolidayRule{Name: "Labour Day", Month: time.October, Weekday: time.Monday, Nth: 1},
+	),
+	"AU-WA": auState(
+		HolidayRule{Name: "Labour Day", Month: time.March, Weekday: time.Monday, Nth: 1},
+		HolidayRule{Name: "Western Australia Day", Month: time.June, Weekday: time.Monday, Nth: 1},
+		HolidayRule{Name: "King's Birthday", FormerName: "Queen's Birthday", RenamedIn: 2023, Month: time.September, Weekday: time.Monday, Nth: -1},
+	),
+	"AU-TAS": auState(
+		HolidayRule{Name: "Eight Hours Day", Month: time.March, Weekday: time.Monday, Nth: 2},
+		HolidayRule{Name: "King's Birthday", FormerName: "Queen's Birthday", RenamedIn: 2023, Month: time.June, Weekday: time.Monday, Nth: 2},
+	),
+	"AU-ACT": auState(
+		HolidayRule{Name: "Canberra Day", Month: time.March, Weekday: time.Monday, Nth: 2},
+		easterSaturday, easterDay,
+		HolidayRule{Name: "Reconciliation Day", Month: time.May, Day: 27, Weekday: time.Monday, Nth: 1, From: 2018},
+		HolidayRule{Name: "King's Birthday", FormerName: "Queen's Birthday", RenamedIn: 2023, Month: time.June, Weekday: time.Monday, Nth: 2},
+		HolidayRule{Name: "Labour Day", Month: time.October, Weekday: time.Monday, Nth: 1},
+	),
+	"AU-NT": auState(
+		easterSaturday,
+		HolidayRule{Name: "May Day", Month: time.May, Weekday: time.Monday, Nth: 1},
+		HolidayRule{Name: "King's Birthday", FormerName: "Queen's Birthday", RenamedIn: 2023, Month: time.June, Weekday: time.Monday, Nth: 2},
+		HolidayRule{Name: "Picnic Day", Month: time.August, Weekday: time.Monday, Nth: 1},
+	),
+}
+
+var nzHolidays = []HolidayRule{
+	{Name: "New Year's Day", Month: time.January, Day: 1, Mondayise: true},
+	{Name: "Day after New Year's Day", Month: time.January, Day: 2, Mondayise: true},
+	{Name: "Waitangi Day", Month: time.February, Day: 6, Mondayise: true, MondayiseFrom: 2014},
+	{Name: "Good Friday", Easter: true, EasterOffset: -2},
+	{Name: "Easter Monday", Easter: true, EasterOffset: 1},
+	{Name: "ANZAC Day", Month: time.April, Day: 25, Mondayise: true, MondayiseFrom: 2014},
+	{Name: "King's Birthday", FormerName: "Queen's Birthday", RenamedIn: 2023, Month: time.June, Weekday: time.Monday, Nth: 1},
+	// Matariki is set by law every year, later years can be added to "holiday_dates"
+	{Name: "Matariki", Dates: []string{
+		"2022-06-24", "2023-07-14", "2024-06-28", "2025-06-20", "2026-07-10",
+		"2027-06-25", "2028-07-14", "2029-07-06", "2030-06-21",
+	}},
+	{Name: "Labour Day", Month: time.October, Weekday: time.Monday, Nth: 4},
+	{Name: "Christmas Day", Month: time.December, Day: 25, Mondayise: true},
+	{Name: "Boxing Day", Month: time.December, Day: 26, Mondayise: true},
+}
+
+var auHolidays = []HolidayRule{
+	{Name: "New Year's Day", Month: time.January, Day: 1, Mondayise: true},
+	{Name: "Australia Day", Month: time.January, Day: 26, Mondayise: true},
+	{Name: "Good Friday", Easter: true, EasterOffset: -2},
+	{Name: "Easter Monday", Easter: true, EasterOffset: 1},
+	{Name: "ANZAC Day", Month: time.April, Day: 25},
+	{Name: "Christmas Day", Month: time.December, Day: 25, Mondayise: true},
+	{Name: "Boxing Day", Month: time.December, Day: 26, Mondayise: true},
+}
+
+var (
+	easterSaturday = HolidayRule{Name: "Easter Saturday", Easter: true, EasterOffset: -1}
+	easterDay      = HolidayRule{Name: "Easter Sunday", Easter: true}
+)
+
+// nzRegion returns the NZ holidays and the anniversary day of a region
+func nzRegion(anniversary HolidayRule) []HolidayRule {
+	return append(append([]HolidayRule{}, nzHolidays...), anniversary)
+}
+
+// auState returns the AU holidays and the holidays of a state or territory
+func auState(rules ...HolidayRule) []HolidayRule {
+	return append(append([]HolidayRule{}, auHolidays...), rules...)
+}
@@ -0,0 +1,189 @@
+package datasources
+
+// Rule based public holidays, so stat holidays can be worked out for any year without an iCal
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// dateFormat is the format of HolidayRule.Dates
+const dateFormat = "2006-01-02"
+
+// HolidayRule describes how to work out the date of a public holiday in any year.
+//
+// A rule is one of:
+// - Easter relative, EasterOffset days from Easter Sunday
+// - the Nth Weekday of Month on or after Day, counting back from the end of Month if Nth is negative
+// - the Weekday nearest to Month and Day, if Nearest is set
+// - one of the Dates set by law every year, if Dates is set
+// - a fixed date, Month and Day
+type HolidayRule struct {
+	Name         string
+	Month        time.Month
+	Day          int
+	Weekday      time.Weekday
+	Nth          int
+	Nearest      bool
+	Easter       bool
+	EasterOffset int
+	// Dates are the dates of a holiday set by law, formatted as dateFormat
+	Dates []string
+	// Mondayise observes the holiday on the following Monday too if it falls on a weekend,
+	// or the next weekday that isn't already a holiday
+	Mondayise bool
+	// MondayiseFrom is the first year the holiday is mondayised
+	MondayiseFrom int
+	// From and Until are the first and last year the holiday exists, 0 if unbounded
+	From  int
+	Until int
+	// FormerName is the name of the holiday before the year it was RenamedIn
+	FormerName string
+	RenamedIn  int
+}
+
+// Holiday is a public holiday on a single day
+type Holiday struct {
+	Name string
+	Date time.Time
+	// Observed is true if the holiday fell on a weekend and is observed on this weekday instead
+	Observed bool
+}
+
+// Date returns the date of the holiday in the year and timezone loc,
+// and false if the holiday doesn't exist that year
+func (r HolidayRule) Date(year int, loc *time.Location) (time.Time, bool) {
+	if (r.From != 0 && year < r.From) || (r.Until != 0 && year > r.Until) {
+		return time.Time{}, false
+	}
+	switch {
+	case r.Easter:
+		return easterSunday(year, loc).AddDate(0, 0, r.EasterOffset), true
+	case r.Nth > 0:
+		day := r.Day
+		if day == 0 {
+			day = 1
+		}
+		first := time.Date(year, r.Month, day, 0, 0, 0, 0, loc)
+		offset := (int(r.Weekday) - int(first.Weekday()) + 7) % 7
+		return first.AddDate(0, 0, offset+7*(r.Nth-1)), true
+	case r.Nth < 0:
+		last := time.Date(year, r.Month+1, 0, 0, 0, 0, 0, loc)
+		offset := (int(last.Weekday()) - int(r.Weekday) + 7) % 7
+		return last.AddDate(0, 0, -offset+7*(r.Nth+1)), true
+	case r.Nearest:
+		date := time.Date(year, r.Month, r.Day, 0, 0, 0, 0, loc)
+		after := (int(r.Weekday) - int(date.Weekday()) + 7) % 7
+		if after <= 3 {
+			return date.AddDate(0, 0, after), true
+		}
+		return date.AddDate(0, 0, after-7), true
+	case r.Dates != nil:
+		for _, d := range r.Dates {
+			date, err := time.ParseInLocation(dateFormat, d, loc)
+			if err == nil && date.Year() == year {
+				return date, true
+			}
+		}
+		return time.Time{}, false
+	default:
+		return time.Date(year, r.Month, r.Day, 0, 0, 0, 0, loc), true
+	}
+}
+
+// NameIn returns the name of the holiday in year
+func (r HolidayRule) NameIn(year int) string {
+	if r.FormerName != "" && year < r.RenamedIn {
+		return r.FormerName
+	}
+	return r.Name
+}
+
+// mondayised returns true if the holiday is observed on a weekday when falling on a weekend in year
+func (r HolidayRule) mondayised(year int) bool {
+	return r.Mondayise && year >= r.MondayiseFrom
+}
+
+// GenerateHolidays returns the holidays of all rules in year, sorted by date. Holidays falling on a weekend
+// that are mondayised are observed on the next weekday that isn't already a holiday as well, so
+// Christmas Day and Boxing Day on a weekend are observed on Monday and Tuesday
+func GenerateHolidays(rules []HolidayRule, year int, loc *time.Location) []Holiday {
+	holidays := []Holiday{}
+	taken := map[string]bool{}
+	weekend := []Holiday{}
+	for _, rule := range rules {
+		date, exists := rule.Date(year, loc)
+		if !exists {
+			continue
+		}
+		holiday := Holiday{Name: rule.NameIn(year), Date: date}
+		holidays = append(holidays, holiday)
+		if !isWeekend(date) {
+			taken[date.Format(dateFormat)] = true
+		} else if rule.mondayised(year) {
+			weekend = append(weekend, holiday)
+		}
+	}
+	sort.SliceStable(weekend, func(i, j int) bool {
+		return weekend[i].Date.Before(weekend[j].Date)
+	})
+	for _, holiday := range weekend {
+		observed := holiday.Date
+		for isWeekend(observed) || taken[observed.Format(dateFormat)] {
+			observed = observed.AddDate(0, 0, 1)
+		}
+		taken[observed.Format(dateFormat)] = true
+		holidays = append(holidays, Holiday{Name: holiday.Name, Date: observed, Observed: true})
+	}
+	sort.SliceStable(holidays, func(i, j int) bool {
+		return holidays[i].Date.Before(holidays[j].Date)
+	})
+	return holidays
+}
+
+// HolidayRulesFor returns the rules of all the named rule sets in HolidayRuleSets, leaving out rules that
+// are identical in more than one of them. Rules of the same name on different dates are kept, as states
+// can hold the same holiday at different times of the year
+func HolidayRulesFor(ruleSets []string) ([]HolidayRule, error) {
+	rules := []HolidayRule{}
+	seen := map[string]bool{}
+	for _, name := range ruleSets {
+		ruleSet, exists := HolidayRuleSets[name]
+		if !exists {
+			return nil, fmt.Errorf("unknown holiday rule set %q", name)
+		}
+		for _, rule := range ruleSet {
+			key := fmt.Sprintf("%+v", rule)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// easterSunday returns the date of Easter Sunday in year using the anonymous Gregorian algorithm
+func easterSunday(year int, loc *time.Location) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, loc)
+}
+
+func isWeekend(day time.Time) bool {
+	return day.Weekday() == time.Saturday || day.Weekday() == time.Sunday
+}
@@ -0,0 +1,120 @@
+package datasources
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/config"
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+func TestEasterSunday(t *testing.T) {
+	tests := map[int]string{
+		2019: "2019-04-21",
+		2024: "2024-03-31",
+		2025: "2025-04-20",
+		2038: "2038-04-25",
+	}
+	for year, expected := range tests {
+		if easter := easterSunday(year, time.UTC).Format(dateFormat); easter != expected {
+			t.Errorf("Expected Easter Sunday %d on %s, got %s", year, expected, easter)
+		}
+	}
+}
+
+func TestGenerateHolidays(t *testing.T) {
+	tests := []struct {
+		ruleSet string
+		year    int
+		name    string
+		dates   []string
+	}{
+		// Both fall on a weekend, so they're observed on Monday and Tuesday
+		{"NZ", 2021, "Christmas Day", []string{"2021-12-25", "2021-12-27"}},
+		{"NZ", 2021, "Boxing Day", []string{"2021-12-26", "2021-12-28"}},
+		{"NZ", 2022, "Day after New Year's Day", []string{"2022-01-02", "2022-01-04"}},
+		{"NZ", 2017, "New Year's Day", []string{"2017-01-01", "2017-01-03"}},
+		{"NZ", 2019, "Labour Day", []string{"2019-10-28"}},
+		{"NZ", 2022, "Queen's Birthday", []string{"2022-06-06"}},
+		{"NZ", 2023, "King's Birthday", []string{"2023-06-05"}},
+		{"NZ", 2024, "Matariki", []string{"2024-06-28"}},
+		{"NZ", 2019, "Good Friday", []string{"2019-04-19"}},
+		{"NZ-AUK", 2019, "Auckland Anniversary Day", []string{"2019-01-28"}},
+		{"NZ-WGN", 2019, "Wellington Anniversary Day", []string{"2019-01-21"}},
+		{"NZ-CAN", 2019, "Canterbury Anniversary Day", []string{"2019-11-15"}},
+		{"AU-VIC", 2019, "Melbourne Cup", []string{"2019-11-05"}},
+		{"AU-WA", 2023, "King's Birthday", []string{"2023-09-25"}},
+		{"AU", 2020, "ANZAC Day", []string{"2020-04-25"}},
+	}
+	for _, test := range tests {
+		rules, err := HolidayRulesFor([]string{test.ruleSet})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		dates := []string{}
+		for _, holiday := range GenerateHolidays(rules, test.year, time.UTC) {
+			if holiday.Name == test.name {
+				dates = append(dates, holiday.Date.Format(dateFormat))
+			}
+		}
+		if len(dates) != len(test.dates) {
+			t.Errorf("%s %s %d: expected %v, got %v", test.ruleSet, test.name, test.year, test.dates, dates)
+			continue
+		}
+		for i := range dates {
+			if dates[i] != test.dates[i] {
+				t.Errorf("%s %s %d: expected %v, got %v", test.ruleSet, test.name, test.year, test.dates, dates)
+				break
+			}
+		}
+	}
+	if _, err := HolidayRulesFor([]string{"NZ-XYZ"}); err == nil {
+		t.Errorf("Expected an error for an unknown holiday rule set")
+	}
+}
+
+func TestCombinedHolidayRuleSets(t *testing.T) {
+	tests := []struct {
+		ruleSets []string
+		year     int
+		name     string
+		dates    []string
+	}{
+		{[]string{"AU-NSW", "AU-VIC"}, 2019, "Labour Day", []string{"2019-03-11", "2019-10-07"}},
+		{[]string{"AU-VIC", "AU-NSW"}, 2019, "Christmas Day", []string{"2019-12-25"}},
+		{[]string{"AU-NSW", "AU-QLD"}, 2023, "King's Birthday", []string{"2023-06-12", "2023-10-02"}},
+	}
+	for _, test := range tests {
+		rules, err := HolidayRulesFor(test.ruleSets)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		dates := []string{}
+		for _, holiday := range GenerateHolidays(rules, test.year, time.UTC) {
+			if holiday.Name == test.name {
+				dates = append(dates, holiday.Date.Format(dateFormat))
+			}
+		}
+		if strings.Join(dates, " ") != strings.Join(test.dates, " ") {
+			t.Errorf("%v %s %d: expected %v, got %v", test.ruleSets, test.name, test.year, test.dates, dates)
+		}
+	}
+}
+
+func TestGeneratedCalendarSpans(t *testing.T) {
+	akl, _ := time.LoadLocation("Pacific/Auckland")
+	start := time.Date(2019, time.January, 1, 0, 0, 0, 0, akl)
+	end := time.Date(2019, time.February, 1, 0, 0, 0, 0, akl)
+
+	cal := mustCalendar(t, config.ScheduleConfig{
+		Holidays:     []string{"Auckland Anniversary Day", "Wellington Anniversary Day"},
+		HolidayRules: []string{"NZ-AUK", "NZ-WGN"},
+		Timezone:     "Pacific/Auckland",
+		ScheduleSpan: timespan.New(start, end),
+	})
+
+	if len(mustSpans(t, cal, timespan.New(start, end), akl)) != 2 {
+		t.Errorf("Calendar should contain 2 spans, got %d", len(mustSpans(t, cal, timespan.New(start, end), akl)))
+	}
+}
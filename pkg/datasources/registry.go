@@ -0,0 +1,139 @@
+package datasources
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/leosunmo/pagertally/pkg/config"
+)
+
+// Factory returns the DataSource of an entry in the "datasources" config of the schedule config sc
+type Factory func(entry config.DataSourceConfig, sc config.ScheduleConfig) (DataSource, error)
+
+// factories are the registered datasource types by lower case name
+var factories = map[string]Factory{}
+
+func init() {
+	Register("ical", newICalDataSource)
+	Register("static", newStaticDataSource)
+	Register("weekly", newWeeklyDataSource)
+	Register("http-json", newHTTPDataSource)
+	Register("command", newCommandDataSource)
+}
+
+// Register makes a datasource type available to the "datasources" config under typeName,
+// replacing any type already registered with that name
+func Register(typeName string, factory Factory) {
+	factories[strings.ToLower(typeName)] = factory
+}
+
+// Types returns the names of the registered datasource types
+func Types() []string {
+	types := []string{}
+	for typeName := range factories {
+		types = append(types, typeName)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// New returns the DataSource of an entry in the "datasources" config, created by the factory of its type
+func New(entry config.DataSourceConfig, sc config.ScheduleConfig) (AttributeDataSource, error) {
+	factory, exists := factories[strings.ToLower(entry.Type)]
+	if !exists {
+		return AttributeDataSource{}, fmt.Errorf("%s: unknown datasource type %q, use one of %s", entry.Name, entry.Type, strings.Join(Types(), ", "))
+	}
+	ds, err := factory(entry, sc)
+	if err != nil {
+		return AttributeDataSource{}, fmt.Errorf("%s: %s", entry.Name, err.Error())
+	}
+	return AttributeDataSource{DataSource: ds, Name: entry.Name, Attribute: entry.ParsedAttribute}, nil
+}
+
+// NewConfigured returns the datasources in the "datasources" config of the schedule config sc
+func NewConfigured(sc config.ScheduleConfig) ([]AttributeDataSource, error) {
+	sources := []AttributeDataSource{}
+	for _, entry := range sc.DataSources {
+		source, err := New(entry, sc)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// icalOptions are the options of "ical" datasources. Only the whitelisted Holidays are read from
+// the calendars, but every generated holiday is used if there is no whitelist
+type icalOptions struct {
+	URLs         []string             `mapstructure:"urls"`
+	Holidays     []string             `mapstructure:"holidays"`
+	HolidayRules []string             `mapstructure:"holiday_rules"`
+	HolidayDates []config.HolidayDate `mapstructure:"holiday_dates"`
+}
+
+// newICalDataSource returns a calendar of holidays like the stat holidays in the schedule config
+func newICalDataSource(entry config.DataSourceConfig, sc config.ScheduleConfig) (DataSource, error) {
+	var opts icalOptions
+	if err := entry.DecodeOptions(&opts); err != nil {
+		return nil, err
+	}
+	if _, err := HolidayRulesFor(opts.HolidayRules); err != nil {
+		return nil, err
+	}
+	loc, err := sc.Location()
+	if err != nil {
+		return nil, err
+	}
+	return CalendarDataSource{
+		CalTimezone:  loc,
+		CalendarURLs: opts.URLs,
+		Holidays:     opts.Holidays,
+		HolidayRules: opts.HolidayRules,
+		HolidayDates: opts.HolidayDates,
+		loaded:       &loadedCalendars{calendars: map[spansKey]CalendarDataSource{}},
+	}, nil
+}
+
+// staticOptions are the options of "static" datasources, dates in any of the formats of company days
+type staticOptions struct {
+	Dates []config.CompanyDay `mapstructure:"dates"`
+}
+
+// newStaticDataSource returns a datasource of fixed and recurring dates like company days
+func newStaticDataSource(entry config.DataSourceConfig, sc config.ScheduleConfig) (DataSource, error) {
+	var opts staticOptions
+	if err := entry.DecodeOptions(&opts); err != nil {
+		return nil, err
+	}
+	loc, err := sc.Location()
+	if err != nil {
+		return nil, err
+	}
+	for _, day := range opts.Dates {
+		if _, err := companyDayDates(day.Date, 2000, 2000, loc); err != nil {
+			return nil, fmt.Errorf("failed to parse date %q, err: %s", day.Date, err.Error())
+		}
+	}
+	return CompanyDaysDataSource{CompanyDays: opts.Dates, Timezone: loc}, nil
+}
+
+// weeklyOptions are the options of "weekly" datasources, like those of time bands
+type weeklyOptions struct {
+	Weekly []config.WeeklyWindow `mapstructure:"weekly"`
+	Dates  []string              `mapstructure:"dates"`
+}
+
+// newWeeklyDataSource returns a datasource of weekly windows in each user's timezone, like a time band
+func newWeeklyDataSource(entry config.DataSourceConfig, sc config.ScheduleConfig) (DataSource, error) {
+	var opts weeklyOptions
+	if err := entry.DecodeOptions(&opts); err != nil {
+		return nil, err
+	}
+	band := config.TimeBand{Name: entry.Name, Weekly: opts.Weekly, Dates: opts.Dates}
+	if err := band.Validate(); err != nil {
+		return nil, err
+	}
+	return NewTimeBandDataSourceFor(band), nil
+}
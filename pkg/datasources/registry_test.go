@@ -0,0 +1,76 @@
+package datasources
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/config"
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+func TestNewConfigured(t *testing.T) {
+	start := time.Date(2019, time.January, 1, 0, 0, 0, 0, aklTz)
+	end := time.Date(2019, time.January, 31, 0, 0, 0, 0, aklTz)
+	sc := config.ScheduleConfig{
+		ScheduleSpan: timespan.New(start, end),
+		Timezone:     "Pacific/Auckland",
+		DataSources: []config.DataSourceConfig{
+			{
+				Name:            "Shutdown",
+				Type:            "static",
+				ParsedAttribute: timespan.CompanyDay,
+				Options:         map[string]interface{}{"dates": []interface{}{"between 07/01 and 08/01"}},
+			},
+			{
+				Name:            "Leave",
+				Type:            "command",
+				ParsedAttribute: timespan.CompanyDay,
+				Options: map[string]interface{}{"command": []interface{}{
+					"sh", "-c", `echo '[{"start": "2019-01-14T00:00:00+13:00", "end": "2019-01-15T00:00:00+13:00", "name": "Annual leave"}]'`,
+				}},
+			},
+		},
+	}
+
+	sources, err := NewConfigured(sc)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(sources) != 2 {
+		t.Fatalf("Expected 2 datasources, got %d", len(sources))
+	}
+	// Recurring dates are created for the year before the schedule as well
+	if spans := mustSpans(t, sources[0], sc.ScheduleSpan, aklTz); len(spans) != 4 {
+		t.Errorf("Expected the shutdown to last 2 days in 2018 and 2019, got %d spans", len(spans))
+	}
+	leave := mustSpans(t, sources[1], sc.ScheduleSpan, aklTz)
+	if len(leave) != 1 || leave[0].Duration() != 24*time.Hour {
+		t.Errorf("Expected a day of leave, got %v", leave)
+	}
+	if name := sources[1].DataSource.(NamedDataSource).SpanName(leave[0]); name != "Annual leave" {
+		t.Errorf("Expected the leave to be named Annual leave, got %q", name)
+	}
+
+	sc.DataSources = []config.DataSourceConfig{{Name: "HR", Type: "carrier-pigeon"}}
+	if _, err = NewConfigured(sc); err == nil {
+		t.Errorf("Expected an error for an unknown datasource type")
+	}
+}
+
+func TestWithConfigured(t *testing.T) {
+	builtIns := BuiltInSources(CompanyDaysDataSource{}, CalendarDataSource{}, WeekendDataSource{}, AfterHoursDataSource{})
+	configured := []AttributeDataSource{
+		{Name: "regional", Attribute: timespan.StatHoliday},
+		{Name: "leave", Attribute: timespan.CompanyDay},
+	}
+	expected := []string{"company_days", "leave", "calendar", "regional", "weekends", "after_hours"}
+	sources := WithConfigured(builtIns, configured)
+	if len(sources) != len(expected) {
+		t.Fatalf("Expected %d datasources, got %d", len(expected), len(sources))
+	}
+	for i, name := range expected {
+		if sources[i].Name != name {
+			t.Errorf("Expected datasource %d to be %s, got %s", i, name, sources[i].Name)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package datasources
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/config"
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+// TimeBandDataSource is a datasource for a user-defined time band
+type TimeBandDataSource struct {
+	Band config.TimeBand
+}
+
+// NewTimeBandDataSourceFor returns a DataSource with the spans of the time band
+func NewTimeBandDataSourceFor(band config.TimeBand) TimeBandDataSource {
+	return TimeBandDataSource{Band: band}
+}
+
+// NewTimeBands returns the datasources of the schedule config's time bands
+func NewTimeBands(sc config.ScheduleConfig) []AttributeDataSource {
+	bands := []AttributeDataSource{}
+	for _, band := range sc.TimeBands {
+		bands = append(bands, AttributeDataSource{
+			DataSource: NewTimeBandDataSourceFor(band),
+			Name:       band.Name,
+			Attribute:  band.Attribute,
+			Before:     band.BeforeAttribute,
+		})
+	}
+	return bands
+}
+
+// windowOn returns the start and end of the window starting on day
+func windowOn(day time.Time, window config.WeeklyWindow) (time.Time, time.Time) {
+	start, _ := time.Parse("15:04", window.Start)
+	end, _ := time.Parse("15:04", window.End)
+	startTime := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, day.Location())
+	endTime := time.Date(day.Year(), day.Month(), day.Day(), end.Hour(), end.Minute(), 0, 0, day.Location())
+	if !endTime.After(startTime) {
+		next := day.AddDate(0, 0, 1)
+		endTime = time.Date(next.Year(), next.Month(), next.Day(), end.Hour(), end.Minute(), 0, 0, day.Location())
+	}
+	return startTime, endTime
+}
+
+// Spans returns the time band's spans during span in the timezone loc
+func (tbds TimeBandDataSource) Spans(ctx context.Context, span timespan.Span, loc *time.Location) ([]timespan.Span, error) {
+	spans := []timespan.Span{}
+	days := localDays(span, loc)
+	if len(days) != 0 {
+		// Overnight windows starting the day before the span still count
+		days = append([]time.Time{days[0].AddDate(0, 0, -1)}, days...)
+	}
+	for _, day := range days {
+		for _, window := range tbds.Band.Weekly {
+			if !window.OnDay(day.Weekday()) {
+				continue
+			}
+			start, end := windowOn(day, window)
+			spans = append(spans, timespan.New(start, end))
+		}
+	}
+	firstYear := span.Start().In(loc).Year() - 1
+	lastYear := span.End().In(loc).Year()
+	for _, expr := range tbds.Band.Dates {
+		dates, err := companyDayDates(expr, firstYear, lastYear, loc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse date %q of time band %s, err: %s", expr, tbds.Band.Name, err.Error())
+		}
+		for _, date := range dates {
+			spans = append(spans, timespan.New(date, date.AddDate(0, 0, 1)))
+		}
+	}
+	return timespan.MergeSpans(spans), nil
+}
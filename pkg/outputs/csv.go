@@ -9,6 +9,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/leosunmo/pagertally/pkg/timespan"
 )
 
 // CSVOutputter outputs one CSV file per schedule to the filesystem
@@ -18,6 +20,15 @@ type CSVOutputter struct {
 
 type csvFile [][]string
 
+// csvAttributeHeaders are the CSV headers of the built in on-call attributes
+var csvAttributeHeaders = attributeHeaders{
+	timespan.Business:    "BusinessHours",
+	timespan.AfterHours:  "AfterHours",
+	timespan.Weekend:     "Weekend",
+	timespan.StatHoliday: "StatDays",
+	timespan.CompanyDay:  "CompanyDays",
+}
+
 // NewCSVOutputter returns a new CSV outputter
 func NewCSVOutputter(outLocation string) *CSVOutputter {
 	return &CSVOutputter{
@@ -37,18 +48,20 @@ func (c *CSVOutputter) Print(data OutputData) error {
 		}
 		defer oFile.Close()
 		// Add headers
-		headers := []interface{}{"User", "BusinessHours", "AfterHours", "Weekend", "StatDays", "CompanyDays", "Total"}
+		headers := []interface{}{"User"}
+		headers = append(headers, data.durationHeaders(csvAttributeHeaders)...)
+		headers = append(headers, "Total")
+		headers = append(headers, data.extraHeaders(csvAttributeHeaders)...)
 		csvFile.addRow(headers)
+		var total ShiftsSummary
 		for _, shift := range sched.UserShifts {
 			csvRow := make([]interface{}, 0)
-			csvRow = append(csvRow, shift.User.Name)
-			csvRow = append(csvRow, shift.Durations.Business)
-			csvRow = append(csvRow, shift.Durations.AfterHours)
-			csvRow = append(csvRow, shift.Durations.Weekend)
-			csvRow = append(csvRow, shift.Durations.Stat)
-			csvRow = append(csvRow, shift.Durations.CompanyDay)
+			csvRow = append(csvRow, data.userLabel(shift.User))
+			csvRow = append(csvRow, data.durationColumns(shift.Durations)...)
 			csvRow = append(csvRow, shift.Durations.OnCall)
+			csvRow = append(csvRow, data.extraColumns(shift)...)
 			csvFile.addRow(csvRow)
+			total = total.Add(shift)
 		}
 
 		// Semi hack to sort by username but avoiding the headers
@@ -64,14 +77,93 @@ func (c *CSVOutputter) Print(data OutputData) error {
 			return false
 		})
 
-		// Send to the csv writer
-		writer := csv.NewWriter(oFile)
-		defer writer.Flush()
-		for _, finalRow := range csvFile {
-			err := writer.Write(finalRow)
-			if err != nil {
-				return fmt.Errorf("Failed to write line to CSV: %s", err.Error())
-			}
+		// Add the totals after sorting so they stay at the bottom
+		if data.Compensation.Enabled() {
+			totalRow := []interface{}{"Total"}
+			totalRow = append(totalRow, data.durationColumns(total.Durations)...)
+			totalRow = append(totalRow, total.Durations.OnCall)
+			totalRow = append(totalRow, data.extraColumns(total)...)
+			csvFile.addRow(totalRow)
+		}
+
+		if err := csvFile.write(oFile); err != nil {
+			return err
+		}
+
+		// Write the overrides to a separate file so shift swaps can be audited
+		if err := c.printOverrides(data, sched); err != nil {
+			return err
+		}
+		if err := c.printDetails(data, sched); err != nil {
+			return err
+		}
+	}
+	return c.printOverlaps(data)
+}
+
+// printOverlaps outputs a CSV file listing every time a user was on call for more than one schedule at once, if any
+func (c *CSVOutputter) printOverlaps(data OutputData) error {
+	if len(data.Overlaps) == 0 {
+		return nil
+	}
+	var csvFile csvFile
+	csvFile.addRow(overlapHeaders())
+	for _, overlap := range data.Overlaps {
+		csvFile.addRow(data.overlapRow(overlap))
+	}
+	oFile, err := os.Create(filepath.Clean(c.outputLocation + "overlaps.csv"))
+	if err != nil {
+		return fmt.Errorf("Failed to create CSV output file on filesystem: %s", err.Error())
+	}
+	defer oFile.Close()
+	return csvFile.write(oFile)
+}
+
+// printOverrides outputs a CSV file listing who covered for whom if the schedule has any overrides
+func (c *CSVOutputter) printOverrides(data OutputData, sched Schedule) error {
+	overrides := sched.Overrides()
+	if len(overrides) == 0 {
+		return nil
+	}
+	var csvFile csvFile
+	csvFile.addRow(overrideHeaders())
+	for _, override := range overrides {
+		csvFile.addRow(data.overrideRow(override))
+	}
+	normalisedName := strings.Replace(strings.ToLower(sched.Name), " ", "_", -1) + "_overrides.csv"
+	oFile, err := os.Create(filepath.Clean(c.outputLocation + normalisedName))
+	if err != nil {
+		return fmt.Errorf("Failed to create CSV output file on filesystem: %s", err.Error())
+	}
+	defer oFile.Close()
+	return csvFile.write(oFile)
+}
+
+// printDetails outputs a CSV file listing every attributed span of every shift on the schedule,
+// so the summary can be audited line by line
+func (c *CSVOutputter) printDetails(data OutputData, sched Schedule) error {
+	var csvFile csvFile
+	csvFile.addRow(data.lineItemHeaders())
+	for _, row := range data.lineItemRows(sched) {
+		csvFile.addRow(row)
+	}
+	normalisedName := strings.Replace(strings.ToLower(sched.Name), " ", "_", -1) + "_details.csv"
+	oFile, err := os.Create(filepath.Clean(c.outputLocation + normalisedName))
+	if err != nil {
+		return fmt.Errorf("Failed to create CSV output file on filesystem: %s", err.Error())
+	}
+	defer oFile.Close()
+	return csvFile.write(oFile)
+}
+
+// write sends all rows to a csv writer for oFile
+func (cf csvFile) write(oFile *os.File) error {
+	writer := csv.NewWriter(oFile)
+	defer writer.Flush()
+	for _, finalRow := range cf {
+		err := writer.Write(finalRow)
+		if err != nil {
+			return fmt.Errorf("Failed to write line to CSV: %s", err.Error())
 		}
 	}
 	return nil
@@ -0,0 +1,342 @@
+package outputs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/compensation"
+	"github.com/leosunmo/pagertally/pkg/timespan"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ExportSchemaVersion is the version of the JSON and YAML export schema. It's only increased when fields
+// are removed or change meaning, new fields may be added to a version at any time
+const ExportSchemaVersion = 1
+
+// Machine readable export formats
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// ExportOutputter outputs the full data as a single JSON or YAML document, for other programs to read
+type ExportOutputter struct {
+	// path is the file the export is written to, stdout if it's "-"
+	path   string
+	format string
+}
+
+// NewJSONOutputter returns a new outputter writing the export as JSON to path, or stdout if path is "-"
+func NewJSONOutputter(path string) *ExportOutputter {
+	return &ExportOutputter{path: path, format: FormatJSON}
+}
+
+// NewYAMLOutputter returns a new outputter writing the export as YAML to path, or stdout if path is "-"
+func NewYAMLOutputter(path string) *ExportOutputter {
+	return &ExportOutputter{path: path, format: FormatYAML}
+}
+
+// Print writes the export of the data to the outputter's file
+func (r *ExportOutputter) Print(data OutputData) error {
+	if r.path == "-" {
+		return r.write(os.Stdout, data)
+	}
+	oFile, err := os.Create(r.path)
+	if err != nil {
+		return fmt.Errorf("Failed to create %s export file on filesystem: %s", r.format, err.Error())
+	}
+	defer oFile.Close()
+	return r.write(oFile, data)
+}
+
+// write encodes the export of the data to w
+func (r *ExportOutputter) write(w io.Writer, data OutputData) error {
+	export := NewExport(data)
+	switch r.format {
+	case FormatYAML:
+		out, err := yaml.Marshal(export)
+		if err != nil {
+			return fmt.Errorf("Failed to encode YAML export: %s", err.Error())
+		}
+		_, err = w.Write(out)
+		return err
+	default:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(export); err != nil {
+			return fmt.Errorf("Failed to encode JSON export: %s", err.Error())
+		}
+		return nil
+	}
+}
+
+// Export is the machine readable form of OutputData. Times are RFC 3339, durations are in seconds
+// and attributes are named by their config names, e.g. "after_hours"
+type Export struct {
+	SchemaVersion int              `json:"schema_version" yaml:"schema_version"`
+	Period        ExportPeriod     `json:"period" yaml:"period"`
+	Currency      string           `json:"currency,omitempty" yaml:"currency,omitempty"`
+	Schedules     []ExportSchedule `json:"schedules" yaml:"schedules"`
+	Overlaps      []ExportOverlap  `json:"overlaps" yaml:"overlaps"`
+}
+
+// ExportPeriod is the reporting period
+type ExportPeriod struct {
+	Name  string `json:"name" yaml:"name"`
+	Start string `json:"start" yaml:"start"`
+	End   string `json:"end" yaml:"end"`
+}
+
+// ExportSchedule is a schedule and the users on call for it
+type ExportSchedule struct {
+	Name            string       `json:"name" yaml:"name"`
+	EscalationLevel int          `json:"escalation_level" yaml:"escalation_level"`
+	Users           []ExportUser `json:"users" yaml:"users"`
+}
+
+// ExportUser is the time a user was on call for a schedule, and what they're owed for it
+type ExportUser struct {
+	ID             string          `json:"id" yaml:"id"`
+	Name           string          `json:"name" yaml:"name"`
+	Email          string          `json:"email" yaml:"email"`
+	EmployeeNumber string          `json:"employee_number,omitempty" yaml:"employee_number,omitempty"`
+	Timezone       string          `json:"timezone,omitempty" yaml:"timezone,omitempty"`
+	Durations      ExportDurations `json:"durations" yaml:"durations"`
+	// ConcurrentSeconds is the time on call while also on call for a lower escalation level
+	ConcurrentSeconds float64  `json:"concurrent_seconds" yaml:"concurrent_seconds"`
+	CompanyDays       int      `json:"company_days" yaml:"company_days"`
+	CompanyDayNames   []string `json:"company_day_names" yaml:"company_day_names"`
+	// Amounts, AllowanceDays, Allowances and Callouts are only set if compensation is enabled
+	Amounts       map[string]float64 `json:"amounts,omitempty" yaml:"amounts,omitempty"`
+	AllowanceDays map[string]int     `json:"allowance_days,omitempty" yaml:"allowance_days,omitempty"`
+	Allowances    map[string]float64 `json:"allowances,omitempty" yaml:"allowances,omitempty"`
+	Callouts      map[string]float64 `json:"callouts,omitempty" yaml:"callouts,omitempty"`
+	// Incidents is only set if incidents were fetched
+	Incidents *ExportIncidents `json:"incidents,omitempty" yaml:"incidents,omitempty"`
+	Shifts    []ExportShift    `json:"shifts" yaml:"shifts"`
+	Overrides []ExportOverride `json:"overrides" yaml:"overrides"`
+}
+
+// ExportDurations are the time on call in total and per on-call attribute, in seconds
+type ExportDurations struct {
+	OnCallSeconds float64            `json:"on_call_seconds" yaml:"on_call_seconds"`
+	ByAttribute   map[string]float64 `json:"by_attribute" yaml:"by_attribute"`
+}
+
+// ExportIncidents are the incidents a user was called out for and the time spent engaged in them
+type ExportIncidents struct {
+	Count       int             `json:"count" yaml:"count"`
+	ByAttribute map[string]int  `json:"by_attribute" yaml:"by_attribute"`
+	Engaged     ExportDurations `json:"engaged" yaml:"engaged"`
+}
+
+// ExportShift is a single shift and the attributed spans it's made up of
+type ExportShift struct {
+	// ID identifies the shift in the detail CSVs and sheets
+	ID              string       `json:"id" yaml:"id"`
+	Start           string       `json:"start" yaml:"start"`
+	End             string       `json:"end" yaml:"end"`
+	DurationSeconds float64      `json:"duration_seconds" yaml:"duration_seconds"`
+	Spans           []ExportSpan `json:"spans" yaml:"spans"`
+}
+
+// ExportSpan is a part of a shift with a single on-call attribute
+type ExportSpan struct {
+	Start           string  `json:"start" yaml:"start"`
+	End             string  `json:"end" yaml:"end"`
+	DurationSeconds float64 `json:"duration_seconds" yaml:"duration_seconds"`
+	Attribute       string  `json:"attribute" yaml:"attribute"`
+	// Name is the name of what the span was attributed to, like a named company day
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Stacked are the other attributes of the span when attributes are stacked
+	Stacked []string `json:"stacked,omitempty" yaml:"stacked,omitempty"`
+}
+
+// ExportOverride is an override that put a user on call
+type ExportOverride struct {
+	Start      string `json:"start" yaml:"start"`
+	End        string `json:"end" yaml:"end"`
+	CoveredFor string `json:"covered_for,omitempty" yaml:"covered_for,omitempty"`
+}
+
+// ExportOverlap is time a user was on call for more than one schedule at once
+type ExportOverlap struct {
+	Start     string   `json:"start" yaml:"start"`
+	End       string   `json:"end" yaml:"end"`
+	UserID    string   `json:"user_id" yaml:"user_id"`
+	User      string   `json:"user" yaml:"user"`
+	Schedules []string `json:"schedules" yaml:"schedules"`
+	// CountedOn is the schedule the time was counted on, empty if it was counted on all of them
+	CountedOn string `json:"counted_on,omitempty" yaml:"counted_on,omitempty"`
+}
+
+// NewExport returns the machine readable export of the data, with schedules sorted by name
+// and users sorted by their label
+func NewExport(data OutputData) Export {
+	export := Export{
+		SchemaVersion: ExportSchemaVersion,
+		Period: ExportPeriod{
+			Name:  data.PeriodName,
+			Start: exportTime(data.DateRange.Start()),
+			End:   exportTime(data.DateRange.End()),
+		},
+		Schedules: []ExportSchedule{},
+		Overlaps:  []ExportOverlap{},
+	}
+	if data.Compensation.Enabled() {
+		export.Currency = data.Compensation.Currency
+	}
+	for _, sched := range data.Schedules {
+		schedule := ExportSchedule{Name: sched.Name, EscalationLevel: sched.EscalationLevel, Users: []ExportUser{}}
+		for _, summary := range sched.UserShifts {
+			schedule.Users = append(schedule.Users, data.exportUser(summary))
+		}
+		sort.SliceStable(schedule.Users, func(i, j int) bool {
+			return schedule.Users[i].label(data) < schedule.Users[j].label(data)
+		})
+		export.Schedules = append(export.Schedules, schedule)
+	}
+	sort.SliceStable(export.Schedules, func(i, j int) bool {
+		return export.Schedules[i].Name < export.Schedules[j].Name
+	})
+	for _, overlap := range data.Overlaps {
+		schedules := []string{}
+		for _, schedule := range overlap.Schedules {
+			schedules = append(schedules, string(schedule))
+		}
+		export.Overlaps = append(export.Overlaps, ExportOverlap{
+			Start:     exportTime(overlap.Start()),
+			End:       exportTime(overlap.End()),
+			UserID:    overlap.User.ID,
+			User:      data.userLabel(data.UserLabels.userDetails(overlap.User)),
+			Schedules: schedules,
+			CountedOn: string(overlap.CountedOn),
+		})
+	}
+	return export
+}
+
+// label returns what identifies the user in the user column of the other outputs
+func (u ExportUser) label(data OutputData) string {
+	return data.userLabel(UserDetails{Name: u.Name, Email: u.Email, EmployeeNumber: u.EmployeeNumber})
+}
+
+// exportUser returns the export of a user's summary
+func (data OutputData) exportUser(summary ShiftsSummary) ExportUser {
+	user := ExportUser{
+		ID:                summary.User.ID,
+		Name:              summary.User.Name,
+		Email:             summary.User.Email,
+		EmployeeNumber:    summary.User.EmployeeNumber,
+		Durations:         exportDurations(summary.Durations, data.Registry),
+		ConcurrentSeconds: summary.Concurrent.Seconds(),
+		CompanyDays:       summary.CompanyDays,
+		CompanyDayNames:   append([]string{}, summary.CompanyDayNames...),
+		Shifts:            exportShifts(summary.User, summary.AttributedShifts, data.Registry),
+		Overrides:         []ExportOverride{},
+	}
+	if summary.User.Timezone != nil {
+		user.Timezone = summary.User.Timezone.String()
+	}
+	if data.Compensation.Enabled() {
+		user.Amounts = exportAmounts(summary.Amounts, data.Registry)
+		user.AllowanceDays = map[string]int{}
+		for attr, days := range summary.AllowanceDays {
+			user.AllowanceDays[data.Registry.Name(attr)] = days
+		}
+		user.Allowances = exportAmounts(summary.Allowances, data.Registry)
+		user.Callouts = exportAmounts(summary.Callouts, data.Registry)
+	}
+	if data.Incidents {
+		incidents := &ExportIncidents{
+			Count:       summary.Incidents.Count,
+			ByAttribute: map[string]int{},
+			Engaged:     exportDurations(summary.Incidents.Engaged, data.Registry),
+		}
+		for attr, count := range summary.Incidents.ByAttribute {
+			incidents.ByAttribute[data.Registry.Name(attr)] = count
+		}
+		user.Incidents = incidents
+	}
+	for _, override := range summary.Overrides {
+		user.Overrides = append(user.Overrides, ExportOverride{
+			Start:      exportTime(override.Start()),
+			End:        exportTime(override.End()),
+			CoveredFor: override.CoveredFor.Name,
+		})
+	}
+	return user
+}
+
+// exportShifts returns every shift of the attributed shifts with its attributed spans, sorted by start.
+// Attributes are named by names
+func exportShifts(user UserDetails, attributedShifts []AttributedShiftSpans, names *timespan.Attributes) []ExportShift {
+	shifts := []ExportShift{}
+	for _, attributedShift := range attributedShifts {
+		for shift, attrSpans := range attributedShift {
+			exportShift := ExportShift{
+				ID:              shiftID(user.ID, shift),
+				Start:           exportTime(shift.Start()),
+				End:             exportTime(shift.End()),
+				DurationSeconds: shift.Duration().Seconds(),
+				Spans:           []ExportSpan{},
+			}
+			for _, attrSpan := range attrSpans {
+				exportShift.Spans = append(exportShift.Spans, exportSpan(attrSpan, names))
+			}
+			sort.SliceStable(exportShift.Spans, func(i, j int) bool {
+				return exportShift.Spans[i].Start < exportShift.Spans[j].Start
+			})
+			shifts = append(shifts, exportShift)
+		}
+	}
+	sort.SliceStable(shifts, func(i, j int) bool {
+		return shifts[i].Start < shifts[j].Start
+	})
+	return shifts
+}
+
+// exportSpan returns the export of a single attributed span, with its attributes named by names
+func exportSpan(attrSpan timespan.AttributedSpan, names *timespan.Attributes) ExportSpan {
+	span := ExportSpan{
+		Start:           exportTime(attrSpan.Start()),
+		End:             exportTime(attrSpan.End()),
+		DurationSeconds: attrSpan.Duration().Seconds(),
+		Attribute:       names.Name(attrSpan.SpanType),
+		Name:            attrSpan.Name,
+	}
+	for _, attr := range attrSpan.Stacked {
+		span.Stacked = append(span.Stacked, names.Name(attr))
+	}
+	return span
+}
+
+// exportDurations returns the durations in seconds by attribute name in names
+func exportDurations(durations TypeDurations, names *timespan.Attributes) ExportDurations {
+	export := ExportDurations{
+		OnCallSeconds: durations.OnCall.Seconds(),
+		ByAttribute:   map[string]float64{},
+	}
+	for attr, dur := range durations.ByAttribute {
+		export.ByAttribute[names.Name(attr)] = dur.Seconds()
+	}
+	return export
+}
+
+// exportAmounts returns the amounts by attribute name in names
+func exportAmounts(amounts compensation.Amounts, names *timespan.Attributes) map[string]float64 {
+	export := map[string]float64{}
+	for attr, amount := range amounts {
+		export[names.Name(attr)] = amount
+	}
+	return export
+}
+
+// exportTime formats t for the export. Times are UTC, as users may be in different timezones
+func exportTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
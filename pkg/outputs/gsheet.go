@@ -3,7 +3,6 @@ package outputs
 import (
 	"fmt"
 	"io/ioutil"
-	"log"
 	"sort"
 	"strconv"
 	"strings"
@@ -22,6 +21,7 @@ type GSheetOutputter struct {
 	spreadsheet   *sheets.Spreadsheet
 	sheetName     string
 	startCoord    string
+	saFile        string
 	client        *sheets.Service
 }
 
@@ -37,49 +37,69 @@ func NewGSheetOutputter(spreadsheetID string, saFile string) *GSheetOutputter {
 	return &GSheetOutputter{
 		spreadsheetID: spreadsheetID,
 		startCoord:    "A1",
-		client:        getSheetClient(saFile),
+		saFile:        saFile,
 	}
 }
 
 // Print outputs the [][]interface{} to the Google Sheet ID provided
 func (g *GSheetOutputter) Print(data OutputData) error {
 	var err error
+	if g.client == nil {
+		g.client, err = getSheetClient(g.saFile)
+		if err != nil {
+			return err
+		}
+	}
 	err = g.getSpreadsheetFromID()
 	if err != nil {
 		return fmt.Errorf("unable to retrive spreadsheet with id %s, err: %s", g.spreadsheetID, err)
 	}
 
-	// Find the dates involved and name the sheet
+	// Find the period involved and name the sheet
 	g.findMonth(data)
+	summaryName := g.sheetName
 
 	// Replace with a tidy function that builds a value range from the new data format
 	sheetValues, err := outputDataToSheetData(data)
 	if err != nil {
 		return err
 	}
+	if err = g.printTable(sheetValues); err != nil {
+		return err
+	}
+
+	// The line items go in a companion sheet so the summary can be audited
+	g.sheetName = summaryName + " details"
+	detailValues, err := outputDataToDetailSheetData(data)
+	if err != nil {
+		return err
+	}
+	return g.printTable(detailValues)
+}
+
+// printTable writes the table to the sheet named sheetName, adding the sheet if it doesn't exist
+func (g *GSheetOutputter) printTable(sheetValues sheetData) error {
 	var vr sheets.ValueRange
 	vr.Values = sheetValues.table
 
-	// fixup end
-
 	sheet, sheetError := g.findOrAddSheet()
 	if sheetError != nil {
 		return sheetError
 	}
 
-	_, err = g.client.Spreadsheets.Values.Update(g.spreadsheetID, g.sheetName+"!"+g.startCoord, &vr).ValueInputOption("USER_ENTERED").Do()
+	_, err := g.client.Spreadsheets.Values.Update(g.spreadsheetID, g.sheetName+"!"+g.startCoord, &vr).ValueInputOption("USER_ENTERED").Do()
 	if err != nil {
 		return err
 	}
-	err = g.addBandedRange(sheetValues, sheet)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return g.addBandedRange(sheetValues, sheet)
 }
 
+// findMonth names the sheet after the reporting period, falling back to the month it starts in
 func (g *GSheetOutputter) findMonth(data OutputData) error {
+	if data.PeriodName != "" {
+		g.sheetName = data.PeriodName
+		return nil
+	}
 	g.sheetName = data.DateRange.Start().Month().String() + " " + strconv.Itoa(data.DateRange.Start().Year())
 	return nil
 }
@@ -101,88 +121,158 @@ func outputDataToSheetData(data OutputData) (sheetData, error) {
 	return sheetData, nil
 }
 
-func (s *sheetData) buildTable(data OutputData) error {
-	var table sheetTable
+// outputDataToDetailSheetData returns the sheet data of the line items of every schedule
+func outputDataToDetailSheetData(data OutputData) (sheetData, error) {
+	sheetData := sheetData{}
+	err := sheetData.buildDetailTable(data)
+	return sheetData, err
+}
+
+// scheduleNames returns the names of all schedules, sorted
+func (data OutputData) scheduleNames() []string {
 	var schedules []string
 	for _, sched := range data.Schedules {
 		schedules = append(schedules, sched.Name)
 	}
 	sort.Strings(schedules)
+	return schedules
+}
+
+// buildDetailTable builds a table of the line items of every schedule, sorted by schedule, user and start
+func (s *sheetData) buildDetailTable(data OutputData) error {
+	var table sheetTable
+	// Add Schedules at the top, like the summary
+	table.addRow([]interface{}{strings.Join(data.scheduleNames(), " & ")})
+	table.addRow(data.lineItemHeaders())
+	schedules := append([]Schedule{}, data.Schedules...)
+	sort.SliceStable(schedules, func(i, j int) bool {
+		return schedules[i].Name < schedules[j].Name
+	})
+	for _, sched := range schedules {
+		for _, row := range data.lineItemRows(sched) {
+			if err := table.addRow(row); err != nil {
+				return fmt.Errorf("unable to convert data to sheetdata, err: %s", err)
+			}
+		}
+	}
+	s.table = table
+	return nil
+}
+
+func (s *sheetData) buildTable(data OutputData) error {
+	var table sheetTable
 	// Add Schedules at the top
 	schedulesString := make([]interface{}, 1)
-	schedulesString[0] = strings.Join(schedules, " & ")
+	schedulesString[0] = strings.Join(data.scheduleNames(), " & ")
 	table.addRow(schedulesString)
-	// Add headers
-	headers := []interface{}{"User", "BusinessHours", "AfterHours", "Weekend", "StatDays", "CompanyDays", "Total"}
+	headers, rows, totalRow := data.combinedSummary()
 	table.addRow(headers)
+	for _, tableRow := range rows {
+		err := table.addRow(tableRow)
+		if err != nil {
+			return fmt.Errorf("unable to convert data to sheetdata, err: %s", err)
+		}
+	}
+	// Add the grand total after the sorted users so it stays at the bottom
+	if data.Compensation.Enabled() {
+		err := table.addRow(totalRow)
+		if err != nil {
+			return fmt.Errorf("unable to convert data to sheetdata, err: %s", err)
+		}
+	}
+	// After we've added headers, extracted users and their on-call duration and sorted users, add to sheet
+	s.table = table
+	return nil
+}
 
-	// Crunch the user data per schedule and combine in to one table
-	userDurations := map[string]TypeDurations{}
+// combinedSummary returns the headers, rows and total row of the time of every user across all schedules, sorted by user.
+// Primary and secondary time are kept apart if there are escalation levels. Columns are unformatted, so each spreadsheet
+// can format them its own way
+func (data OutputData) combinedSummary() ([]interface{}, [][]interface{}, []interface{}) {
+	headers := []interface{}{"User"}
+	if data.HasEscalationLevels() {
+		headers = append(headers, "Level")
+	}
+	headers = append(headers, data.durationHeaders(csvAttributeHeaders)...)
+	headers = append(headers, "Total")
+	headers = append(headers, data.extraHeaders(csvAttributeHeaders)...)
+
+	// Crunch the user data per schedule and combine in to one table,
+	// keeping primary and secondary time apart if there are escalation levels
+	type userLevel struct {
+		userID string
+		level  string
+	}
+	userSummaries := map[userLevel]ShiftsSummary{}
 	for _, sched := range data.Schedules {
+		level := levelGroup(sched.EscalationLevel)
 		for _, userSummary := range sched.UserShifts {
-			if durs, exists := userDurations[userSummary.User.Name]; exists {
-				newDurs := TypeDurations{
-					OnCall:     durs.OnCall + userSummary.Durations.OnCall,
-					Business:   durs.Business + userSummary.Durations.Business,
-					AfterHours: durs.AfterHours + userSummary.Durations.AfterHours,
-					Weekend:    durs.Weekend + userSummary.Durations.Weekend,
-					Stat:       durs.Stat + userSummary.Durations.Stat,
-					CompanyDay: durs.CompanyDay + userSummary.Durations.CompanyDay,
-				}
-				userDurations[userSummary.User.Name] = newDurs
-			} else {
-				userDurations[userSummary.User.Name] = userSummary.Durations
-			}
+			key := userLevel{userSummary.User.ID, level}
+			userSummaries[key] = userSummaries[key].Add(userSummary)
 		}
 	}
 
-	for user, durs := range userDurations {
+	var total ShiftsSummary
+	rows := [][]interface{}{}
+	for key, summary := range userSummaries {
+		durs := summary.Durations
 		tableRow := make([]interface{}, 0)
-		tableRow = append(tableRow, user)
-		tableRow = append(tableRow, durs.Business)
-		tableRow = append(tableRow, durs.AfterHours)
-		tableRow = append(tableRow, durs.Weekend)
-		tableRow = append(tableRow, durs.Stat)
-		tableRow = append(tableRow, durs.CompanyDay)
-		tableRow = append(tableRow, durs.OnCall)
-		err := table.addRow(tableRow)
-		if err != nil {
-			return fmt.Errorf("unable to convert data to sheetdata, err: %s", err)
+		tableRow = append(tableRow, data.userLabel(summary.User))
+		if data.HasEscalationLevels() {
+			tableRow = append(tableRow, key.level)
 		}
-	}
-	// Sort the usernames in the table, minus the schedules and headers
-	sort.SliceStable(table[2:], func(i, j int) bool {
-		i += 2
-		j += 2
-		for x := range table[i] {
-			if table[i][x].(string) == table[j][x].(string) {
+		tableRow = append(tableRow, data.durationColumns(durs)...)
+		tableRow = append(tableRow, durs.OnCall)
+		tableRow = append(tableRow, data.extraColumns(summary)...)
+		rows = append(rows, tableRow)
+		total = total.Add(summary)
+	}
+	// Sort the usernames in the table
+	sort.SliceStable(rows, func(i, j int) bool {
+		for x := range rows[i] {
+			if fmt.Sprint(rows[i][x]) == fmt.Sprint(rows[j][x]) {
 				continue
 			}
-			return table[i][x].(string) < table[j][x].(string)
+			return fmt.Sprint(rows[i][x]) < fmt.Sprint(rows[j][x])
 		}
 		return false
 	})
-	// After we've added headers, extracted users and their on-call duration and sorted users, add to sheet
-	s.table = table
-	return nil
+
+	totalRow := []interface{}{"Total"}
+	if data.HasEscalationLevels() {
+		totalRow = append(totalRow, "")
+	}
+	totalRow = append(totalRow, data.durationColumns(total.Durations)...)
+	totalRow = append(totalRow, total.Durations.OnCall)
+	totalRow = append(totalRow, data.extraColumns(total)...)
+	return headers, rows, totalRow
+}
+
+// levelGroup returns the escalation levels a schedule's time is grouped in to,
+// the primary on-call or any level above it
+func levelGroup(level int) string {
+	if level > 1 {
+		return "2+"
+	}
+	return levelLabel(level)
 }
 
-func getSheetClient(saFile string) *sheets.Service {
+func getSheetClient(saFile string) (*sheets.Service, error) {
 	b, err := ioutil.ReadFile(saFile)
 	if err != nil {
-		log.Fatalf("Unable to read service account secret file: %v", err)
+		return nil, fmt.Errorf("unable to read service account secret file: %v", err)
 	}
 	config, err := google.JWTConfigFromJSON(b, sheets.SpreadsheetsScope)
 	if err != nil {
-		log.Fatalf("Unable to parse client secret file to config: %v", err)
+		return nil, fmt.Errorf("unable to parse client secret file to config: %v", err)
 	}
 	client := config.Client(context.Background())
 
 	srv, err := sheets.New(client)
 	if err != nil {
-		log.Fatalf("Unable to retrieve Sheets client: %v", err)
+		return nil, fmt.Errorf("unable to retrieve Sheets client: %v", err)
 	}
-	return srv
+	return srv, nil
 }
 
 func dataToGridRange(data [][]interface{}, sheetID int64, columnStartOffset, rowStartOffset int64) sheets.GridRange {
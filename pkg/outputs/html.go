@@ -0,0 +1,346 @@
+package outputs
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+// HTMLOutputter outputs a single self-contained HTML page with summary tables and on-call timelines,
+// without any external assets so it can be emailed or archived
+type HTMLOutputter struct {
+	path string
+}
+
+// NewHTMLOutputter returns a new HTML outputter writing the page to path
+func NewHTMLOutputter(path string) *HTMLOutputter {
+	return &HTMLOutputter{path: path}
+}
+
+// attributeColours are the timeline colours of the built in on-call attributes
+var attributeColours = map[timespan.OnCallAttribute]string{
+	timespan.Business:    "#4e79a7",
+	timespan.AfterHours:  "#f28e2b",
+	timespan.Weekend:     "#e15759",
+	timespan.StatHoliday: "#76b7b2",
+	timespan.CompanyDay:  "#59a14f",
+}
+
+// customColours are the timeline colours of user-defined attributes, in the order they were registered
+var customColours = []string{"#edc948", "#b07aa1", "#ff9da7", "#9c755f", "#bab0ac", "#86bcb6", "#d37295"}
+
+// attributeColour returns the timeline colour of the attribute attr
+func (data OutputData) attributeColour(attr timespan.OnCallAttribute) string {
+	if colour, exists := attributeColours[attr]; exists {
+		return colour
+	}
+	custom := 0
+	for _, known := range data.Attributes {
+		if known.IsBuiltIn() {
+			continue
+		}
+		if known == attr {
+			return customColours[custom%len(customColours)]
+		}
+		custom++
+	}
+	return "#999999"
+}
+
+// htmlPage is everything the HTML template prints
+type htmlPage struct {
+	Title     string
+	Period    string
+	Legend    []htmlLegend
+	Schedules []htmlSchedule
+	Overlaps  htmlTable
+	// GrandTotal is the amount owed across all schedules, empty if compensation isn't enabled
+	GrandTotal string
+}
+
+// htmlLegend is the timeline colour of a single attribute
+type htmlLegend struct {
+	Name  string
+	Style template.CSS
+}
+
+// htmlTable is a table with a header, rows and an optional footer
+type htmlTable struct {
+	Headers []string
+	Rows    [][]string
+	Footer  []string
+}
+
+// htmlSchedule is the summary table and timelines of a single schedule
+type htmlSchedule struct {
+	Name    string
+	Summary htmlTable
+	Ticks   []htmlBar
+	// Markers are the stat holidays and company days users were on call for
+	Markers   []htmlBar
+	Timelines []htmlTimeline
+}
+
+// htmlTimeline is the on-call time of a single user across the reporting period
+type htmlTimeline struct {
+	User  string
+	Total string
+	Bars  []htmlBar
+}
+
+// htmlBar is a block positioned on a timeline
+type htmlBar struct {
+	Style template.CSS
+	Label string
+	Title string
+}
+
+// Print writes the HTML page of the data to the outputter's file
+func (h *HTMLOutputter) Print(data OutputData) error {
+	oFile, err := os.Create(h.path)
+	if err != nil {
+		return fmt.Errorf("Failed to create HTML output file on filesystem: %s", err.Error())
+	}
+	defer oFile.Close()
+	return h.write(oFile, data)
+}
+
+// write renders the HTML page of the data to w
+func (h *HTMLOutputter) write(w io.Writer, data OutputData) error {
+	if err := htmlTemplate.Execute(w, data.htmlPage()); err != nil {
+		return fmt.Errorf("Failed to render HTML report: %s", err.Error())
+	}
+	return nil
+}
+
+// htmlPage returns the page of the data, with schedules sorted by name and users by their label
+func (data OutputData) htmlPage() htmlPage {
+	loc := data.DateRange.Start().Location()
+	page := htmlPage{
+		Title:  "On-call report",
+		Period: data.DateRange.Start().Format("2 Jan 2006") + " - " + data.DateRange.End().Add(-time.Nanosecond).In(loc).Format("2 Jan 2006"),
+	}
+	if data.PeriodName != "" {
+		page.Title = "On-call report " + data.PeriodName
+	}
+	for _, attr := range data.Attributes {
+		page.Legend = append(page.Legend, htmlLegend{Name: stdoutAttributeHeaders.header(attr, data.Registry), Style: template.CSS("background:" + data.attributeColour(attr))})
+	}
+
+	schedules := append([]Schedule{}, data.Schedules...)
+	sort.SliceStable(schedules, func(i, j int) bool {
+		return schedules[i].Name < schedules[j].Name
+	})
+	headers := []string{"User"}
+	headers = append(headers, stringRow(data.durationHeaders(stdoutAttributeHeaders))...)
+	headers = append(headers, "Total time")
+	headers = append(headers, stringRow(data.extraHeaders(stdoutAmountHeaders))...)
+	var grandTotal float64
+	for _, sched := range schedules {
+		name := sched.Name
+		if data.HasEscalationLevels() {
+			name = fmt.Sprintf("%s (escalation level %s)", sched.Name, levelLabel(sched.EscalationLevel))
+		}
+		schedule := htmlSchedule{
+			Name:    name,
+			Summary: htmlTable{Headers: headers},
+			Ticks:   data.htmlTicks(),
+			Markers: data.htmlMarkers(sched),
+		}
+		summaries := append([]ShiftsSummary{}, sched.UserShifts...)
+		sort.SliceStable(summaries, func(i, j int) bool {
+			return data.userLabel(summaries[i].User) < data.userLabel(summaries[j].User)
+		})
+		var total ShiftsSummary
+		for _, summary := range summaries {
+			schedule.Summary.Rows = append(schedule.Summary.Rows, data.buildUserRow(summary))
+			schedule.Timelines = append(schedule.Timelines, data.htmlTimeline(summary))
+			total = total.Add(summary)
+		}
+		total.User = UserDetails{Name: "Total"}
+		schedule.Summary.Footer = data.buildUserRow(total)
+		grandTotal += total.TotalAmount()
+		page.Schedules = append(page.Schedules, schedule)
+	}
+	if data.Compensation.Enabled() {
+		page.GrandTotal = amountFormat(grandTotal) + " " + data.Compensation.Currency
+	}
+	if len(data.Overlaps) != 0 {
+		page.Overlaps.Headers = stringRow(overlapHeaders())
+		for _, overlap := range data.Overlaps {
+			page.Overlaps.Rows = append(page.Overlaps.Rows, stringRow(data.overlapRow(overlap)))
+		}
+	}
+	return page
+}
+
+// htmlTimeline returns the timeline of a user's attributed spans, coloured by attribute
+func (data OutputData) htmlTimeline(summary ShiftsSummary) htmlTimeline {
+	timeline := htmlTimeline{User: data.userLabel(summary.User), Total: durationFormat(summary.Durations.OnCall)}
+	loc := summary.User.Timezone
+	for _, item := range summary.LineItems {
+		style, visible := data.htmlPosition(item.Span.Span)
+		if !visible {
+			continue
+		}
+		spanLoc := loc
+		if spanLoc == nil {
+			spanLoc = item.Span.Start().Location()
+		}
+		timeline.Bars = append(timeline.Bars, htmlBar{
+			Style: style + template.CSS(";background:"+data.attributeColour(item.Span.SpanType)),
+			Title: fmt.Sprintf("%s: %s, %s", stdoutAttributeHeaders.spanLabel(item.Span, data.Registry), detailSpanLabel(item.Span.Span, shiftDetailFormat, spanLoc), durationFormat(item.Span.Duration())),
+		})
+	}
+	return timeline
+}
+
+// htmlMarkers returns a marker for every stat holiday and company day any user on the schedule was on call for,
+// including stacked ones
+func (data OutputData) htmlMarkers(sched Schedule) []htmlBar {
+	type marker struct {
+		span timespan.Span
+		attr timespan.OnCallAttribute
+	}
+	names := map[marker][]string{}
+	markers := []marker{}
+	for _, summary := range sched.UserShifts {
+		for _, item := range summary.LineItems {
+			for _, attr := range append([]timespan.OnCallAttribute{item.Span.SpanType}, item.Span.Stacked...) {
+				if attr != timespan.StatHoliday && attr != timespan.CompanyDay {
+					continue
+				}
+				// Markers cover whole days, as holidays and company days are mostly whole days
+				day := timespan.StartOfDay(item.Span.Start().In(data.DateRange.Start().Location()))
+				key := marker{span: timespan.New(day, day.AddDate(0, 0, 1)), attr: attr}
+				if _, exists := names[key]; !exists {
+					markers = append(markers, key)
+					names[key] = []string{}
+				}
+				if item.Span.Name != "" && attr == item.Span.SpanType {
+					names[key] = mergeNames(names[key], []string{item.Span.Name})
+				}
+			}
+		}
+	}
+	bars := []htmlBar{}
+	for _, m := range markers {
+		style, visible := data.htmlPosition(m.span)
+		if !visible {
+			continue
+		}
+		title := stdoutAttributeHeaders.header(m.attr, data.Registry) + " " + m.span.Start().Format("Mon 2 Jan")
+		if len(names[m]) != 0 {
+			title += ": " + strings.Join(names[m], ", ")
+		}
+		bars = append(bars, htmlBar{
+			Style: style + template.CSS(";background:"+data.attributeColour(m.attr)),
+			Title: title,
+		})
+	}
+	return bars
+}
+
+// htmlTicks returns the day markers of the timelines, every day for periods of up to two months and every month for longer ones
+func (data OutputData) htmlTicks() []htmlBar {
+	loc := data.DateRange.Start().Location()
+	start := timespan.StartOfDay(data.DateRange.Start().In(loc))
+	months := data.DateRange.Duration() > 62*24*time.Hour
+	if months {
+		start = time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, loc)
+	}
+	ticks := []htmlBar{}
+	for day := start; day.Before(data.DateRange.End()); {
+		next := day.AddDate(0, 0, 1)
+		label := day.Format("2")
+		if months {
+			next = day.AddDate(0, 1, 0)
+			label = day.Format("Jan")
+		}
+		if style, visible := data.htmlPosition(timespan.New(day, next)); visible {
+			tick := htmlBar{Style: style, Label: label, Title: day.Format("Mon 2 Jan 2006")}
+			if !months && (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday) {
+				tick.Style += ";background:#f3f3f3"
+			}
+			ticks = append(ticks, tick)
+		}
+		day = next
+	}
+	return ticks
+}
+
+// htmlPosition returns the CSS position of span on a timeline of the reporting period as percentages,
+// and false if it's outside the reporting period
+func (data OutputData) htmlPosition(span timespan.Span) (template.CSS, bool) {
+	visible, overlaps := span.Intersection(data.DateRange)
+	if !overlaps || data.DateRange.Duration() == 0 {
+		return "", false
+	}
+	total := float64(data.DateRange.Duration())
+	left := float64(visible.Start().Sub(data.DateRange.Start())) / total * 100
+	width := float64(visible.Duration()) / total * 100
+	return template.CSS(fmt.Sprintf("left:%.4f%%;width:%.4f%%", left, width)), true
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #222; margin: 2em; }
+h1 { margin-bottom: 0.2em; }
+h2 { margin-top: 2em; border-bottom: 1px solid #ddd; padding-bottom: 0.2em; }
+.period { color: #666; margin-top: 0; }
+table { border-collapse: collapse; margin: 1em 0; font-size: 0.9em; }
+th, td { padding: 0.35em 0.8em; text-align: right; white-space: nowrap; }
+th:first-child, td:first-child { text-align: left; }
+thead th { background: #bdbdbd; }
+tbody tr:nth-child(even) { background: #f3f3f3; }
+tfoot td { font-weight: bold; border-top: 2px solid #bdbdbd; }
+.legend span { display: inline-block; margin-right: 1.2em; font-size: 0.9em; }
+.legend i { display: inline-block; width: 0.9em; height: 0.9em; margin-right: 0.3em; vertical-align: middle; }
+.timeline { display: flex; align-items: center; margin: 2px 0; font-size: 0.85em; }
+.timeline .label { width: 14em; flex-shrink: 0; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+.timeline .total { width: 6em; flex-shrink: 0; text-align: right; padding-left: 0.5em; color: #666; }
+.track { position: relative; flex-grow: 1; height: 1.4em; background: #fafafa; border: 1px solid #e5e5e5; }
+.track div { position: absolute; top: 0; bottom: 0; }
+.ticks .track { height: 1.2em; background: none; border: none; }
+.ticks .track div { font-size: 0.75em; color: #888; text-align: center; border-left: 1px solid #e5e5e5; }
+.markers .track { height: 0.6em; background: none; border: none; }
+.grand-total { font-size: 1.2em; font-weight: bold; margin-top: 2em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="period">{{.Period}}</p>
+<p class="legend">{{range .Legend}}<span><i style="{{.Style}}"></i>{{.Name}}</span>{{end}}</p>
+{{range .Schedules}}
+<h2>{{.Name}}</h2>
+<table>
+<thead><tr>{{range .Summary.Headers}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>{{range .Summary.Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>{{end}}</tbody>
+<tfoot><tr>{{range .Summary.Footer}}<td>{{.}}</td>{{end}}</tr></tfoot>
+</table>
+<div class="timeline ticks"><div class="label"></div><div class="track">{{range .Ticks}}<div style="{{.Style}}" title="{{.Title}}">{{.Label}}</div>{{end}}</div><div class="total"></div></div>
+{{if .Markers}}<div class="timeline markers"><div class="label">Holidays &amp; company days</div><div class="track">{{range .Markers}}<div style="{{.Style}}" title="{{.Title}}"></div>{{end}}</div><div class="total"></div></div>{{end}}
+{{range .Timelines}}<div class="timeline"><div class="label" title="{{.User}}">{{.User}}</div><div class="track">{{range .Bars}}<div style="{{.Style}}" title="{{.Title}}"></div>{{end}}</div><div class="total">{{.Total}}</div></div>
+{{end}}
+{{end}}
+{{if .Overlaps.Rows}}
+<h2>Overlapping shifts</h2>
+<table>
+<thead><tr>{{range .Overlaps.Headers}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>{{range .Overlaps.Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>{{end}}</tbody>
+</table>
+{{end}}
+{{if .GrandTotal}}<p class="grand-total">Grand total: {{.GrandTotal}}</p>{{end}}
+</body>
+</html>
+`))
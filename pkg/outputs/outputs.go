@@ -3,8 +3,11 @@ package outputs
 import (
 	"fmt"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/leosunmo/pagertally/pkg/compensation"
 	"github.com/leosunmo/pagertally/pkg/timespan"
 )
 
@@ -17,69 +20,265 @@ type Outputter interface {
 type OutputData struct {
 	RawResults map[string][]timespan.UserShiftResults // RawResults is a map of schedule names to users and their shifts
 	DateRange  timespan.Span
+	PeriodName string // PeriodName is the human readable name of DateRange, e.g. "March 2018"
 	Schedules  []Schedule
+	// Compensation is the configuration used to calculate the amounts owed.
+	// Outputters only print amounts if it's enabled
+	Compensation compensation.Config
+	// Incidents is true if incidents were fetched, outputters only print incident columns if it's set
+	Incidents bool
+	// Attributes are the on-call attributes outputters have columns for, the built in ones followed by the time bands
+	Attributes []timespan.OnCallAttribute
+	// Registry is where the time bands of Attributes were registered, it names them in outputs
+	Registry *timespan.Attributes
+	// UserLabels configures what identifies users in the user column
+	UserLabels UserLabels
+	// Overlaps are all times users were on call for more than one schedule at once
+	Overlaps []timespan.Overlap
+}
+
+// User columns that can identify users in outputs
+const (
+	UserColumnName           = "name"
+	UserColumnEmail          = "email"
+	UserColumnEmployeeNumber = "employee_number"
+)
+
+// UserLabels configures how users are identified in outputs
+type UserLabels struct {
+	// Column is one of the UserColumn constants, defaults to the user's name
+	Column string
+	// EmployeeNumbers maps lower case PagerDuty user IDs or emails to employee numbers
+	EmployeeNumbers map[string]string
 }
 
 type Schedule struct {
-	Name       string
-	UserShifts []ShiftsSummary
+	Name string
+	// EscalationLevel is the escalation level the schedule is on call for, 0 if unknown
+	EscalationLevel int
+	UserShifts      []ShiftsSummary
 }
 
 type ShiftsSummary struct {
 	User             UserDetails
 	AttributedShifts []AttributedShiftSpans
 	Durations        TypeDurations
-	CompanyDays      int
+	Amounts          compensation.Amounts
+	// AllowanceDays is the number of days per attribute that qualified for a daily allowance
+	AllowanceDays map[timespan.OnCallAttribute]int
+	// Allowances is the daily allowance owed per attribute, after the cap has been applied
+	Allowances compensation.Amounts
+	// monthlyAllowances is the daily allowance owed per calendar month before the cap
+	monthlyAllowances compensation.MonthlyAmounts
+	Incidents         IncidentSummary
+	// Callouts is the callout fees owed per attribute
+	Callouts compensation.Amounts
+	// Overrides are the overrides that put the user on call
+	Overrides []timespan.Override
+	// Concurrent is the time the user was on call for this schedule while also on call
+	// for a schedule on a lower escalation level, e.g. while being both primary and secondary
+	Concurrent  time.Duration
+	CompanyDays int
+	// CompanyDayNames are the names of the named company days the user was on call for
+	CompanyDayNames []string
+	// LineItems are the attributed spans of every shift and the amount owed for each, sorted by start
+	LineItems []LineItem
+}
+
+// LineItem is the part of a shift with a single on-call attribute and the hourly amount owed for it
+type LineItem struct {
+	// ShiftID identifies the shift the span is part of, see shiftID
+	ShiftID string
+	Shift   timespan.Span
+	Span    timespan.AttributedSpan
+	Amount  float64
+}
+
+// IncidentSummary is the number of incidents a user was called out for and the time spent engaged in them
+type IncidentSummary struct {
+	Count       int
+	ByAttribute map[timespan.OnCallAttribute]int
+	Engaged     TypeDurations
 }
 
 type UserDetails struct {
-	Name     string
-	Timezone *time.Location
+	ID             string
+	Name           string
+	Email          string
+	EmployeeNumber string
+	Timezone       *time.Location
 }
 
+// TypeDurations are the time on call in total and per on-call attribute
 type TypeDurations struct {
-	OnCall     time.Duration
-	Business   time.Duration
-	AfterHours time.Duration
-	Weekend    time.Duration
-	Stat       time.Duration
-	CompanyDay time.Duration
+	OnCall      time.Duration
+	ByAttribute map[timespan.OnCallAttribute]time.Duration
 }
 
+// attributeHeaders are an outputter's own headers of the built in on-call attributes.
+// User-defined attributes like time bands are headed by the name they were registered with
+type attributeHeaders map[timespan.OnCallAttribute]string
+
 // AttributedShiftSpans is a map of the shift span to it's associated attributed spans
 type AttributedShiftSpans map[timespan.Span]timespan.AttributedSpans
 
-// NewOutputData returns a new OutputData with the final data ready for easy use
-func NewOutputData(results map[string][]timespan.UserShiftResults, startDate, endDate time.Time) OutputData {
+// NewOutputData returns a new OutputData with the final data ready for easy use. It has columns for the on-call
+// attributes of registry, the built in ones if it's nil
+func NewOutputData(results map[string][]timespan.UserShiftResults, startDate, endDate time.Time, periodName string, comp compensation.Config, registry *timespan.Attributes, incidents bool, labels UserLabels) OutputData {
 	data := OutputData{
-		RawResults: results,
-		DateRange:  timespan.New(startDate, endDate),
-		Schedules:  []Schedule{},
+		RawResults:   results,
+		DateRange:    timespan.New(startDate, endDate),
+		PeriodName:   periodName,
+		Schedules:    []Schedule{},
+		Compensation: comp,
+		Incidents:    incidents,
+		Attributes:   registry.All(),
+		Registry:     registry,
+		UserLabels:   labels,
 	}
 
+	lowerLevelShifts := userLevelShifts(results)
 	for schedName, userResults := range results {
 		userShiftSummary := []ShiftsSummary{}
+		level := 0
 		for _, userResult := range userResults {
+			level = userResult.EscalationLevel
+			levelComp := comp.ForLevel(level)
+			schedKey := comp.ScheduleKey(userResult.ScheduleID, schedName)
+			concurrentShifts := lowerLevelShifts.below(userResult.User.ID, level)
+			// Only the time on the lowest level is paid if concurrent levels aren't paid on every level
+			paid := userResult.Breakdown
+			if !comp.PaysConcurrentLevels() {
+				paid = paid.Without(concurrentShifts)
+			}
+			allowanceDays, allowances := levelComp.CalculateAllowances(schedKey, paid, userResult.Location)
 			userShifts := ShiftsSummary{
-				User: UserDetails{
-					Name:     userResult.User.Name,
-					Timezone: userResult.User.Location,
-				},
+				User:             labels.userDetails(userResult.User),
 				AttributedShifts: buildAttributedShiftSpans(userResult),
-				Durations:        buildDurations(userResult),
+				Durations:        spansToDurations(userResult.Breakdown, data.Attributes),
+				Amounts:          levelComp.Calculate(schedKey, paid),
+				AllowanceDays:    allowanceDays,
+				Allowances:       allowances.Total(),
+				Incidents:        buildIncidentSummary(userResult, data.Attributes),
+				Callouts:         levelComp.CalculateCallouts(schedKey, userResult.Incidents),
+				Overrides:        userResult.Overrides,
+				Concurrent:       overlapDur(userResult.Shifts, concurrentShifts),
 				CompanyDays:      userResult.Breakdown.CompanyDayCount(),
+				CompanyDayNames:  userResult.Breakdown.Names(timespan.CompanyDay),
+				LineItems:        buildLineItems(userResult, levelComp, schedKey, concurrentShifts),
 			}
+			userShifts.monthlyAllowances = allowances
 			userShiftSummary = append(userShiftSummary, userShifts)
 		}
 		schedule := Schedule{
-			Name:       schedName,
-			UserShifts: userShiftSummary,
+			Name:            schedName,
+			EscalationLevel: level,
+			UserShifts:      userShiftSummary,
 		}
 		data.Schedules = append(data.Schedules, schedule)
 	}
+	data.capAllowances()
 	return data
 }
 
+// levelShifts are the shifts of each user by user ID and escalation level
+type levelShifts map[string]map[int][]timespan.Span
+
+// userLevelShifts returns the shifts of every user across all schedules by escalation level
+func userLevelShifts(results map[string][]timespan.UserShiftResults) levelShifts {
+	shifts := levelShifts{}
+	for _, userResults := range results {
+		for _, userResult := range userResults {
+			if shifts[userResult.User.ID] == nil {
+				shifts[userResult.User.ID] = map[int][]timespan.Span{}
+			}
+			level := userResult.EscalationLevel
+			shifts[userResult.User.ID][level] = append(shifts[userResult.User.ID][level], userResult.Shifts...)
+		}
+	}
+	return shifts
+}
+
+// below returns the user's shifts on known escalation levels lower than level
+func (ls levelShifts) below(userID string, level int) []timespan.Span {
+	below := []timespan.Span{}
+	for l, shifts := range ls[userID] {
+		if l > 0 && l < level {
+			below = append(below, shifts...)
+		}
+	}
+	return below
+}
+
+// overlapDur returns how much of shifts overlaps any of others
+func overlapDur(shifts, others []timespan.Span) time.Duration {
+	var total, remaining time.Duration
+	for _, shift := range shifts {
+		total += shift.Duration()
+	}
+	for _, shift := range timespan.Subtract(shifts, others) {
+		remaining += shift.Duration()
+	}
+	return total - remaining
+}
+
+// userDetails returns the details of user, including the employee number mapped from its ID or email
+func (l UserLabels) userDetails(user timespan.User) UserDetails {
+	employeeNumber, exists := l.EmployeeNumbers[strings.ToLower(user.ID)]
+	if !exists {
+		employeeNumber = l.EmployeeNumbers[strings.ToLower(user.Email)]
+	}
+	return UserDetails{
+		ID:             user.ID,
+		Name:           user.Name,
+		Email:          user.Email,
+		EmployeeNumber: employeeNumber,
+		Timezone:       user.Location,
+	}
+}
+
+// Label returns what identifies the user in the user column of the outputs
+func (l UserLabels) Label(user timespan.User) string {
+	return OutputData{UserLabels: l}.userLabel(l.userDetails(user))
+}
+
+// userLabel returns what identifies the user in the user column,
+// falling back to the name if the user doesn't have the configured detail
+func (data OutputData) userLabel(user UserDetails) string {
+	var label string
+	switch data.UserLabels.Column {
+	case UserColumnEmail:
+		label = user.Email
+	case UserColumnEmployeeNumber:
+		label = user.EmployeeNumber
+	}
+	if label == "" {
+		return user.Name
+	}
+	return label
+}
+
+// capAllowances applies the monthly allowance cap to each user's allowances across all schedules
+func (data *OutputData) capAllowances() {
+	type summaryIndex struct{ sched, user int }
+	userSummaries := map[string][]summaryIndex{}
+	for i, sched := range data.Schedules {
+		for j, summary := range sched.UserShifts {
+			userSummaries[summary.User.ID] = append(userSummaries[summary.User.ID], summaryIndex{i, j})
+		}
+	}
+	for _, indexes := range userSummaries {
+		allowances := []compensation.MonthlyAmounts{}
+		for _, idx := range indexes {
+			allowances = append(allowances, data.Schedules[idx.sched].UserShifts[idx.user].monthlyAllowances)
+		}
+		capped := data.Compensation.CapAllowances(allowances)
+		for k, idx := range indexes {
+			data.Schedules[idx.sched].UserShifts[idx.user].Allowances = capped[k]
+		}
+	}
+}
+
 // PrintOutput runs the Print methods on all provided outputters
 func (data OutputData) PrintOutput(outputs []Outputter) []error {
 	var errors []error
@@ -95,6 +294,43 @@ func (data OutputData) PrintOutput(outputs []Outputter) []error {
 	return nil
 }
 
+// buildLineItems returns the attributed spans of every shift with the hourly amount owed for them on the schedule,
+// leaving out the time on call for a lower escalation level unless concurrent levels are paid on every level
+func buildLineItems(results timespan.UserShiftResults, comp compensation.Config, schedule string, concurrentShifts []timespan.Span) []LineItem {
+	items := []LineItem{}
+	for _, shift := range results.Shifts {
+		id := shiftID(results.User.ID, shift)
+		for _, attrSpan := range results.Breakdown {
+			// Attributed spans can continue into the next shift if the shifts are back to back
+			span, overlaps := attrSpan.Span.Intersection(shift)
+			if !overlaps {
+				continue
+			}
+			attrSpan.Span = span
+			paid := timespan.AttributedSpans{attrSpan}
+			if !comp.PaysConcurrentLevels() {
+				paid = paid.Without(concurrentShifts)
+			}
+			items = append(items, LineItem{
+				ShiftID: id,
+				Shift:   shift,
+				Span:    attrSpan,
+				Amount:  comp.Calculate(schedule, paid).Total(),
+			})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Span.Start().Before(items[j].Span.Start())
+	})
+	return items
+}
+
+// shiftID returns an ID of the user's shift that stays the same between runs, as PagerDuty doesn't have one,
+// e.g. "PABC123-20190104T0900Z"
+func shiftID(userID string, shift timespan.Span) string {
+	return userID + "-" + shift.Start().UTC().Format("20060102T1504Z")
+}
+
 func buildAttributedShiftSpans(shiftResults timespan.UserShiftResults) []AttributedShiftSpans {
 	output := []AttributedShiftSpans{}
 	// Iterate over all the shift spans and find it's attributed spans
@@ -118,16 +354,410 @@ func buildAttributedShiftSpans(shiftResults timespan.UserShiftResults) []Attribu
 	return output
 }
 
-func buildDurations(results timespan.UserShiftResults) TypeDurations {
-	return TypeDurations{
-		OnCall:     results.Breakdown.TotalDur(),
-		Business:   results.Breakdown.BusinessHoursDur(),
-		AfterHours: results.Breakdown.AfterHoursDur(),
-		Weekend:    results.Breakdown.WeekendDur(),
-		Stat:       results.Breakdown.StatDur(),
-		CompanyDay: results.Breakdown.CompanyDayDur(),
+// spansToDurations returns the total duration of spans and their duration for each of attrs
+func spansToDurations(spans timespan.AttributedSpans, attrs []timespan.OnCallAttribute) TypeDurations {
+	durations := TypeDurations{
+		OnCall:      spans.TotalDur(),
+		ByAttribute: map[timespan.OnCallAttribute]time.Duration{},
+	}
+	for _, attr := range attrs {
+		durations.ByAttribute[attr] = spans.Dur(attr)
+	}
+	return durations
+}
+
+func buildIncidentSummary(results timespan.UserShiftResults, attrs []timespan.OnCallAttribute) IncidentSummary {
+	summary := IncidentSummary{
+		Count:       len(results.Incidents),
+		ByAttribute: results.IncidentCount(),
+	}
+	for _, incident := range results.Incidents {
+		summary.Engaged = summary.Engaged.Add(spansToDurations(incident.Engaged, attrs))
+	}
+	return summary
+}
+
+// Add returns the sum of i and o
+func (i IncidentSummary) Add(o IncidentSummary) IncidentSummary {
+	sum := IncidentSummary{
+		Count:       i.Count + o.Count,
+		ByAttribute: map[timespan.OnCallAttribute]int{},
+		Engaged:     i.Engaged.Add(o.Engaged),
+	}
+	for attr, count := range i.ByAttribute {
+		sum.ByAttribute[attr] += count
+	}
+	for attr, count := range o.ByAttribute {
+		sum.ByAttribute[attr] += count
+	}
+	return sum
+}
+
+// Add returns the sum of d and o
+func (d TypeDurations) Add(o TypeDurations) TypeDurations {
+	sum := TypeDurations{
+		OnCall:      d.OnCall + o.OnCall,
+		ByAttribute: map[timespan.OnCallAttribute]time.Duration{},
+	}
+	for attr, dur := range d.ByAttribute {
+		sum.ByAttribute[attr] += dur
+	}
+	for attr, dur := range o.ByAttribute {
+		sum.ByAttribute[attr] += dur
+	}
+	return sum
+}
+
+// spanLabel returns the header of the span's attribute, with what it was attributed to
+// if it's named and the attributes stacked on it, see header for names
+func (h attributeHeaders) spanLabel(span timespan.AttributedSpan, names *timespan.Attributes) string {
+	label := h.header(span.SpanType, names)
+	if span.Name != "" {
+		label += " (" + span.Name + ")"
+	}
+	for _, attr := range span.Stacked {
+		label += " + " + h.header(attr, names)
+	}
+	return label
+}
+
+// header returns the header of the attribute attr, or its name in names if the outputter has no header for it
+func (h attributeHeaders) header(attr timespan.OnCallAttribute, names *timespan.Attributes) string {
+	if header, exists := h[attr]; exists {
+		return header
+	}
+	return names.Name(attr)
+}
+
+// durationHeaders returns the headers of durationColumns
+func (data OutputData) durationHeaders(attrHeaders attributeHeaders) []interface{} {
+	headers := []interface{}{}
+	for _, attr := range data.Attributes {
+		headers = append(headers, attrHeaders.header(attr, data.Registry))
+	}
+	return headers
+}
+
+// durationColumns returns the time on call per on-call attribute, in the order of data.Attributes
+func (data OutputData) durationColumns(durations TypeDurations) []interface{} {
+	columns := []interface{}{}
+	for _, attr := range data.Attributes {
+		columns = append(columns, durations.ByAttribute[attr])
+	}
+	return columns
+}
+
+// Add returns the sum of s and o, keeping the user details of s unless it has none
+func (s ShiftsSummary) Add(o ShiftsSummary) ShiftsSummary {
+	if s.User.ID == "" && s.User.Name == "" {
+		s.User = o.User
+	}
+	sum := ShiftsSummary{
+		User:             s.User,
+		AttributedShifts: append(append([]AttributedShiftSpans{}, s.AttributedShifts...), o.AttributedShifts...),
+		Durations:        s.Durations.Add(o.Durations),
+		Amounts:          s.Amounts.Add(o.Amounts),
+		AllowanceDays:    map[timespan.OnCallAttribute]int{},
+		Allowances:       s.Allowances.Add(o.Allowances),
+		Incidents:        s.Incidents.Add(o.Incidents),
+		Callouts:         s.Callouts.Add(o.Callouts),
+		Overrides:        append(append([]timespan.Override{}, s.Overrides...), o.Overrides...),
+		Concurrent:       s.Concurrent + o.Concurrent,
+		CompanyDays:      s.CompanyDays + o.CompanyDays,
+		CompanyDayNames:  mergeNames(s.CompanyDayNames, o.CompanyDayNames),
+		LineItems:        append(append([]LineItem{}, s.LineItems...), o.LineItems...),
+	}
+	for attr, days := range s.AllowanceDays {
+		sum.AllowanceDays[attr] += days
+	}
+	for attr, days := range o.AllowanceDays {
+		sum.AllowanceDays[attr] += days
+	}
+	return sum
+}
+
+// mergeNames returns the distinct names in a and b, sorted
+func mergeNames(a, b []string) []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, name := range append(append([]string{}, a...), b...) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TotalAmount returns the hourly amounts plus allowances and callout fees owed
+func (s ShiftsSummary) TotalAmount() float64 {
+	return s.Amounts.Total() + s.Allowances.Total() + s.Callouts.Total()
+}
+
+// OverrideDur returns the total time the user was on call because of overrides
+func (s ShiftsSummary) OverrideDur() time.Duration {
+	var durs time.Duration
+	for _, override := range s.Overrides {
+		durs += override.Duration()
+	}
+	return durs
+}
+
+// Overrides returns all overrides on the schedule sorted by start time
+func (s Schedule) Overrides() []timespan.Override {
+	overrides := []timespan.Override{}
+	for _, summary := range s.UserShifts {
+		overrides = append(overrides, summary.Overrides...)
+	}
+	sort.SliceStable(overrides, func(i, j int) bool {
+		return overrides[i].Start().Before(overrides[j].Start())
+	})
+	return overrides
+}
+
+// HasOverrides returns true if any user on any schedule was on call because of an override
+func (data OutputData) HasOverrides() bool {
+	for _, sched := range data.Schedules {
+		if len(sched.Overrides()) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// HasConcurrentLevels returns true if any user was on call for more than one escalation level at the same time
+func (data OutputData) HasConcurrentLevels() bool {
+	for _, sched := range data.Schedules {
+		for _, summary := range sched.UserShifts {
+			if summary.Concurrent != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasCompanyDayNames returns true if any user was on call for a named company day
+func (data OutputData) HasCompanyDayNames() bool {
+	for _, sched := range data.Schedules {
+		for _, summary := range sched.UserShifts {
+			if len(summary.CompanyDayNames) != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasEscalationLevels returns true if any schedule is on call for an escalation level above the first
+func (data OutputData) HasEscalationLevels() bool {
+	for _, sched := range data.Schedules {
+		if sched.EscalationLevel > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// levelLabel returns the escalation level in the way it's shown in outputs
+func levelLabel(level int) string {
+	if level < 1 {
+		return "-"
+	}
+	return strconv.Itoa(level)
+}
+
+// extraHeaders returns the headers of extraColumns, see compensationHeaders for attrHeaders
+func (data OutputData) extraHeaders(attrHeaders attributeHeaders) []interface{} {
+	headers := []interface{}{}
+	if data.HasOverrides() {
+		headers = append(headers, "Overrides")
+	}
+	if data.HasConcurrentLevels() {
+		headers = append(headers, "Concurrent")
+	}
+	if data.HasCompanyDayNames() {
+		headers = append(headers, "Company day events")
+	}
+	if data.Incidents {
+		headers = append(headers, data.incidentHeaders()...)
+	}
+	if data.Compensation.Enabled() {
+		headers = append(headers, data.compensationHeaders(attrHeaders)...)
+	}
+	return headers
+}
+
+// extraColumns returns the columns that follow the duration columns, depending on
+// whether there are overrides, concurrent escalation levels or named company days and whether incidents and compensation are enabled
+func (data OutputData) extraColumns(summary ShiftsSummary) []interface{} {
+	columns := []interface{}{}
+	if data.HasOverrides() {
+		columns = append(columns, summary.OverrideDur())
+	}
+	if data.HasConcurrentLevels() {
+		columns = append(columns, summary.Concurrent)
+	}
+	if data.HasCompanyDayNames() {
+		columns = append(columns, strings.Join(summary.CompanyDayNames, ", "))
+	}
+	if data.Incidents {
+		columns = append(columns, data.incidentColumns(summary)...)
+	}
+	if data.Compensation.Enabled() {
+		columns = append(columns, data.compensationColumns(summary)...)
+	}
+	return columns
+}
+
+// overrideHeaders returns the headers of overrideRow
+func overrideHeaders() []interface{} {
+	return []interface{}{"User", "Covering for", "Start", "End", "Duration"}
+}
+
+// overrideRow returns a row describing who covered for whom and when
+func (data OutputData) overrideRow(override timespan.Override) []interface{} {
+	coveredFor := "-"
+	if override.CoveredFor != (timespan.User{}) {
+		coveredFor = data.userLabel(data.UserLabels.userDetails(override.CoveredFor))
+	}
+	return []interface{}{
+		data.userLabel(data.UserLabels.userDetails(override.User)),
+		coveredFor,
+		override.Start().Format(overrideTimeFormat),
+		override.End().Format(overrideTimeFormat),
+		override.Duration(),
+	}
+}
+
+// lineItemHeaders returns the headers of lineItemRow
+func (data OutputData) lineItemHeaders() []interface{} {
+	headers := []interface{}{"User", "Schedule", "Shift ID", "Start", "End", "Attribute", "Duration"}
+	if data.Compensation.Enabled() {
+		headers = append(headers, "Amount")
+	}
+	return headers
+}
+
+// lineItemRow returns a row describing a single attributed span of a user's shift on the schedule,
+// in the user's timezone
+func (data OutputData) lineItemRow(schedule string, user UserDetails, item LineItem) []interface{} {
+	start, end := item.Span.Start(), item.Span.End()
+	if user.Timezone != nil {
+		start, end = start.In(user.Timezone), end.In(user.Timezone)
+	}
+	row := []interface{}{
+		data.userLabel(user),
+		schedule,
+		item.ShiftID,
+		start.Format(overrideTimeFormat),
+		end.Format(overrideTimeFormat),
+		csvAttributeHeaders.spanLabel(item.Span, data.Registry),
+		item.Span.Duration(),
+	}
+	if data.Compensation.Enabled() {
+		row = append(row, item.Amount)
+	}
+	return row
+}
+
+// lineItemRows returns the rows of every line item on the schedule, sorted by user and start
+func (data OutputData) lineItemRows(sched Schedule) [][]interface{} {
+	summaries := append([]ShiftsSummary{}, sched.UserShifts...)
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return data.userLabel(summaries[i].User) < data.userLabel(summaries[j].User)
+	})
+	rows := [][]interface{}{}
+	for _, summary := range summaries {
+		for _, item := range summary.LineItems {
+			rows = append(rows, data.lineItemRow(sched.Name, summary.User, item))
+		}
+	}
+	return rows
+}
+
+// overlapHeaders returns the headers of overlapRow
+func overlapHeaders() []interface{} {
+	return []interface{}{"User", "Schedules", "Start", "End", "Duration", "Counted on"}
+}
+
+// overlapRow returns a row describing when a user was on call for more than one schedule at once and where it was counted
+func (data OutputData) overlapRow(overlap timespan.Overlap) []interface{} {
+	countedOn := "all"
+	if len(overlap.Schedules) == 2 {
+		countedOn = "both"
+	}
+	if overlap.CountedOn != "" {
+		countedOn = string(overlap.CountedOn)
+	}
+	schedules := []string{}
+	for _, schedule := range overlap.Schedules {
+		schedules = append(schedules, string(schedule))
+	}
+	return []interface{}{
+		data.userLabel(data.UserLabels.userDetails(overlap.User)),
+		strings.Join(schedules, " & "),
+		overlap.Start().Format(overrideTimeFormat),
+		overlap.End().Format(overrideTimeFormat),
+		overlap.Duration(),
+		countedOn,
+	}
+}
+
+// incidentHeaders returns the headers of incidentColumns
+func (data OutputData) incidentHeaders() []interface{} {
+	return []interface{}{"Incidents", "Engaged"}
+}
+
+// incidentColumns returns the number of incidents and the time spent engaged in them
+func (data OutputData) incidentColumns(summary ShiftsSummary) []interface{} {
+	return []interface{}{summary.Incidents.Count, summary.Incidents.Engaged.OnCall}
+}
+
+// compensationHeaders returns the headers of compensationColumns. The hourly amount and
+// allowance day columns are named after attrHeaders, the outputter's own headers of the on-call attributes
+func (data OutputData) compensationHeaders(attrHeaders attributeHeaders) []interface{} {
+	cur := data.Compensation.Currency
+	headers := []interface{}{}
+	for _, attr := range data.Attributes {
+		headers = append(headers, strings.TrimSpace(attrHeaders.header(attr, data.Registry)+" "+cur))
 	}
+	if data.Compensation.AllowancesEnabled() {
+		for _, attr := range data.Compensation.AllowanceAttributes() {
+			headers = append(headers, attrHeaders.header(attr, data.Registry)+" days")
+		}
+		headers = append(headers, strings.TrimSpace("Allowances "+cur))
+	}
+	if data.Compensation.CalloutsEnabled() {
+		headers = append(headers, strings.TrimSpace("Callouts "+cur))
+	}
+	return append(headers, strings.TrimSpace("Total "+cur))
+}
+
+// compensationColumns returns the hourly amounts in the same order as the duration columns,
+// the allowance days, allowances and callout fees if configured, followed by the total amount
+func (data OutputData) compensationColumns(summary ShiftsSummary) []interface{} {
+	columns := []interface{}{}
+	for _, attr := range data.Attributes {
+		columns = append(columns, summary.Amounts[attr])
+	}
+	if data.Compensation.AllowancesEnabled() {
+		for _, attr := range data.Compensation.AllowanceAttributes() {
+			columns = append(columns, summary.AllowanceDays[attr])
+		}
+		columns = append(columns, summary.Allowances.Total())
+	}
+	if data.Compensation.CalloutsEnabled() {
+		columns = append(columns, summary.Callouts.Total())
+	}
+	return append(columns, summary.TotalAmount())
+}
+
+const overrideTimeFormat = "2006-01-02 15:04 MST"
 
+func amountFormat(a float64) string {
+	return fmt.Sprintf("%.2f", a)
 }
 
 func durationFormat(d time.Duration) string {
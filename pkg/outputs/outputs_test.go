@@ -0,0 +1,275 @@
+package outputs
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/compensation"
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+func TestUserLabel(t *testing.T) {
+	labels := UserLabels{
+		EmployeeNumbers: map[string]string{
+			"pabc123":          "E001",
+			"jane@example.com": "E002",
+		},
+	}
+	tests := []struct {
+		column string
+		user   timespan.User
+		label  string
+	}{
+		{UserColumnName, timespan.User{ID: "PABC123", Name: "John Smith", Email: "john@example.com"}, "John Smith"},
+		{UserColumnEmail, timespan.User{ID: "PABC123", Name: "John Smith", Email: "john@example.com"}, "john@example.com"},
+		{UserColumnEmployeeNumber, timespan.User{ID: "PABC123", Name: "John Smith", Email: "john@example.com"}, "E001"},
+		{UserColumnEmployeeNumber, timespan.User{ID: "PDEF456", Name: "Jane Doe", Email: "Jane@example.com"}, "E002"},
+		{UserColumnEmployeeNumber, timespan.User{ID: "PGHI789", Name: "Joe Bloggs"}, "Joe Bloggs"},
+	}
+	for _, test := range tests {
+		labels.Column = test.column
+		data := OutputData{UserLabels: labels}
+		label := data.userLabel(labels.userDetails(test.user))
+		if label != test.label {
+			t.Errorf("%s: expected label %q, got %q", test.column, test.label, label)
+		}
+	}
+}
+
+func TestConcurrentLevels(t *testing.T) {
+	user := timespan.User{ID: "PABC123", Name: "John Smith"}
+	start := time.Date(2019, time.January, 7, 0, 0, 0, 0, time.UTC)
+	primary := timespan.New(start, start.Add(24*time.Hour))
+	secondary := timespan.New(start.Add(12*time.Hour), start.Add(48*time.Hour))
+	results := map[string][]timespan.UserShiftResults{
+		"Primary": {{
+			User:            user,
+			EscalationLevel: 1,
+			Shifts:          []timespan.Span{primary},
+			Breakdown:       timespan.AttributedSpans{{Span: primary, SpanType: timespan.Business}},
+		}},
+		"Secondary": {{
+			User:            user,
+			EscalationLevel: 2,
+			Shifts:          []timespan.Span{secondary},
+			Breakdown:       timespan.AttributedSpans{{Span: secondary, SpanType: timespan.Business}},
+		}},
+	}
+	comp := compensation.Config{
+		Rates:            compensation.Rates{timespan.Business: 10},
+		Levels:           map[int]compensation.ScheduleRates{2: {Rates: compensation.Rates{timespan.Business: 5}}},
+		ConcurrentLevels: compensation.ConcurrentPayLowest,
+	}
+	data := NewOutputData(results, start, start.Add(48*time.Hour), "", comp, nil, false, UserLabels{})
+	if !data.HasEscalationLevels() || !data.HasConcurrentLevels() {
+		t.Fatalf("Expected escalation levels with concurrent time")
+	}
+	for _, sched := range data.Schedules {
+		summary := sched.UserShifts[0]
+		switch sched.Name {
+		case "Primary":
+			if summary.Concurrent != 0 || summary.Amounts.Total() != 240 {
+				t.Errorf("Primary: expected no concurrent time and 240 owed, got %s and %.2f", summary.Concurrent, summary.Amounts.Total())
+			}
+		case "Secondary":
+			// Only the 24 hours not also on call as primary are paid, at the secondary rate
+			if summary.Concurrent != 12*time.Hour || summary.Amounts.Total() != 120 {
+				t.Errorf("Secondary: expected 12h concurrent time and 120 owed, got %s and %.2f", summary.Concurrent, summary.Amounts.Total())
+			}
+			// The line items add up to the amount owed
+			var owed float64
+			for _, item := range summary.LineItems {
+				owed += item.Amount
+			}
+			if len(summary.LineItems) != 1 || owed != 120 || summary.LineItems[0].ShiftID != "PABC123-20190107T1200Z" {
+				t.Errorf("Secondary: expected a single line item of shift PABC123-20190107T1200Z owing 120, got %+v", summary.LineItems)
+			}
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	user := timespan.User{ID: "PABC123", Name: "John Smith", Email: "john@example.com"}
+	start := time.Date(2019, time.January, 4, 9, 0, 0, 0, time.UTC)
+	shift := timespan.New(start, start.Add(24*time.Hour))
+	results := map[string][]timespan.UserShiftResults{
+		"Primary": {{
+			User:   user,
+			Shifts: []timespan.Span{shift},
+			Breakdown: timespan.AttributedSpans{
+				{Span: timespan.New(start, start.Add(8*time.Hour)), SpanType: timespan.Business},
+				{Span: timespan.New(start.Add(8*time.Hour), start.Add(24*time.Hour)), SpanType: timespan.Weekend},
+			},
+		}},
+	}
+	data := NewOutputData(results, start, start.Add(48*time.Hour), "January 2019", compensation.Config{}, nil, false, UserLabels{})
+
+	var out bytes.Buffer
+	if err := NewJSONOutputter("-").write(&out, data); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	var export Export
+	if err := json.Unmarshal(out.Bytes(), &export); err != nil {
+		t.Fatalf("Failed to decode JSON export: %s", err.Error())
+	}
+	if export.SchemaVersion != ExportSchemaVersion {
+		t.Errorf("Expected schema version %d, got %d", ExportSchemaVersion, export.SchemaVersion)
+	}
+	if len(export.Schedules) != 1 || len(export.Schedules[0].Users) != 1 {
+		t.Fatalf("Expected a single schedule with a single user, got %+v", export.Schedules)
+	}
+	exportUser := export.Schedules[0].Users[0]
+	if exportUser.Durations.OnCallSeconds != 86400 || exportUser.Durations.ByAttribute["weekend"] != 57600 {
+		t.Errorf("Expected 86400 seconds on call with 57600 at the weekend, got %+v", exportUser.Durations)
+	}
+	if len(exportUser.Shifts) != 1 || len(exportUser.Shifts[0].Spans) != 2 {
+		t.Fatalf("Expected a single shift of 2 spans, got %+v", exportUser.Shifts)
+	}
+	if span := exportUser.Shifts[0].Spans[0]; span.Attribute != "business" || span.Start != "2019-01-04T09:00:00Z" || span.DurationSeconds != 28800 {
+		t.Errorf("Expected the shift to start with 8 business hours, got %+v", span)
+	}
+}
+
+func TestShiftDetailRows(t *testing.T) {
+	// Friday 09:00 to Monday 17:30 in UTC
+	start := time.Date(2019, time.January, 4, 9, 0, 0, 0, time.UTC)
+	shift := timespan.New(start, start.Add(80*time.Hour+30*time.Minute))
+	friday := timespan.New(start, start.Add(8*time.Hour+30*time.Minute))
+	weekend := timespan.New(friday.End(), friday.End().Add(62*time.Hour+30*time.Minute))
+	monday := timespan.New(weekend.End(), shift.End())
+	summary := ShiftsSummary{
+		User: UserDetails{Name: "John Smith", Timezone: time.UTC},
+		AttributedShifts: []AttributedShiftSpans{{shift: timespan.AttributedSpans{
+			{Span: friday, SpanType: timespan.Business},
+			{Span: weekend, SpanType: timespan.Weekend},
+			{Span: monday, SpanType: timespan.Business},
+		}}},
+	}
+	expected := [][]string{
+		{"Fri 4 Jan 09:00–Mon 7 Jan 17:30", "Fri 09:00–Fri 17:30", "Business Hours", "8h 30m"},
+		{"", "Fri 17:30–Mon 08:00", "Weekend", "62h 30m"},
+		{"", "Mon 08:00–Mon 17:30", "Business Hours", "9h 30m"},
+		{"", "", "Subtotal", "80h 30m"},
+	}
+	rows := shiftDetailRows(summary, nil)
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d rows, got %d: %v", len(expected), len(rows), rows)
+	}
+	for i := range expected {
+		for j := range expected[i] {
+			if rows[i][j] != expected[i][j] {
+				t.Errorf("Row %d column %d: expected %q, got %q", i, j, expected[i][j], rows[i][j])
+			}
+		}
+	}
+}
+
+func TestHTMLReport(t *testing.T) {
+	user := timespan.User{ID: "PABC123", Name: "John <Smith>"}
+	start := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	shift := timespan.New(start.Add(24*time.Hour), start.Add(48*time.Hour))
+	results := map[string][]timespan.UserShiftResults{
+		"Primary": {{
+			User:   user,
+			Shifts: []timespan.Span{shift},
+			Breakdown: timespan.AttributedSpans{
+				{Span: shift, SpanType: timespan.StatHoliday, Name: "New Year"},
+			},
+		}},
+	}
+	data := NewOutputData(results, start, start.AddDate(0, 0, 4), "January 2019", compensation.Config{}, nil, false, UserLabels{})
+
+	timeline := data.htmlTimeline(data.Schedules[0].UserShifts[0])
+	if len(timeline.Bars) != 1 {
+		t.Fatalf("Expected a single timeline bar, got %+v", timeline.Bars)
+	}
+	if expected := "left:25.0000%;width:25.0000%;background:#76b7b2"; string(timeline.Bars[0].Style) != expected {
+		t.Errorf("Expected the bar to be the second day of four, got %q", timeline.Bars[0].Style)
+	}
+	markers := data.htmlMarkers(data.Schedules[0])
+	if len(markers) != 1 || markers[0].Title != "Stat Wed 2 Jan: New Year" {
+		t.Errorf("Expected a single New Year marker, got %+v", markers)
+	}
+	if ticks := data.htmlTicks(); len(ticks) != 4 || ticks[0].Label != "1" {
+		t.Errorf("Expected a tick for each of the 4 days, got %+v", ticks)
+	}
+
+	var out bytes.Buffer
+	if err := NewHTMLOutputter("").write(&out, data); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	page := out.String()
+	if !strings.Contains(page, "John &lt;Smith&gt;") {
+		t.Errorf("Expected the user name to be escaped")
+	}
+	for _, external := range []string{"<link", "<script", "src="} {
+		if strings.Contains(page, external) {
+			t.Errorf("Expected no external assets, found %q", external)
+		}
+	}
+}
+
+func TestXLSXWorkbook(t *testing.T) {
+	start := time.Date(2019, time.January, 4, 9, 0, 0, 0, time.UTC)
+	results := map[string][]timespan.UserShiftResults{}
+	for i, name := range []string{"John Smith", "Jane Doe"} {
+		shift := timespan.New(start, start.Add(time.Duration(i+1)*12*time.Hour+30*time.Minute))
+		results["Primary"] = append(results["Primary"], timespan.UserShiftResults{
+			User:      timespan.User{ID: fmt.Sprintf("P%d", i), Name: name},
+			Shifts:    []timespan.Span{shift},
+			Breakdown: timespan.AttributedSpans{{Span: shift, SpanType: timespan.AfterHours}},
+		})
+	}
+	data := NewOutputData(results, start, start.AddDate(0, 0, 2), "January 2019", compensation.Config{}, nil, false, UserLabels{})
+
+	var out bytes.Buffer
+	if err := NewXLSXOutputter("").write(&out, data); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	zr, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatalf("Failed to open workbook: %s", err.Error())
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %s", f.Name, err.Error())
+		}
+		content, _ := ioutil.ReadAll(r)
+		r.Close()
+		parts[f.Name] = string(content)
+	}
+	for _, name := range []string{"[Content_Types].xml", "xl/workbook.xml", "xl/styles.xml", "xl/worksheets/sheet1.xml", "xl/worksheets/sheet2.xml", "xl/worksheets/sheet3.xml"} {
+		if _, exists := parts[name]; !exists {
+			t.Errorf("Expected %s in the workbook", name)
+		}
+	}
+	if !strings.Contains(parts["xl/workbook.xml"], `name="Summary"`) || !strings.Contains(parts["xl/workbook.xml"], `name="Primary"`) {
+		t.Errorf("Expected a summary and a Primary sheet, got %s", parts["xl/workbook.xml"])
+	}
+	var ws xlsxWorksheet
+	if err := xml.Unmarshal([]byte(parts["xl/worksheets/sheet2.xml"]), &ws); err != nil {
+		t.Fatalf("Failed to decode the Primary sheet: %s", err.Error())
+	}
+	// Title, headers, Jane, John and the total
+	if len(ws.SheetData.Rows) != 5 {
+		t.Fatalf("Expected 5 rows, got %d", len(ws.SheetData.Rows))
+	}
+	// Afterhours is the second column after User and Business Hours
+	jane := ws.SheetData.Rows[2].Cells[2]
+	if jane.V != "1.0208333333333333" || jane.S != xlsxStyle(xlsxFirstBand, xlsxDuration) {
+		t.Errorf("Expected 24h 30m as a duration cell, got %+v", jane)
+	}
+	total := ws.SheetData.Rows[4].Cells[2]
+	if total.F != "SUM(C3:C4)" || total.V != "1.5416666666666667" {
+		t.Errorf("Expected a SUM of 37h total, got %+v", total)
+	}
+}
@@ -4,16 +4,37 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"time"
 
+	"github.com/leosunmo/pagertally/pkg/timespan"
 	"github.com/olekukonko/tablewriter"
 )
 
+// stdoutAttributeHeaders are the stdout headers of the built in on-call attributes' durations
+var stdoutAttributeHeaders = attributeHeaders{
+	timespan.Business:    "Business Hours",
+	timespan.AfterHours:  "Afterhours",
+	timespan.Weekend:     "Weekend",
+	timespan.StatHoliday: "Stat",
+	timespan.CompanyDay:  "Company days",
+}
+
+// stdoutAmountHeaders are the stdout headers of the built in on-call attributes' amounts
+var stdoutAmountHeaders = attributeHeaders{
+	timespan.Business:    "Business",
+	timespan.AfterHours:  "Afterhours",
+	timespan.Weekend:     "Weekend",
+	timespan.StatHoliday: "Stat",
+	timespan.CompanyDay:  "Company days",
+}
+
 // StdoutOutputter will print tables to stdout
 type StdoutOutputter struct {
 	ShiftDetails bool
 }
 
-// NewStdoutOutput returns a StdoutOutput with shiftdetails enabled or disabled
+// NewStdoutOutputter returns a StdoutOutputter with shift details enabled or disabled.
+// With shift details every user's shifts are printed with their attributed spans after each schedule's table
 func NewStdoutOutputter(shiftDetails bool) StdoutOutputter {
 	return StdoutOutputter{
 		ShiftDetails: shiftDetails,
@@ -23,48 +44,186 @@ func NewStdoutOutputter(shiftDetails bool) StdoutOutputter {
 // Print prints one table per Schedule to Stdout
 func (std StdoutOutputter) Print(data OutputData) error {
 	writer := tablewriter.NewWriter(os.Stdout)
-	// Schedules to Users to Durations
-	tableData := map[string]map[string]TypeDurations{}
+	// Schedules to Users to shift summaries
+	tableData := map[string]map[string]ShiftsSummary{}
+	levels := map[string]int{}
 	sortedSchedules := []string{}
 	// gather some useful stuff to sort on
 	for _, schedule := range data.Schedules {
 		sortedSchedules = append(sortedSchedules, schedule.Name)
-		userDurs := map[string]TypeDurations{}
+		userSummaries := map[string]ShiftsSummary{}
 		for _, shiftSummary := range schedule.UserShifts {
-			userDurs[shiftSummary.User.Name] = shiftSummary.Durations
+			userSummaries[shiftSummary.User.ID] = shiftSummary
 		}
-		tableData[schedule.Name] = userDurs
+		tableData[schedule.Name] = userSummaries
+		levels[schedule.Name] = schedule.EscalationLevel
 	}
 	sort.Strings(sortedSchedules)
+	headers := []string{"User"}
+	headers = append(headers, stringRow(data.durationHeaders(stdoutAttributeHeaders))...)
+	headers = append(headers, "Total time")
+	headers = append(headers, stringRow(data.extraHeaders(stdoutAmountHeaders))...)
+	overrides := map[string][]timespan.Override{}
+	for _, schedule := range data.Schedules {
+		overrides[schedule.Name] = schedule.Overrides()
+	}
+	var grandTotal float64
 	for _, s := range sortedSchedules {
-		fmt.Printf("Schedule: %s\n", s)
-		writer.SetHeader([]string{"User", "Business Hours", "Afterhours", "Weekend", "Stat", "Company days", "Total time"})
-		writer.AppendBulk(buildUsersDurationTable(tableData[s]))
+		if data.HasEscalationLevels() {
+			fmt.Printf("Schedule: %s (escalation level %s)\n", s, levelLabel(levels[s]))
+		} else {
+			fmt.Printf("Schedule: %s\n", s)
+		}
+		writer.SetHeader(headers)
+		writer.AppendBulk(data.buildUsersDurationTable(tableData[s]))
+		if data.Compensation.Enabled() {
+			var total ShiftsSummary
+			for _, summary := range tableData[s] {
+				total = total.Add(summary)
+			}
+			total.User = UserDetails{Name: "Total"}
+			writer.SetFooter(data.buildUserRow(total))
+			grandTotal += total.TotalAmount()
+		}
 		writer.Render()
 		writer.ClearRows()
 		fmt.Println()
+		if len(overrides[s]) != 0 {
+			fmt.Printf("Overrides: %s\n", s)
+			overrideWriter := tablewriter.NewWriter(os.Stdout)
+			overrideWriter.SetHeader(stringRow(overrideHeaders()))
+			for _, override := range overrides[s] {
+				overrideWriter.Append(stringRow(data.overrideRow(override)))
+			}
+			overrideWriter.Render()
+			fmt.Println()
+		}
+		if std.ShiftDetails {
+			data.printShiftDetails(s, tableData[s])
+		}
+	}
+	if len(data.Overlaps) != 0 {
+		fmt.Println("Overlapping shifts")
+		overlapWriter := tablewriter.NewWriter(os.Stdout)
+		overlapWriter.SetHeader(stringRow(overlapHeaders()))
+		for _, overlap := range data.Overlaps {
+			overlapWriter.Append(stringRow(data.overlapRow(overlap)))
+		}
+		overlapWriter.Render()
+		fmt.Println()
+	}
+	if data.Compensation.Enabled() {
+		fmt.Printf("Grand total: %s %s\n", amountFormat(grandTotal), data.Compensation.Currency)
 	}
 	return nil
 }
 
-func buildUsersDurationTable(data map[string]TypeDurations) [][]string {
-	users := []string{}
-	userTable := [][]string{}
-	for user := range data {
-		users = append(users, user)
+// shiftDetailFormat and spanDetailFormat are the time formats of shifts and their attributed spans in the shift details
+const (
+	shiftDetailFormat = "Mon 2 Jan 15:04"
+	spanDetailFormat  = "Mon 15:04"
+)
+
+// printShiftDetails prints one table per user on the schedule with every shift and its attributed spans
+func (data OutputData) printShiftDetails(schedule string, summaries map[string]ShiftsSummary) {
+	users := []ShiftsSummary{}
+	for _, summary := range summaries {
+		users = append(users, summary)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return data.userLabel(users[i].User) < data.userLabel(users[j].User)
+	})
+	for _, summary := range users {
+		if summary.User.Timezone != nil {
+			fmt.Printf("Shifts: %s, %s (%s)\n", schedule, data.userLabel(summary.User), summary.User.Timezone)
+		} else {
+			fmt.Printf("Shifts: %s, %s\n", schedule, data.userLabel(summary.User))
+		}
+		writer := tablewriter.NewWriter(os.Stdout)
+		writer.SetHeader([]string{"Shift", "Span", "Attribute", "Duration"})
+		writer.AppendBulk(shiftDetailRows(summary, data.Registry))
+		writer.Render()
+		fmt.Println()
+	}
+}
+
+// shiftDetailRows returns a row per attributed span of every shift of the summary, in the user's timezone,
+// followed by a subtotal row per shift. Attributes are named by names
+func shiftDetailRows(summary ShiftsSummary, names *timespan.Attributes) [][]string {
+	shifts := []timespan.Span{}
+	attributed := map[timespan.Span]timespan.AttributedSpans{}
+	for _, attributedShift := range summary.AttributedShifts {
+		for shift, attrSpans := range attributedShift {
+			shifts = append(shifts, shift)
+			attributed[shift] = attrSpans
+		}
 	}
-	sort.Strings(users)
-	// loop over the sorted users to build the table in alphabetical order
-	for _, u := range users {
-		durations := data[u]
-		durs := []string{u,
-			durationFormat(durations.Business),
-			durationFormat(durations.AfterHours),
-			durationFormat(durations.Weekend),
-			durationFormat(durations.Stat),
-			durationFormat(durations.CompanyDay),
-			durationFormat(durations.OnCall)}
-		userTable = append(userTable, durs)
+	sort.Sort(timespan.Spans(shifts))
+
+	rows := [][]string{}
+	for _, shift := range shifts {
+		loc := summary.User.Timezone
+		if loc == nil {
+			loc = shift.Start().Location()
+		}
+		shiftLabel := detailSpanLabel(shift, shiftDetailFormat, loc)
+		var subtotal time.Duration
+		for _, attrSpan := range attributed[shift] {
+			// Attributed spans can continue into the next shift if the shifts are back to back
+			span, overlaps := attrSpan.Span.Intersection(shift)
+			if !overlaps {
+				continue
+			}
+			format := spanDetailFormat
+			if span.Duration() >= 6*24*time.Hour {
+				format = shiftDetailFormat
+			}
+			rows = append(rows, []string{shiftLabel, detailSpanLabel(span, format, loc), stdoutAttributeHeaders.spanLabel(attrSpan, names), durationFormat(span.Duration())})
+			shiftLabel = ""
+			subtotal += span.Duration()
+		}
+		rows = append(rows, []string{shiftLabel, "", "Subtotal", durationFormat(subtotal)})
+	}
+	return rows
+}
+
+// detailSpanLabel returns the start and end of span in loc, e.g. "Fri 17:30–Mon 08:00"
+func detailSpanLabel(span timespan.Span, format string, loc *time.Location) string {
+	return span.Start().In(loc).Format(format) + "–" + span.End().In(loc).Format(format)
+}
+
+func (data OutputData) buildUsersDurationTable(summaries map[string]ShiftsSummary) [][]string {
+	userTable := [][]string{}
+	for _, summary := range summaries {
+		userTable = append(userTable, data.buildUserRow(summary))
 	}
+	// sort the rows to build the table in alphabetical order
+	sort.Slice(userTable, func(i, j int) bool {
+		return userTable[i][0] < userTable[j][0]
+	})
 	return userTable
 }
+
+// buildUserRow returns the table row of a single user's durations, and amounts if compensation is enabled
+func (data OutputData) buildUserRow(summary ShiftsSummary) []string {
+	row := []string{data.userLabel(summary.User)}
+	row = append(row, stringRow(data.durationColumns(summary.Durations))...)
+	row = append(row, durationFormat(summary.Durations.OnCall))
+	return append(row, stringRow(data.extraColumns(summary))...)
+}
+
+// stringRow formats the columns of a row for printing
+func stringRow(columns []interface{}) []string {
+	row := []string{}
+	for _, column := range columns {
+		switch c := column.(type) {
+		case float64:
+			row = append(row, amountFormat(c))
+		case time.Duration:
+			row = append(row, durationFormat(c))
+		default:
+			row = append(row, fmt.Sprint(c))
+		}
+	}
+	return row
+}
@@ -0,0 +1,528 @@
+package outputs
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// XLSXOutputter outputs an Excel workbook with a summary sheet, a sheet per schedule and a sheet of line items.
+// Durations are numeric cells formatted as hours and minutes, and totals are formulas, so they can be worked with in Excel
+type XLSXOutputter struct {
+	path string
+}
+
+// NewXLSXOutputter returns a new XLSX outputter writing the workbook to path
+func NewXLSXOutputter(path string) *XLSXOutputter {
+	return &XLSXOutputter{path: path}
+}
+
+// xlsxSheet is a single sheet of the workbook, a title row followed by a banded table with a total row
+type xlsxSheet struct {
+	name    string
+	title   string
+	headers []interface{}
+	rows    [][]interface{}
+}
+
+// Bands of the table rows, the same colours as the Google Sheet banding
+const (
+	xlsxHeaderBand = iota
+	xlsxFirstBand
+	xlsxSecondBand
+	xlsxTotalBand
+)
+
+// Kinds of cells, each with its own number format
+const (
+	xlsxText = iota
+	xlsxDuration
+	xlsxAmount
+)
+
+// xlsxPart is a file of the workbook, either a string of XML or a value to encode as XML
+type xlsxPart struct {
+	name    string
+	content interface{}
+}
+
+// xlsxTitleStyle is the style of the title row, the styles of the table cells follow it
+const xlsxTitleStyle = 1
+
+// xlsxStyle returns the index of the cell style of a cell kind in a band
+func xlsxStyle(band, kind int) int {
+	return xlsxTitleStyle + 1 + band*3 + kind
+}
+
+// xlsxMaxSheetName is the longest sheet name Excel allows
+const xlsxMaxSheetName = 31
+
+// Print writes the workbook of the data to the outputter's file
+func (x *XLSXOutputter) Print(data OutputData) error {
+	oFile, err := os.Create(x.path)
+	if err != nil {
+		return fmt.Errorf("Failed to create XLSX output file on filesystem: %s", err.Error())
+	}
+	defer oFile.Close()
+	return x.write(oFile, data)
+}
+
+// write writes the workbook of the data to w
+func (x *XLSXOutputter) write(w io.Writer, data OutputData) error {
+	sheets := data.xlsxSheets()
+	zw := zip.NewWriter(w)
+	parts := []xlsxPart{
+		{"[Content_Types].xml", xlsxContentTypes(len(sheets))},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", newXLSXWorkbook(sheets)},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels(len(sheets))},
+		{"xl/styles.xml", xlsxStyles()},
+	}
+	for i, sheet := range sheets {
+		parts = append(parts, xlsxPart{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), sheet.worksheet()})
+	}
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return fmt.Errorf("Failed to write %s to XLSX file: %s", part.name, err.Error())
+		}
+		if _, err = io.WriteString(f, xml.Header); err != nil {
+			return fmt.Errorf("Failed to write %s to XLSX file: %s", part.name, err.Error())
+		}
+		switch content := part.content.(type) {
+		case string:
+			_, err = io.WriteString(f, content)
+		default:
+			err = xml.NewEncoder(f).Encode(content)
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to write %s to XLSX file: %s", part.name, err.Error())
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("Failed to write XLSX file: %s", err.Error())
+	}
+	return nil
+}
+
+// xlsxSheets returns the summary sheet of all schedules combined, a sheet per schedule sorted by name and a sheet of line items
+func (data OutputData) xlsxSheets() []xlsxSheet {
+	period := data.PeriodName
+	if period == "" {
+		period = data.DateRange.Start().Month().String() + " " + strconv.Itoa(data.DateRange.Start().Year())
+	}
+	used := map[string]bool{}
+	headers, rows, _ := data.combinedSummary()
+	sheets := []xlsxSheet{{
+		name:    xlsxSheetName("Summary", used),
+		title:   period + ": " + strings.Join(data.scheduleNames(), " & "),
+		headers: headers,
+		rows:    rows,
+	}}
+
+	schedules := append([]Schedule{}, data.Schedules...)
+	sort.SliceStable(schedules, func(i, j int) bool {
+		return schedules[i].Name < schedules[j].Name
+	})
+	headers = []interface{}{"User"}
+	headers = append(headers, data.durationHeaders(csvAttributeHeaders)...)
+	headers = append(headers, "Total")
+	headers = append(headers, data.extraHeaders(csvAttributeHeaders)...)
+	details := xlsxSheet{
+		title:   period + " line items",
+		headers: data.lineItemHeaders(),
+	}
+	for _, sched := range schedules {
+		sheet := xlsxSheet{
+			name:    xlsxSheetName(sched.Name, used),
+			title:   period + ": " + sched.Name,
+			headers: headers,
+		}
+		if data.HasEscalationLevels() {
+			sheet.title += " (escalation level " + levelLabel(sched.EscalationLevel) + ")"
+		}
+		summaries := append([]ShiftsSummary{}, sched.UserShifts...)
+		sort.SliceStable(summaries, func(i, j int) bool {
+			return data.userLabel(summaries[i].User) < data.userLabel(summaries[j].User)
+		})
+		for _, summary := range summaries {
+			row := []interface{}{data.userLabel(summary.User)}
+			row = append(row, data.durationColumns(summary.Durations)...)
+			row = append(row, summary.Durations.OnCall)
+			row = append(row, data.extraColumns(summary)...)
+			sheet.rows = append(sheet.rows, row)
+		}
+		sheets = append(sheets, sheet)
+		details.rows = append(details.rows, data.lineItemRows(sched)...)
+	}
+	details.name = xlsxSheetName("Details", used)
+	return append(sheets, details)
+}
+
+// xlsxSheetName returns name as a valid sheet name that isn't used yet, replacing characters Excel doesn't allow
+// and shortening it to the longest name allowed
+func xlsxSheetName(name string, used map[string]bool) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '-'
+		}
+		return r
+	}, strings.Trim(name, "'"))
+	if name == "" {
+		name = "Sheet"
+	}
+	unique := truncateRunes(name, xlsxMaxSheetName)
+	for i := 2; used[strings.ToLower(unique)]; i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		unique = truncateRunes(name, xlsxMaxSheetName-len(suffix)) + suffix
+	}
+	used[strings.ToLower(unique)] = true
+	return unique
+}
+
+// truncateRunes returns s shortened to at most n runes
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// xlsxColumn returns the letters of the zero based column index, e.g. 0 is A and 27 is AB
+func xlsxColumn(index int) string {
+	column := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		column = string(rune('A'+(index-1)%26)) + column
+	}
+	return column
+}
+
+// xlsxCellKind returns the kind of cell a column value is printed as
+func xlsxCellKind(value interface{}) int {
+	switch value.(type) {
+	case time.Duration:
+		return xlsxDuration
+	case float64:
+		return xlsxAmount
+	}
+	return xlsxText
+}
+
+// xlsxCellValue returns a column value as a cell, durations as fractions of days like Excel's own times
+func xlsxCellValue(ref string, value interface{}, style int) xlsxC {
+	cell := xlsxC{R: ref, S: style}
+	switch v := value.(type) {
+	case nil:
+	case time.Duration:
+		cell.V = strconv.FormatFloat(v.Hours()/24, 'f', -1, 64)
+	case float64:
+		cell.V = strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		cell.V = strconv.Itoa(v)
+	case int64:
+		cell.V = strconv.FormatInt(v, 10)
+	default:
+		cell.T = "inlineStr"
+		cell.Is = &xlsxIs{T: xlsxT{Space: "preserve", Text: fmt.Sprint(v)}}
+	}
+	return cell
+}
+
+// worksheet returns the XML of the sheet. The header row has the header band, the rows alternate between
+// the first and second band and the total row sums every numeric column with a formula
+func (s xlsxSheet) worksheet() xlsxWorksheet {
+	ws := xlsxWorksheet{
+		Xmlns: xlsxNamespace,
+		SheetViews: xlsxSheetViews{SheetView: xlsxSheetView{
+			WorkbookViewID: 0,
+			// Keep the title and headers in view when scrolling
+			Pane: &xlsxPane{YSplit: 2, TopLeftCell: "A3", ActivePane: "bottomLeft", State: "frozen"},
+		}},
+	}
+	ws.SheetData.Rows = append(ws.SheetData.Rows, xlsxRow{R: 1, Cells: []xlsxC{xlsxCellValue("A1", s.title, xlsxTitleStyle)}})
+
+	width := len(s.headers)
+	for _, row := range s.rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	kinds := make([]int, width)
+	numeric := make([]bool, width)
+	widths := make([]int, width)
+	for _, row := range s.rows {
+		for i, value := range row {
+			switch value.(type) {
+			case time.Duration, float64, int, int64:
+				numeric[i] = true
+				kinds[i] = xlsxCellKind(value)
+			}
+		}
+	}
+
+	header := xlsxRow{R: 2}
+	for i := 0; i < width; i++ {
+		var value interface{}
+		if i < len(s.headers) {
+			value = s.headers[i]
+		}
+		header.Cells = append(header.Cells, xlsxCellValue(xlsxColumn(i)+"2", value, xlsxStyle(xlsxHeaderBand, xlsxText)))
+		widths[i] = len(fmt.Sprint(value))
+	}
+	ws.SheetData.Rows = append(ws.SheetData.Rows, header)
+
+	firstRow := 3
+	for r, row := range s.rows {
+		band := xlsxFirstBand
+		if r%2 == 1 {
+			band = xlsxSecondBand
+		}
+		xr := xlsxRow{R: firstRow + r}
+		for i := 0; i < width; i++ {
+			var value interface{}
+			if i < len(row) {
+				value = row[i]
+			}
+			ref := xlsxColumn(i) + strconv.Itoa(xr.R)
+			xr.Cells = append(xr.Cells, xlsxCellValue(ref, value, xlsxStyle(band, xlsxCellKind(value))))
+			if length := len(fmt.Sprint(value)); xlsxCellKind(value) == xlsxText && length > widths[i] {
+				widths[i] = length
+			}
+		}
+		ws.SheetData.Rows = append(ws.SheetData.Rows, xr)
+	}
+
+	if len(s.rows) != 0 {
+		lastRow := firstRow + len(s.rows) - 1
+		total := xlsxRow{R: lastRow + 1}
+		for i := 0; i < width; i++ {
+			ref := xlsxColumn(i) + strconv.Itoa(total.R)
+			style := xlsxStyle(xlsxTotalBand, kinds[i])
+			switch {
+			case i == 0:
+				total.Cells = append(total.Cells, xlsxCellValue(ref, "Total", style))
+			case numeric[i]:
+				cell := xlsxCellValue(ref, s.columnSum(i), style)
+				cell.F = fmt.Sprintf("SUM(%s%d:%s%d)", xlsxColumn(i), firstRow, xlsxColumn(i), lastRow)
+				total.Cells = append(total.Cells, cell)
+			default:
+				total.Cells = append(total.Cells, xlsxCellValue(ref, nil, style))
+			}
+		}
+		ws.SheetData.Rows = append(ws.SheetData.Rows, total)
+	}
+
+	for i, w := range widths {
+		// Leave room for numbers like "1234:56" or "12345.67"
+		if numeric[i] && w < 10 {
+			w = 10
+		}
+		if w > 60 {
+			w = 60
+		}
+		ws.Cols.Cols = append(ws.Cols.Cols, xlsxCol{Min: i + 1, Max: i + 1, Width: float64(w + 2), CustomWidth: true})
+	}
+	if len(ws.Cols.Cols) == 0 {
+		ws.Cols.Cols = append(ws.Cols.Cols, xlsxCol{Min: 1, Max: 1, Width: 12, CustomWidth: true})
+	}
+	return ws
+}
+
+// columnSum returns the sum of the numeric column, cached in the total row for readers that don't calculate formulas
+func (s xlsxSheet) columnSum(column int) interface{} {
+	var durations time.Duration
+	var amounts float64
+	var counts int64
+	kind := xlsxText
+	for _, row := range s.rows {
+		if column >= len(row) {
+			continue
+		}
+		switch v := row[column].(type) {
+		case time.Duration:
+			durations += v
+			kind = xlsxDuration
+		case float64:
+			amounts += v
+			kind = xlsxAmount
+		case int:
+			counts += int64(v)
+		case int64:
+			counts += v
+		}
+	}
+	switch kind {
+	case xlsxDuration:
+		return durations
+	case xlsxAmount:
+		return amounts
+	}
+	return counts
+}
+
+const xlsxNamespace = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+
+const xlsxRelationshipNamespace = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+
+type xlsxWorksheet struct {
+	XMLName    xml.Name       `xml:"worksheet"`
+	Xmlns      string         `xml:"xmlns,attr"`
+	SheetViews xlsxSheetViews `xml:"sheetViews"`
+	Cols       xlsxCols       `xml:"cols"`
+	SheetData  xlsxSheetData  `xml:"sheetData"`
+}
+
+type xlsxSheetViews struct {
+	SheetView xlsxSheetView `xml:"sheetView"`
+}
+
+type xlsxSheetView struct {
+	WorkbookViewID int       `xml:"workbookViewId,attr"`
+	Pane           *xlsxPane `xml:"pane,omitempty"`
+}
+
+type xlsxPane struct {
+	YSplit      int    `xml:"ySplit,attr"`
+	TopLeftCell string `xml:"topLeftCell,attr"`
+	ActivePane  string `xml:"activePane,attr"`
+	State       string `xml:"state,attr"`
+}
+
+type xlsxCols struct {
+	Cols []xlsxCol `xml:"col"`
+}
+
+type xlsxCol struct {
+	Min         int     `xml:"min,attr"`
+	Max         int     `xml:"max,attr"`
+	Width       float64 `xml:"width,attr"`
+	CustomWidth bool    `xml:"customWidth,attr"`
+}
+
+type xlsxSheetData struct {
+	Rows []xlsxRow `xml:"row"`
+}
+
+type xlsxRow struct {
+	R     int     `xml:"r,attr"`
+	Cells []xlsxC `xml:"c"`
+}
+
+// xlsxC is a single cell, either a number V, a formula F with its cached value V or an inline string Is
+type xlsxC struct {
+	R  string  `xml:"r,attr"`
+	S  int     `xml:"s,attr,omitempty"`
+	T  string  `xml:"t,attr,omitempty"`
+	F  string  `xml:"f,omitempty"`
+	V  string  `xml:"v,omitempty"`
+	Is *xlsxIs `xml:"is,omitempty"`
+}
+
+type xlsxIs struct {
+	T xlsxT `xml:"t"`
+}
+
+type xlsxT struct {
+	Space string `xml:"xml:space,attr,omitempty"`
+	Text  string `xml:",chardata"`
+}
+
+type xlsxWorkbook struct {
+	XMLName xml.Name         `xml:"workbook"`
+	Xmlns   string           `xml:"xmlns,attr"`
+	R       string           `xml:"xmlns:r,attr"`
+	Sheets  []xlsxSheetEntry `xml:"sheets>sheet"`
+	CalcPr  xlsxCalcPr       `xml:"calcPr"`
+}
+
+type xlsxSheetEntry struct {
+	Name    string `xml:"name,attr"`
+	SheetID int    `xml:"sheetId,attr"`
+	RID     string `xml:"r:id,attr"`
+}
+
+type xlsxCalcPr struct {
+	FullCalcOnLoad bool `xml:"fullCalcOnLoad,attr"`
+}
+
+// newXLSXWorkbook returns the workbook listing the sheets, calculating the total formulas when it's opened
+func newXLSXWorkbook(sheets []xlsxSheet) xlsxWorkbook {
+	wb := xlsxWorkbook{Xmlns: xlsxNamespace, R: xlsxRelationshipNamespace, CalcPr: xlsxCalcPr{FullCalcOnLoad: true}}
+	for i, sheet := range sheets {
+		wb.Sheets = append(wb.Sheets, xlsxSheetEntry{Name: sheet.name, SheetID: i + 1, RID: fmt.Sprintf("rId%d", i+1)})
+	}
+	return wb
+}
+
+func xlsxContentTypes(sheets int) string {
+	var b strings.Builder
+	b.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	b.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	b.WriteString(`</Types>`)
+	return b.String()
+}
+
+const xlsxRootRels = `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+// xlsxWorkbookRels returns the relationships of the workbook to its sheets, rId1 to rIdN, and its styles
+func xlsxWorkbookRels(sheets int) string {
+	var b strings.Builder
+	b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, sheets+1)
+	b.WriteString(`</Relationships>`)
+	return b.String()
+}
+
+// xlsxStyles returns the stylesheet with the title style followed by every cell kind in every band, see xlsxStyle.
+// Durations are formatted as [h]:mm so they don't wrap at 24 hours, and amounts with two decimals
+func xlsxStyles() string {
+	// Fills 0 and 1 are reserved by Excel, followed by the header, first and second band colours
+	bandFills := map[int]int{xlsxHeaderBand: 2, xlsxFirstBand: 3, xlsxSecondBand: 4, xlsxTotalBand: 2}
+	kindFormats := map[int]int{xlsxText: 0, xlsxDuration: 164, xlsxAmount: 165}
+	var b strings.Builder
+	b.WriteString(`<styleSheet xmlns="` + xlsxNamespace + `">`)
+	b.WriteString(`<numFmts count="2"><numFmt numFmtId="164" formatCode="[h]:mm"/><numFmt numFmtId="165" formatCode="0.00"/></numFmts>`)
+	b.WriteString(`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>`)
+	b.WriteString(`<fills count="5"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill>`)
+	for _, colour := range []string{"FFBDBDBD", "FFFFFFFF", "FFF3F3F3"} {
+		fmt.Fprintf(&b, `<fill><patternFill patternType="solid"><fgColor rgb="%s"/><bgColor indexed="64"/></patternFill></fill>`, colour)
+	}
+	b.WriteString(`</fills>`)
+	b.WriteString(`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>`)
+	b.WriteString(`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>`)
+	fmt.Fprintf(&b, `<cellXfs count="%d">`, xlsxStyle(xlsxTotalBand, xlsxAmount)+1)
+	b.WriteString(`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>`)
+	b.WriteString(`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>`)
+	for _, band := range []int{xlsxHeaderBand, xlsxFirstBand, xlsxSecondBand, xlsxTotalBand} {
+		font := 0
+		if band == xlsxHeaderBand || band == xlsxTotalBand {
+			font = 1
+		}
+		for _, kind := range []int{xlsxText, xlsxDuration, xlsxAmount} {
+			fmt.Fprintf(&b, `<xf numFmtId="%d" fontId="%d" fillId="%d" borderId="0" xfId="0" applyNumberFormat="1" applyFont="1" applyFill="1"/>`,
+				kindFormats[kind], font, bandFills[band])
+		}
+	}
+	b.WriteString(`</cellXfs>`)
+	b.WriteString(`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>`)
+	b.WriteString(`</styleSheet>`)
+	return b.String()
+}
@@ -0,0 +1,115 @@
+package pagertally
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/datasources"
+	"github.com/leosunmo/pagertally/pkg/outputs"
+	"github.com/leosunmo/pagertally/pkg/process"
+	"github.com/leosunmo/pagertally/pkg/timespan"
+	"github.com/olekukonko/tablewriter"
+)
+
+// explainTimeFormat is the format of the times in printed explanations
+const explainTimeFormat = "Mon 2006-01-02 15:04 MST"
+
+// Explain traces how the time user was on call at the instant at was attributed, on every schedule they were on call for then.
+// The user is matched by PagerDuty user ID, email or name. The instant has to be in the reporting period of the options
+func Explain(ctx context.Context, opts Options, user string, at time.Time) ([]process.Explanation, error) {
+	if at.Before(opts.Start) || !at.Before(opts.End) {
+		return nil, fmt.Errorf("%s isn't in the reporting period %s - %s", at, opts.Start, opts.End)
+	}
+	global, profiles, err := opts.scheduleConfigs()
+	if err != nil {
+		return nil, err
+	}
+	schedules, _, err := opts.readSchedules(ctx, global, false)
+	if err != nil {
+		return nil, err
+	}
+
+	scheduleNames := []string{}
+	for schedule := range schedules.UserShifts {
+		scheduleNames = append(scheduleNames, string(schedule))
+	}
+	sort.Strings(scheduleNames)
+	scheduleDatasources := datasources.PerSchedule(global, profiles)
+	explanations := []process.Explanation{}
+	for _, name := range scheduleNames {
+		schedule := timespan.ScheduleName(name)
+		for pdUser, shifts := range schedules.UserShifts[schedule] {
+			if !matchesUser(pdUser, user) {
+				continue
+			}
+			ds, err := scheduleDatasources(schedules.IDs[schedule])
+			if err != nil {
+				return nil, fmt.Errorf("schedule %s: %s", schedule, err.Error())
+			}
+			explanation, covered, err := process.Explain(ctx, pdUser, schedule, shifts, at, ds)
+			if err != nil {
+				return nil, fmt.Errorf("schedule %s: %s", schedule, err.Error())
+			}
+			if covered {
+				explanations = append(explanations, explanation)
+			}
+		}
+	}
+	if len(explanations) == 0 {
+		return nil, fmt.Errorf("%s wasn't on call for any of the schedules at %s", user, at)
+	}
+	return explanations, nil
+}
+
+// matchesUser returns true if the PagerDuty user has the ID, email or name user, ignoring case
+func matchesUser(pdUser timespan.User, user string) bool {
+	return strings.EqualFold(pdUser.ID, user) || strings.EqualFold(pdUser.Email, user) || strings.EqualFold(pdUser.Name, user)
+}
+
+// PrintExplanations prints how the instant of every explanation was attributed, with a table of
+// every datasource and why it did or didn't decide the attribute. Times are in the user's timezone
+func PrintExplanations(w io.Writer, explanations []process.Explanation, labels outputs.UserLabels) {
+	for _, explanation := range explanations {
+		loc := explanation.Location
+		fmt.Fprintf(w, "Schedule: %s\n", explanation.Schedule)
+		fmt.Fprintf(w, "User: %s (%s)\n", labels.Label(explanation.User), loc)
+		fmt.Fprintf(w, "At: %s\n", explanation.At.In(loc).Format(explainTimeFormat))
+		fmt.Fprintf(w, "Shift: %s\n", explainSpan(explanation.Shift, loc))
+		fmt.Fprintf(w, "Attributed as: %s\n", explainedAttributes(explanation.Attributed, explanation.Attributes))
+
+		writer := tablewriter.NewWriter(w)
+		writer.SetHeader([]string{"Datasource", "Attribute", "Span", "Source", "Decision", "Reason"})
+		for _, source := range explanation.Sources {
+			span := "-"
+			if !source.Span.IsZero() {
+				span = explainSpan(source.Span, loc)
+			}
+			writer.Append([]string{source.Name, explanation.Attributes.Name(source.Attribute), span, source.Description, source.Decision, source.Reason})
+		}
+		writer.Render()
+		fmt.Fprintln(w)
+	}
+}
+
+// explainSpan returns the start and end of span in loc
+func explainSpan(span timespan.Span, loc *time.Location) string {
+	return span.Start().In(loc).Format(explainTimeFormat) + " - " + span.End().In(loc).Format(explainTimeFormat)
+}
+
+// explainedAttributes returns the attribute of the span with the ones stacked on it, named by names, and the name of
+// what it was attributed to if it has one
+func explainedAttributes(attributed timespan.AttributedSpan, names *timespan.Attributes) string {
+	attrs := []string{names.Name(attributed.SpanType)}
+	for _, attr := range attributed.Stacked {
+		attrs = append(attrs, names.Name(attr))
+	}
+	label := strings.Join(attrs, " + ")
+	if attributed.Name != "" {
+		label += " (" + attributed.Name + ")"
+	}
+	return label
+}
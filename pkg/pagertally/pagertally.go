@@ -0,0 +1,141 @@
+// Package pagertally tallies the time users were on call for PagerDuty schedules, for embedding
+// pagertally in other programs. Everything a tally needs is passed in its Options rather than read
+// from the command line flags and config file, and errors are returned rather than exiting
+package pagertally
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/config"
+	"github.com/leosunmo/pagertally/pkg/datasources"
+	"github.com/leosunmo/pagertally/pkg/outputs"
+	"github.com/leosunmo/pagertally/pkg/pd"
+	"github.com/leosunmo/pagertally/pkg/process"
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+// Report is the tallied on-call time of every user on the schedules, ready to be printed by the outputters
+type Report = outputs.OutputData
+
+// Options are the settings of a single tally
+type Options struct {
+	// PDToken is the PagerDuty API token
+	PDToken string
+	// Schedules are the IDs of the PagerDuty schedules to tally, on top of the ones selected by Filter
+	Schedules []string
+	Filter    pd.ScheduleFilter
+	// Start and End are the reporting period, named PeriodName in the outputs
+	Start      time.Time
+	End        time.Time
+	PeriodName string
+	// Config is the config of the schedules without one in ScheduleConfigs, including the compensation of all schedules
+	Config config.ScheduleConfig
+	// ScheduleConfigs are the configs of schedules with their own profile, by lower case PagerDuty schedule ID.
+	// Their time bands are registered in the Attributes of Config, as that's what names them in the report
+	ScheduleConfigs map[string]config.ScheduleConfig
+	// Incidents reads the schedules' incidents and adds their callout fees
+	Incidents bool
+	// OverlapMode is how time a user is on call for more than one schedule at once is counted,
+	// defaults to process.OverlapSum
+	OverlapMode string
+	UserLabels  outputs.UserLabels
+}
+
+// Tally reads the shifts of the schedules from PagerDuty and attributes and compensates the on-call time of every user.
+// PagerDuty requests and datasources are cancelled once ctx is done
+func Tally(ctx context.Context, opts Options) (Report, error) {
+	global, profiles, err := opts.scheduleConfigs()
+	if err != nil {
+		return Report{}, err
+	}
+
+	schedules, scheduleIncidents, err := opts.readSchedules(ctx, global, opts.Incidents)
+	if err != nil {
+		return Report{}, err
+	}
+	return opts.report(ctx, global, profiles, schedules, scheduleIncidents)
+}
+
+// report attributes and compensates the shifts of the schedules read from PagerDuty with the validated configs
+func (opts Options) report(ctx context.Context, global config.ScheduleConfig, profiles map[string]config.ScheduleConfig, schedules pd.Schedules, scheduleIncidents timespan.ScheduleIncidents) (Report, error) {
+	results, err := process.ScheduleUserShifts(ctx, schedules.UserShifts, scheduleIncidents, schedules.Overrides, schedules.IDs, schedules.Levels, datasources.PerSchedule(global, profiles))
+	if err != nil {
+		return Report{}, fmt.Errorf("failed attributing shifts, %s", err.Error())
+	}
+	comp := global.Compensation
+	results, overlaps, err := process.ResolveOverlaps(results, opts.OverlapMode, func(result timespan.UserShiftResults, attr timespan.OnCallAttribute) float64 {
+		return comp.ScheduleHourlyRate(result.ScheduleID, string(result.Schedule), result.EscalationLevel, attr)
+	})
+	if err != nil {
+		return Report{}, fmt.Errorf("failed resolving overlapping shifts, %s", err.Error())
+	}
+
+	report := outputs.NewOutputData(results, opts.Start, opts.End, opts.PeriodName, comp, global.Attributes, opts.Incidents, opts.UserLabels)
+	report.Overlaps = overlaps
+	return report, nil
+}
+
+// readSchedules reads the shifts of the schedules of the options from PagerDuty, and their incidents if incidents is set.
+// Requests are cancelled once ctx is done
+func (opts Options) readSchedules(ctx context.Context, global config.ScheduleConfig, incidents bool) (pd.Schedules, timespan.ScheduleIncidents, error) {
+	client := pd.WithContext(ctx, pd.NewPDClient(opts.PDToken))
+	scheduleIDs, err := pd.DiscoverSchedules(client, opts.Schedules, opts.Filter)
+	if err != nil {
+		return pd.Schedules{}, nil, fmt.Errorf("failed discovering PagerDuty schedules, %s", err.Error())
+	}
+	if err = ctx.Err(); err != nil {
+		return pd.Schedules{}, nil, err
+	}
+
+	schedules, err := pd.ReadShifts(client, scheduleIDs, opts.Start, opts.End, pd.Timezones{
+		Force: global.ForceTimezone,
+		Users: global.UserTimezones,
+	})
+	if err != nil {
+		return pd.Schedules{}, nil, fmt.Errorf("failed retrieving PagerDuty schedules, %s", err.Error())
+	}
+	if err = ctx.Err(); err != nil {
+		return pd.Schedules{}, nil, err
+	}
+
+	var scheduleIncidents timespan.ScheduleIncidents
+	if incidents {
+		scheduleIncidents, err = pd.ReadIncidents(client, schedules, opts.Start, opts.End)
+		if err != nil {
+			return pd.Schedules{}, nil, fmt.Errorf("failed retrieving PagerDuty incidents, %s", err.Error())
+		}
+		if err = ctx.Err(); err != nil {
+			return pd.Schedules{}, nil, err
+		}
+	}
+	return schedules, scheduleIncidents, nil
+}
+
+// scheduleConfigs returns copies of the configs of the options with the reporting period as their schedule span,
+// or an error if any of them are invalid
+func (opts Options) scheduleConfigs() (config.ScheduleConfig, map[string]config.ScheduleConfig, error) {
+	if !opts.End.After(opts.Start) {
+		return config.ScheduleConfig{}, nil, fmt.Errorf("end %s isn't after start %s", opts.End, opts.Start)
+	}
+	period := timespan.New(opts.Start, opts.End)
+	global := opts.Config
+	global.ScheduleSpan = period
+	if err := global.Validate(); err != nil {
+		return config.ScheduleConfig{}, nil, err
+	}
+	profiles := map[string]config.ScheduleConfig{}
+	for id, sc := range opts.ScheduleConfigs {
+		sc.ScheduleSpan = period
+		if err := sc.Validate(); err != nil {
+			return config.ScheduleConfig{}, nil, fmt.Errorf("schedule %s: %s", id, err.Error())
+		}
+		if sc.Attributes != nil && sc.Attributes != global.Attributes {
+			return config.ScheduleConfig{}, nil, fmt.Errorf("schedule %s: time bands have to be registered in the attributes of the global config", id)
+		}
+		profiles[strings.ToLower(id)] = sc
+	}
+	return global, profiles, nil
+}
@@ -0,0 +1,194 @@
+package pagertally
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/compensation"
+	"github.com/leosunmo/pagertally/pkg/config"
+	"github.com/leosunmo/pagertally/pkg/pd"
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+var (
+	periodStart = time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	periodEnd   = time.Date(2019, time.February, 1, 0, 0, 0, 0, time.UTC)
+	user        = timespan.User{ID: "PUSER", Name: "User"}
+)
+
+// nightConfig returns a config with a time band named night from start to end, paid rate per hour
+func nightConfig(t *testing.T, start, end string, rate float64) config.ScheduleConfig {
+	attrs := timespan.NewAttributes()
+	night, err := attrs.Register("night")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	return config.ScheduleConfig{
+		Timezone:      "UTC",
+		BusinessHours: config.BusinessHoursStruct{Start: "09:00", End: "17:00"},
+		TimeBands: []config.TimeBand{{
+			Name:      "night",
+			Weekly:    []config.WeeklyWindow{{Start: start, End: end}},
+			Attribute: night,
+		}},
+		Attributes: attrs,
+		Compensation: compensation.Config{
+			Currency: "NZD",
+			Rates:    compensation.Rates{night: rate},
+		},
+	}
+}
+
+// fixtureSchedules returns a single schedule with the user on call overnight from Monday to Tuesday
+func fixtureSchedules() pd.Schedules {
+	monday := time.Date(2019, time.January, 7, 0, 0, 0, 0, time.UTC)
+	return pd.Schedules{
+		UserShifts: timespan.ScheduleUserShifts{
+			"Primary": timespan.UserShifts{
+				user: []timespan.Span{timespan.New(monday.Add(20*time.Hour), monday.Add(32*time.Hour))},
+			},
+		},
+		Overrides: timespan.ScheduleOverrides{},
+		IDs:       timespan.ScheduleIDs{"Primary": "PSCHED1"},
+		Levels:    timespan.EscalationLevels{},
+	}
+}
+
+func TestConcurrentTallies(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end string
+		rate       float64
+		night      time.Duration
+		expected   float64
+	}{
+		// Two configs with a time band of the same name, in different windows and at different rates
+		{name: "late night", start: "22:00", end: "06:00", rate: 10, night: 8 * time.Hour, expected: 80},
+		{name: "early morning", start: "00:00", end: "04:00", rate: 30, night: 4 * time.Hour, expected: 120},
+	}
+
+	// Every round registers its time bands again, in new configs
+	nights := map[timespan.OnCallAttribute]bool{}
+	for round := 0; round < 2; round++ {
+		configs := make([]config.ScheduleConfig, len(tests))
+		for i, test := range tests {
+			configs[i] = nightConfig(t, test.start, test.end, test.rate)
+		}
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 10*len(tests))
+		reports := make([][]Report, len(tests))
+		for i := range tests {
+			reports[i] = make([]Report, 10)
+			for run := range reports[i] {
+				wg.Add(1)
+				go func(i, run int, opts Options) {
+					defer wg.Done()
+					global, profiles, err := opts.scheduleConfigs()
+					if err != nil {
+						errs <- err
+						return
+					}
+					reports[i][run], err = opts.report(context.Background(), global, profiles, fixtureSchedules(), nil)
+					if err != nil {
+						errs <- err
+					}
+				}(i, run, Options{Start: periodStart, End: periodEnd, Config: configs[i]})
+			}
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+
+		for i, test := range tests {
+			night := configs[i].TimeBands[0].Attribute
+			nights[night] = true
+			// The process-wide names of the built in attributes don't grow with the time bands of tallies
+			if name := night.String(); name != "unknown" {
+				t.Errorf("%s: expected night not to be named outside its config, got %s", test.name, name)
+			}
+			for _, report := range reports[i] {
+				if len(report.Schedules) != 1 || len(report.Schedules[0].UserShifts) != 1 {
+					t.Fatalf("%s: expected a single user on a single schedule, got %+v", test.name, report.Schedules)
+				}
+				summary := report.Schedules[0].UserShifts[0]
+				if summary.Durations.ByAttribute[night] != test.night {
+					t.Errorf("%s: expected %s at night, got %s", test.name, test.night, summary.Durations.ByAttribute[night])
+				}
+				if summary.Amounts.Total() != test.expected {
+					t.Errorf("%s: expected %.2f owed, got %.2f", test.name, test.expected, summary.Amounts.Total())
+				}
+				if attrs := report.Attributes; len(attrs) != len(timespan.BuiltInAttributes())+1 || attrs[len(attrs)-1] != night {
+					t.Errorf("%s: expected the built in attributes and its own night, got %v", test.name, attrs)
+				}
+				if name := report.Registry.Name(night); name != "night" {
+					t.Errorf("%s: expected the report to name night, got %s", test.name, name)
+				}
+			}
+		}
+	}
+	// Attributes are numbered per config, so registering them again doesn't use up new ones
+	if len(nights) != 1 {
+		t.Errorf("Expected every config to number night the same, got %v", nights)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	valid := config.ScheduleConfig{Timezone: "UTC", BusinessHours: config.BusinessHoursStruct{Start: "09:00", End: "17:00"}}
+	invalidTimezone := valid
+	invalidTimezone.Timezone = "Pacific/Nowhere"
+	invalidHours := valid
+	invalidHours.BusinessHours.End = "5pm"
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "timezone", opts: Options{Start: periodStart, End: periodEnd, Config: invalidTimezone}},
+		{name: "business hours", opts: Options{Start: periodStart, End: periodEnd, Config: invalidHours}},
+		{name: "schedule profile", opts: Options{Start: periodStart, End: periodEnd, Config: valid, ScheduleConfigs: map[string]config.ScheduleConfig{"PSCHED1": invalidTimezone}}},
+		{name: "period", opts: Options{Start: periodEnd, End: periodStart, Config: valid}},
+		{name: "schedule profile attributes", opts: Options{Start: periodStart, End: periodEnd, Config: nightConfig(t, "22:00", "06:00", 10), ScheduleConfigs: map[string]config.ScheduleConfig{"PSCHED1": nightConfig(t, "00:00", "04:00", 30)}}},
+	}
+	for _, test := range tests {
+		// The config is checked before PagerDuty is asked for anything
+		if _, err := Tally(context.Background(), test.opts); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestAllowancesInScheduleTimezone(t *testing.T) {
+	global := config.ScheduleConfig{
+		Timezone:      "UTC",
+		BusinessHours: config.BusinessHoursStruct{Start: "09:00", End: "17:00"},
+		Compensation: compensation.Config{
+			Currency:   "NZD",
+			Allowances: compensation.Allowances{timespan.AfterHours: {PerDay: 10}},
+		},
+	}
+	profile := global
+	profile.Timezone = "Pacific/Auckland"
+	opts := Options{Start: periodStart, End: periodEnd, Config: global, ScheduleConfigs: map[string]config.ScheduleConfig{"PSCHED1": profile}}
+	global, profiles, err := opts.scheduleConfigs()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	// Monday 09:00 to 15:00 in UTC is Monday 22:00 to Tuesday 04:00 in Auckland
+	monday := time.Date(2019, time.January, 7, 0, 0, 0, 0, time.UTC)
+	schedules := fixtureSchedules()
+	schedules.UserShifts["Primary"][user] = []timespan.Span{timespan.New(monday.Add(9*time.Hour), monday.Add(15*time.Hour))}
+	report, err := opts.report(context.Background(), global, profiles, schedules, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	summary := report.Schedules[0].UserShifts[0]
+	if summary.AllowanceDays[timespan.AfterHours] != 2 || summary.Allowances.Total() != 20 {
+		t.Errorf("Expected 2 after hours allowance days owing 20, got %d owing %.2f", summary.AllowanceDays[timespan.AfterHours], summary.Allowances.Total())
+	}
+}
@@ -0,0 +1,251 @@
+package pd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PagerDuty/go-pagerduty"
+	log "github.com/sirupsen/logrus"
+)
+
+// ScheduleFilter selects PagerDuty schedules to process on top of the ones listed by ID
+type ScheduleFilter struct {
+	// Teams are team IDs or names, selecting all schedules of those teams
+	Teams []string
+	// EscalationPolicies are escalation policy IDs or names, selecting all schedules they escalate to
+	EscalationPolicies []string
+	// Match selects schedules by name. If Teams or EscalationPolicies are set,
+	// it only selects from their schedules, otherwise from all schedules
+	Match *regexp.Regexp
+}
+
+// Empty returns true if the filter doesn't select any schedules
+func (f ScheduleFilter) Empty() bool {
+	return len(f.Teams) == 0 && len(f.EscalationPolicies) == 0 && f.Match == nil
+}
+
+// DiscoverSchedules returns the IDs of PdSchedules plus all schedules selected by filter,
+// logging every schedule included and why so the report can be reproduced
+func DiscoverSchedules(client *pagerduty.Client, PdSchedules []string, filter ScheduleFilter) ([]string, error) {
+	if filter.Empty() {
+		return PdSchedules, nil
+	}
+	selected := map[string]string{}
+	for _, id := range PdSchedules {
+		selected[id] = "listed in schedules"
+	}
+
+	schedules, err := listSchedules(client)
+	if err != nil {
+		return nil, err
+	}
+	teamIDs, err := findTeams(client, filter.Teams)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := listEscalationPolicies(client, filter)
+	if err != nil {
+		return nil, err
+	}
+	policySchedules, err := findPolicySchedules(policies, filter.EscalationPolicies)
+	if err != nil {
+		return nil, err
+	}
+	teamSchedules := findTeamSchedules(policies, teamIDs)
+
+	names := map[string]string{}
+	for _, schedule := range schedules {
+		names[schedule.ID] = schedule.Name
+		reason := ""
+		if policy, exists := policySchedules[schedule.ID]; exists {
+			reason = fmt.Sprintf("escalation policy %q", policy)
+		}
+		if team, exists := teamSchedules[schedule.ID]; exists {
+			reason = fmt.Sprintf("team %q", team)
+		}
+		if len(filter.Teams) == 0 && len(filter.EscalationPolicies) == 0 {
+			reason = "all schedules"
+		}
+		if reason == "" {
+			continue
+		}
+		if filter.Match != nil {
+			if !filter.Match.MatchString(schedule.Name) {
+				continue
+			}
+			reason += fmt.Sprintf(" matching %q", filter.Match.String())
+		}
+		if _, exists := selected[schedule.ID]; !exists {
+			selected[schedule.ID] = reason
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no schedules matched")
+	}
+
+	ids := []string{}
+	for id := range selected {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		log.Infof("Including schedule %s %q, %s", id, names[id], selected[id])
+	}
+	return ids, nil
+}
+
+// listSchedules returns all schedules in the PagerDuty account
+func listSchedules(client *pagerduty.Client) ([]pagerduty.Schedule, error) {
+	schedules := []pagerduty.Schedule{}
+	opts := pagerduty.ListSchedulesOptions{
+		APIListObject: pagerduty.APIListObject{Limit: pdListLimit},
+	}
+	for {
+		resp, err := client.ListSchedules(opts)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, resp.Schedules...)
+		if !resp.More {
+			break
+		}
+		opts.Offset += uint(len(resp.Schedules))
+	}
+	return schedules, nil
+}
+
+// findTeams returns the names of the teams by ID, looking them up by ID or name
+func findTeams(client *pagerduty.Client, teams []string) (map[string]string, error) {
+	found := map[string]string{}
+	if len(teams) == 0 {
+		return found, nil
+	}
+	opts := pagerduty.ListTeamOptions{
+		APIListObject: pagerduty.APIListObject{Limit: pdListLimit},
+	}
+	for {
+		resp, err := client.ListTeams(opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, team := range resp.Teams {
+			if matchesIDOrName(teams, team.ID, team.Name) {
+				found[team.ID] = team.Name
+			}
+		}
+		if !resp.More {
+			break
+		}
+		opts.Offset += uint(len(resp.Teams))
+	}
+	for _, team := range teams {
+		if !foundIDOrName(found, team) {
+			return nil, fmt.Errorf("team %q not found", team)
+		}
+	}
+	return found, nil
+}
+
+// listEscalationPolicies returns all escalation policies in the PagerDuty account if the filter selects schedules
+// by team or escalation policy, teams own schedules through the escalation policies that escalate to them
+func listEscalationPolicies(client *pagerduty.Client, filter ScheduleFilter) ([]pagerduty.EscalationPolicy, error) {
+	policies := []pagerduty.EscalationPolicy{}
+	if len(filter.Teams) == 0 && len(filter.EscalationPolicies) == 0 {
+		return policies, nil
+	}
+	opts := pagerduty.ListEscalationPoliciesOptions{
+		APIListObject: pagerduty.APIListObject{Limit: pdListLimit},
+	}
+	for {
+		resp, err := client.ListEscalationPolicies(opts)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, resp.EscalationPolicies...)
+		if !resp.More {
+			break
+		}
+		opts.Offset += uint(len(resp.EscalationPolicies))
+	}
+	return policies, nil
+}
+
+// findPolicySchedules returns the names of the escalation policies that escalate to each schedule by schedule ID,
+// looking the policies up by ID or name
+func findPolicySchedules(policies []pagerduty.EscalationPolicy, wanted []string) (map[string]string, error) {
+	policySchedules := map[string]string{}
+	found := map[string]string{}
+	for _, policy := range policies {
+		if !matchesIDOrName(wanted, policy.ID, policy.Name) {
+			continue
+		}
+		found[policy.ID] = policy.Name
+		for id := range policyScheduleIDs(policy) {
+			policySchedules[id] = policy.Name
+		}
+	}
+	for _, policy := range wanted {
+		if !foundIDOrName(found, policy) {
+			return nil, fmt.Errorf("escalation policy %q not found", policy)
+		}
+	}
+	return policySchedules, nil
+}
+
+// findTeamSchedules returns the names of the teams by the IDs of the schedules their escalation policies escalate to,
+// given the names of the teams by ID
+func findTeamSchedules(policies []pagerduty.EscalationPolicy, teamIDs map[string]string) map[string]string {
+	teamSchedules := map[string]string{}
+	for _, policy := range policies {
+		for _, team := range policy.Teams {
+			teamName, exists := teamIDs[team.ID]
+			if !exists {
+				continue
+			}
+			for id := range policyScheduleIDs(policy) {
+				teamSchedules[id] = teamName
+			}
+		}
+	}
+	return teamSchedules
+}
+
+// policyScheduleIDs returns the IDs of the schedules the escalation policy escalates to
+func policyScheduleIDs(policy pagerduty.EscalationPolicy) map[string]bool {
+	ids := map[string]bool{}
+	for _, rule := range policy.EscalationRules {
+		for _, target := range rule.Targets {
+			if isScheduleTarget(target) {
+				ids[target.ID] = true
+			}
+		}
+	}
+	return ids
+}
+
+// isScheduleTarget returns true if the escalation rule target is a schedule rather than a user
+func isScheduleTarget(target pagerduty.APIObject) bool {
+	return target.Type == "schedule" || target.Type == "schedule_reference"
+}
+
+// matchesIDOrName returns true if any of wanted is the ID or, ignoring case, the name
+func matchesIDOrName(wanted []string, id, name string) bool {
+	for _, w := range wanted {
+		if w == id || strings.EqualFold(w, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// foundIDOrName returns true if wanted is any of the IDs or names in found
+func foundIDOrName(found map[string]string, wanted string) bool {
+	for id, name := range found {
+		if wanted == id || strings.EqualFold(wanted, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package pd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/PagerDuty/go-pagerduty"
+)
+
+// fakePagerDuty serves the JSON of a response by API path, and 404s for any other path
+type fakePagerDuty map[string]interface{}
+
+func (f fakePagerDuty) Do(req *http.Request) (*http.Response, error) {
+	body, exists := f[req.URL.Path]
+	if !exists {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(strings.NewReader(`{"error":{"message":"Not Found"}}`))}, nil
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(b))}, nil
+}
+
+// newFakeClient returns a PagerDuty client answering from responses instead of the API
+func newFakeClient(responses fakePagerDuty) *pagerduty.Client {
+	client := pagerduty.NewClient("token")
+	client.HTTPClient = responses
+	return client
+}
+
+func scheduleTarget(id string) pagerduty.EscalationRule {
+	return pagerduty.EscalationRule{Targets: []pagerduty.APIObject{{ID: id, Type: "schedule_reference"}}}
+}
+
+var discoveryFixture = fakePagerDuty{
+	"/schedules": pagerduty.ListSchedulesResponse{Schedules: []pagerduty.Schedule{
+		{APIObject: pagerduty.APIObject{ID: "S1"}, Name: "Platform Primary"},
+		{APIObject: pagerduty.APIObject{ID: "S2"}, Name: "Platform Secondary"},
+		{APIObject: pagerduty.APIObject{ID: "S3"}, Name: "Payments Primary"},
+		{APIObject: pagerduty.APIObject{ID: "S4"}, Name: "Orphan"},
+	}},
+	"/teams": pagerduty.ListTeamResponse{Teams: []pagerduty.Team{
+		{APIObject: pagerduty.APIObject{ID: "T1"}, Name: "Platform"},
+		{APIObject: pagerduty.APIObject{ID: "T2"}, Name: "Payments"},
+	}},
+	"/escalation_policies": pagerduty.ListEscalationPoliciesResponse{EscalationPolicies: []pagerduty.EscalationPolicy{
+		{
+			APIObject:       pagerduty.APIObject{ID: "EP1"},
+			Name:            "Platform EP",
+			Teams:           []pagerduty.APIReference{{ID: "T1", Type: "team_reference"}},
+			EscalationRules: []pagerduty.EscalationRule{scheduleTarget("S1"), scheduleTarget("S2")},
+		},
+		{
+			APIObject: pagerduty.APIObject{ID: "EP2"},
+			Name:      "Payments EP",
+			Teams:     []pagerduty.APIReference{{ID: "T2", Type: "team_reference"}},
+			EscalationRules: []pagerduty.EscalationRule{
+				scheduleTarget("S3"),
+				{Targets: []pagerduty.APIObject{{ID: "PUSER", Type: "user_reference"}}},
+			},
+		},
+	}},
+}
+
+func TestDiscoverSchedules(t *testing.T) {
+	tests := []struct {
+		name      string
+		schedules []string
+		filter    ScheduleFilter
+		expected  []string
+		err       string
+	}{
+		{name: "no filter", schedules: []string{"S9"}, expected: []string{"S9"}},
+		{name: "team by name", filter: ScheduleFilter{Teams: []string{"Platform"}}, expected: []string{"S1", "S2"}},
+		{name: "team by name ignoring case", filter: ScheduleFilter{Teams: []string{"payments"}}, expected: []string{"S3"}},
+		{name: "team by ID", filter: ScheduleFilter{Teams: []string{"T2"}}, expected: []string{"S3"}},
+		{name: "escalation policy by ID", filter: ScheduleFilter{EscalationPolicies: []string{"EP2"}}, expected: []string{"S3"}},
+		{name: "escalation policy by name", filter: ScheduleFilter{EscalationPolicies: []string{"Platform EP"}}, expected: []string{"S1", "S2"}},
+		{name: "match all schedules", filter: ScheduleFilter{Match: regexp.MustCompile("Primary$")}, expected: []string{"S1", "S3"}},
+		{name: "match team schedules", filter: ScheduleFilter{Teams: []string{"Platform"}, Match: regexp.MustCompile("(?i)primary")}, expected: []string{"S1"}},
+		{name: "listed and team", schedules: []string{"S4"}, filter: ScheduleFilter{Teams: []string{"Payments"}}, expected: []string{"S3", "S4"}},
+		{name: "unknown team", filter: ScheduleFilter{Teams: []string{"Nope"}}, err: `team "Nope" not found`},
+		{name: "unknown escalation policy", filter: ScheduleFilter{EscalationPolicies: []string{"EP9"}}, err: `escalation policy "EP9" not found`},
+		{name: "nothing matched", filter: ScheduleFilter{Teams: []string{"Payments"}, Match: regexp.MustCompile("Secondary")}, err: "no schedules matched"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ids, err := DiscoverSchedules(newFakeClient(discoveryFixture), test.schedules, test.filter)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("Expected error %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err.Error())
+			}
+			if !reflect.DeepEqual(ids, test.expected) {
+				t.Errorf("Expected schedules %v, got %v", test.expected, ids)
+			}
+		})
+	}
+}
+
+func TestFindTeams(t *testing.T) {
+	tests := []struct {
+		teams    []string
+		expected map[string]string
+		err      string
+	}{
+		{teams: nil, expected: map[string]string{}},
+		{teams: []string{"platform", "T2"}, expected: map[string]string{"T1": "Platform", "T2": "Payments"}},
+		{teams: []string{"Platform", "Search"}, err: `team "Search" not found`},
+	}
+	for _, test := range tests {
+		found, err := findTeams(newFakeClient(discoveryFixture), test.teams)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%v: expected error %q, got %v", test.teams, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %s", test.teams, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(found, test.expected) {
+			t.Errorf("%v: expected %v, got %v", test.teams, test.expected, found)
+		}
+	}
+}
+
+func TestFindPolicySchedules(t *testing.T) {
+	policies := discoveryFixture["/escalation_policies"].(pagerduty.ListEscalationPoliciesResponse).EscalationPolicies
+	tests := []struct {
+		policies []string
+		expected map[string]string
+		err      string
+	}{
+		{policies: nil, expected: map[string]string{}},
+		{policies: []string{"payments ep"}, expected: map[string]string{"S3": "Payments EP"}},
+		{policies: []string{"EP1", "EP2"}, expected: map[string]string{"S1": "Platform EP", "S2": "Platform EP", "S3": "Payments EP"}},
+		{policies: []string{"EP1", "Search EP"}, err: `escalation policy "Search EP" not found`},
+	}
+	for _, test := range tests {
+		found, err := findPolicySchedules(policies, test.policies)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%v: expected error %q, got %v", test.policies, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %s", test.policies, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(found, test.expected) {
+			t.Errorf("%v: expected %v, got %v", test.policies, test.expected, found)
+		}
+	}
+
+	teamSchedules := findTeamSchedules(policies, map[string]string{"T1": "Platform"})
+	if expected := map[string]string{"S1": "Platform", "S2": "Platform"}; !reflect.DeepEqual(teamSchedules, expected) {
+		t.Errorf("Expected the Platform team's schedules %v, got %v", expected, teamSchedules)
+	}
+}
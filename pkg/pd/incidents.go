@@ -0,0 +1,178 @@
+package pd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/PagerDuty/go-pagerduty"
+	"github.com/leosunmo/pagertally/pkg/timespan"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	pdListLimit = 100
+
+	acknowledgeLogEntry = "acknowledge_log_entry"
+	assignLogEntry      = "assign_log_entry"
+	notifyLogEntry      = "notify_log_entry"
+	resolveLogEntry     = "resolve_log_entry"
+)
+
+// logEntry is a PagerDuty incident log entry. Unlike pagerduty.LogEntry it keeps the user
+// that was notified and the users the incident was assigned to
+type logEntry struct {
+	Type      string                `json:"type"`
+	CreatedAt string                `json:"created_at"`
+	Agent     pagerduty.APIObject   `json:"agent"`
+	User      pagerduty.APIObject   `json:"user"`
+	Assignees []pagerduty.APIObject `json:"assignees"`
+}
+
+type logEntriesResponse struct {
+	pagerduty.APIListObject
+	LogEntries []logEntry `json:"log_entries"`
+}
+
+// bodyRecorder is a PagerDuty HTTP client keeping the body of the last response, so it can be decoded
+// in to types the pagerduty package doesn't have
+type bodyRecorder struct {
+	client pagerduty.HTTPClient
+	body   []byte
+}
+
+func (r *bodyRecorder) Do(req *http.Request) (*http.Response, error) {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	defer resp.Body.Close()
+	r.body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(r.body))
+	return resp, nil
+}
+
+// ReadIncidents returns all incidents created between startDate and endDate that escalated
+// through an escalation policy using one of the schedules, per schedule name
+func ReadIncidents(client *pagerduty.Client, schedules Schedules, startDate, endDate time.Time) (timespan.ScheduleIncidents, error) {
+	// Invert the escalation policies of every schedule so we can tell which schedules an incident paged
+	policySchedules := map[string][]timespan.ScheduleName{}
+	for schedule, policies := range schedules.Policies {
+		for _, policy := range policies {
+			policySchedules[policy] = append(policySchedules[policy], schedule)
+		}
+	}
+
+	schdIncidents := make(timespan.ScheduleIncidents)
+	opts := pagerduty.ListIncidentsOptions{
+		APIListObject: pagerduty.APIListObject{Limit: pdListLimit},
+		Since:         startDate.Format(time.RFC3339),
+		Until:         endDate.Format(time.RFC3339),
+	}
+	for {
+		resp, err := client.ListIncidents(opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, pdIncident := range resp.Incidents {
+			schedules, exists := policySchedules[pdIncident.EscalationPolicy.ID]
+			if !exists {
+				continue
+			}
+			incident, ierr := readIncident(client, pdIncident)
+			if ierr != nil {
+				return nil, ierr
+			}
+			for _, schedule := range schedules {
+				schdIncidents[schedule] = append(schdIncidents[schedule], incident)
+			}
+		}
+		if !resp.More {
+			break
+		}
+		opts.Offset += uint(len(resp.Incidents))
+	}
+	return schdIncidents, nil
+}
+
+// readIncident converts a PagerDuty incident in to an Incident, using its log entries to find when
+// it was first acknowledged and last resolved, and the users that were notified or acknowledged it
+func readIncident(client *pagerduty.Client, pdIncident pagerduty.Incident) (timespan.Incident, error) {
+	created, err := time.Parse(time.RFC3339, pdIncident.CreatedAt)
+	if err != nil {
+		return timespan.Incident{}, err
+	}
+	// The ID is decoded in to Id, the embedded APIObject's ID is shadowed by it
+	incident := timespan.Incident{
+		ID:      pdIncident.Id,
+		Number:  pdIncident.IncidentNumber,
+		Title:   pdIncident.Title,
+		Created: created,
+	}
+
+	recorder := &bodyRecorder{client: client.HTTPClient}
+	logClient := *client
+	logClient.HTTPClient = recorder
+	responders := map[string]bool{}
+	opts := pagerduty.ListIncidentLogEntriesOptions{
+		APIListObject: pagerduty.APIListObject{Limit: pdListLimit},
+	}
+	for {
+		if _, lerr := logClient.ListIncidentLogEntries(incident.ID, opts); lerr != nil {
+			return timespan.Incident{}, lerr
+		}
+		var resp logEntriesResponse
+		if derr := json.Unmarshal(recorder.body, &resp); derr != nil {
+			return timespan.Incident{}, derr
+		}
+		for _, entry := range resp.LogEntries {
+			at, terr := time.Parse(time.RFC3339, entry.CreatedAt)
+			if terr != nil {
+				return timespan.Incident{}, terr
+			}
+			switch entry.Type {
+			case acknowledgeLogEntry:
+				if incident.Acknowledged.IsZero() || at.Before(incident.Acknowledged) {
+					incident.Acknowledged = at
+				}
+				addResponder(responders, &incident, entry.Agent)
+			case notifyLogEntry:
+				addResponder(responders, &incident, entry.User)
+			case assignLogEntry:
+				for _, assignee := range entry.Assignees {
+					addResponder(responders, &incident, assignee)
+				}
+			case resolveLogEntry:
+				if at.After(incident.Resolved) {
+					incident.Resolved = at
+				}
+			}
+		}
+		if !resp.More {
+			break
+		}
+		opts.Offset += uint(len(resp.LogEntries))
+	}
+	log.Debugf("Incident #%d %q created %s, acknowledged %s, resolved %s, responders %v", incident.Number, incident.Title, incident.Created, incident.Acknowledged, incident.Resolved, incident.Responders)
+	return incident, nil
+}
+
+// addResponder adds the user to the responders of the incident, unless it's already one of them
+// or isn't a user, like the integration acknowledging an incident
+func addResponder(responders map[string]bool, incident *timespan.Incident, user pagerduty.APIObject) {
+	if user.ID == "" || !isUserReference(user) || responders[user.ID] {
+		return
+	}
+	responders[user.ID] = true
+	incident.Responders = append(incident.Responders, user.ID)
+}
+
+// isUserReference returns true if the log entry object is a user rather than a service or integration
+func isUserReference(object pagerduty.APIObject) bool {
+	return object.Type == "user" || object.Type == "user_reference"
+}
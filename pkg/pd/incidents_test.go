@@ -0,0 +1,56 @@
+package pd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pagerduty"
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+func mustParseTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	return parsed
+}
+
+func TestReadIncidents(t *testing.T) {
+	client := newFakeClient(fakePagerDuty{
+		"/incidents": pagerduty.ListIncidentsResponse{Incidents: []pagerduty.Incident{
+			{Id: "I1", IncidentNumber: 1, CreatedAt: "2019-01-05T10:00:00Z", EscalationPolicy: pagerduty.APIObject{ID: "EP1"}},
+			{Id: "I2", IncidentNumber: 2, CreatedAt: "2019-01-06T10:00:00Z", EscalationPolicy: pagerduty.APIObject{ID: "EP9"}},
+		}},
+		"/incidents/I1/log_entries": logEntriesResponse{LogEntries: []logEntry{
+			{Type: "trigger_log_entry", CreatedAt: "2019-01-05T10:00:00Z", Agent: pagerduty.APIObject{ID: "PSERVICE", Type: "service_reference"}},
+			{Type: assignLogEntry, CreatedAt: "2019-01-05T10:00:00Z", Assignees: []pagerduty.APIObject{{ID: "PALICE", Type: "user_reference"}}},
+			{Type: notifyLogEntry, CreatedAt: "2019-01-05T10:00:00Z", User: pagerduty.APIObject{ID: "PALICE", Type: "user_reference"}},
+			{Type: acknowledgeLogEntry, CreatedAt: "2019-01-05T10:05:00Z", Agent: pagerduty.APIObject{ID: "PCAROL", Type: "user_reference"}},
+			{Type: resolveLogEntry, CreatedAt: "2019-01-05T11:00:00Z", Agent: pagerduty.APIObject{ID: "PCAROL", Type: "user_reference"}},
+		}},
+	})
+	schedules := Schedules{Policies: map[timespan.ScheduleName][]string{
+		"Primary":   {"EP1"},
+		"Secondary": {"EP1"},
+		"Other":     {"EP2"},
+	}}
+
+	// No schedule is looked up again, the fake would 404
+	incidents, err := ReadIncidents(client, schedules, mustParseTime(t, "2019-01-01T00:00:00Z"), mustParseTime(t, "2019-02-01T00:00:00Z"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(incidents) != 2 || len(incidents["Primary"]) != 1 || len(incidents["Secondary"]) != 1 {
+		t.Fatalf("Expected incident 1 on the Primary and Secondary schedules only, got %+v", incidents)
+	}
+	incident := incidents["Primary"][0]
+	if expected := []string{"PALICE", "PCAROL"}; !reflect.DeepEqual(incident.Responders, expected) {
+		t.Errorf("Expected responders %v, got %v", expected, incident.Responders)
+	}
+	if !incident.Acknowledged.Equal(mustParseTime(t, "2019-01-05T10:05:00Z")) || !incident.Resolved.Equal(mustParseTime(t, "2019-01-05T11:00:00Z")) {
+		t.Errorf("Expected incident 1 acknowledged at 10:05 and resolved at 11:00, got %s and %s", incident.Acknowledged, incident.Resolved)
+	}
+}
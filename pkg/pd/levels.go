@@ -0,0 +1,62 @@
+package pd
+
+import (
+	"github.com/PagerDuty/go-pagerduty"
+	log "github.com/sirupsen/logrus"
+)
+
+// policyCache looks up PagerDuty escalation policies by ID, fetching every policy only once
+type policyCache struct {
+	client   *pagerduty.Client
+	policies map[string]*pagerduty.EscalationPolicy
+}
+
+func newPolicyCache(client *pagerduty.Client) *policyCache {
+	return &policyCache{
+		client:   client,
+		policies: map[string]*pagerduty.EscalationPolicy{},
+	}
+}
+
+// escalationLevel returns the lowest escalation level the schedule is on call for in any of its
+// escalation policies, 1 being the first escalation rule. Returns 0 if no rule targets the schedule
+func (pc *policyCache) escalationLevel(schedule *pagerduty.Schedule) (int, error) {
+	level := 0
+	for _, ref := range schedule.EscalationPolicies {
+		policy, err := pc.policy(ref.ID)
+		if err != nil {
+			return 0, err
+		}
+		for i, rule := range policy.EscalationRules {
+			if targetsSchedule(rule, schedule.ID) && (level == 0 || i+1 < level) {
+				level = i + 1
+			}
+		}
+	}
+	if level == 0 {
+		log.Debugf("Schedule %s isn't targeted by any escalation policy, escalation level unknown", schedule.Name)
+	}
+	return level, nil
+}
+
+func (pc *policyCache) policy(id string) (*pagerduty.EscalationPolicy, error) {
+	if policy, exists := pc.policies[id]; exists {
+		return policy, nil
+	}
+	policy, err := pc.client.GetEscalationPolicy(id, &pagerduty.GetEscalationPolicyOptions{})
+	if err != nil {
+		return nil, err
+	}
+	pc.policies[id] = policy
+	return policy, nil
+}
+
+// targetsSchedule returns true if the escalation rule notifies whoever is on call for the schedule
+func targetsSchedule(rule pagerduty.EscalationRule, scheduleID string) bool {
+	for _, target := range rule.Targets {
+		if isScheduleTarget(target) && target.ID == scheduleID {
+			return true
+		}
+	}
+	return false
+}
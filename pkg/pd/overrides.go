@@ -0,0 +1,53 @@
+package pd
+
+import (
+	"github.com/PagerDuty/go-pagerduty"
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+// overrides returns the rendered overrides of the schedule, split by the user
+// that would have been on call according to the schedule layers without them
+func (uc *userCache) overrides(ds *pagerduty.Schedule) ([]timespan.Override, error) {
+	overrides := []timespan.Override{}
+	for _, oe := range ds.OverrideSubschedule.RenderedScheduleEntries {
+		overrideSpan, user, err := uc.scheduleEntry(oe)
+		if err != nil {
+			return nil, err
+		}
+		covered, err := uc.coveredUsers(ds.ScheduleLayers, overrideSpan)
+		if err != nil {
+			return nil, err
+		}
+		if len(covered) == 0 {
+			// Nobody would have been on call
+			overrides = append(overrides, timespan.Override{Span: overrideSpan, User: user})
+			continue
+		}
+		for _, c := range covered {
+			c.User = user
+			overrides = append(overrides, c)
+		}
+	}
+	return overrides, nil
+}
+
+// coveredUsers returns who was on call during span in the highest priority layer that
+// has anyone on call at all during span. PagerDuty lists layers highest priority first
+func (uc *userCache) coveredUsers(layers []pagerduty.ScheduleLayer, span timespan.Span) ([]timespan.Override, error) {
+	for _, layer := range layers {
+		covered := []timespan.Override{}
+		for _, le := range layer.RenderedScheduleEntries {
+			layerSpan, user, err := uc.scheduleEntry(le)
+			if err != nil {
+				return nil, err
+			}
+			if overlap, overlaps := span.Intersection(layerSpan); overlaps {
+				covered = append(covered, timespan.Override{Span: overlap, CoveredFor: user})
+			}
+		}
+		if len(covered) != 0 {
+			return covered, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,77 @@
+package pd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pagerduty"
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+var (
+	alice = timespan.User{ID: "PALICE", Name: "Alice"}
+	bob   = timespan.User{ID: "PBOB", Name: "Bob"}
+)
+
+// newTestUserCache returns a userCache that already knows users, so it never calls PagerDuty
+func newTestUserCache(users ...timespan.User) *userCache {
+	uc := newUserCache(nil, Timezones{})
+	for _, user := range users {
+		uc.users[user.ID] = user
+	}
+	return uc
+}
+
+func entry(user timespan.User, start, end string) pagerduty.RenderedScheduleEntry {
+	return pagerduty.RenderedScheduleEntry{
+		Start: start,
+		End:   end,
+		User:  pagerduty.APIObject{ID: user.ID, Summary: user.Name},
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	schedule := &pagerduty.Schedule{
+		Name: "Primary",
+		ScheduleLayers: []pagerduty.ScheduleLayer{{
+			RenderedScheduleEntries: []pagerduty.RenderedScheduleEntry{
+				entry(alice, "2019-01-01T09:00:00+13:00", "2019-01-02T09:00:00+13:00"),
+			},
+		}},
+		OverrideSubschedule: pagerduty.ScheduleLayer{
+			RenderedScheduleEntries: []pagerduty.RenderedScheduleEntry{
+				// Covering for Alice
+				entry(bob, "2019-01-01T12:00:00+13:00", "2019-01-01T18:00:00+13:00"),
+				// Nobody would have been on call
+				entry(bob, "2019-01-03T00:00:00+13:00", "2019-01-03T02:00:00+13:00"),
+			},
+		},
+	}
+	overrides, err := newTestUserCache(alice, bob).overrides(schedule)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(overrides) != 2 {
+		t.Fatalf("Expected 2 overrides, got %d: %+v", len(overrides), overrides)
+	}
+	nzdt := time.FixedZone("", 13*60*60)
+	expected := []timespan.Override{
+		{
+			Span:       timespan.New(time.Date(2019, time.January, 1, 12, 0, 0, 0, nzdt), time.Date(2019, time.January, 1, 18, 0, 0, 0, nzdt)),
+			User:       bob,
+			CoveredFor: alice,
+		},
+		{
+			Span: timespan.New(time.Date(2019, time.January, 3, 0, 0, 0, 0, nzdt), time.Date(2019, time.January, 3, 2, 0, 0, 0, nzdt)),
+			User: bob,
+		},
+	}
+	for i, override := range overrides {
+		if !override.Start().Equal(expected[i].Start()) || !override.End().Equal(expected[i].End()) {
+			t.Errorf("Override %d: expected %s to %s, got %s to %s", i, expected[i].Start(), expected[i].End(), override.Start(), override.End())
+		}
+		if override.User.ID != expected[i].User.ID || override.CoveredFor.ID != expected[i].CoveredFor.ID {
+			t.Errorf("Override %d: expected %s covering for %q, got %s covering for %q", i, expected[i].User.Name, expected[i].CoveredFor.Name, override.User.Name, override.CoveredFor.Name)
+		}
+	}
+}
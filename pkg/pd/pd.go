@@ -1,6 +1,8 @@
 package pd
 
 import (
+	"context"
+	"net/http"
 	"time"
 
 	"github.com/PagerDuty/go-pagerduty"
@@ -14,37 +16,94 @@ func NewPDClient(authtoken string) *pagerduty.Client {
 	return pagerduty.NewClient(authtoken)
 }
 
-// ReadShifts returns a UserShift per schedule in a ScheduleUserShifts map from PagerDuty
-func ReadShifts(client *pagerduty.Client, PdSchedules []string, startDate, endDate time.Time) (timespan.ScheduleUserShifts, error) {
+// WithContext returns a copy of client whose requests are cancelled once ctx is done
+func WithContext(ctx context.Context, client *pagerduty.Client) *pagerduty.Client {
+	withCtx := *client
+	withCtx.HTTPClient = contextHTTPClient{ctx: ctx, client: client.HTTPClient}
+	return &withCtx
+}
+
+// contextHTTPClient sends every request of a PagerDuty client with its context
+type contextHTTPClient struct {
+	ctx    context.Context
+	client pagerduty.HTTPClient
+}
+
+func (c contextHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	return c.client.Do(req.WithContext(c.ctx))
+}
+
+// Schedules are the shifts and overrides of the rendered PagerDuty schedules, by schedule name
+type Schedules struct {
+	UserShifts timespan.ScheduleUserShifts
+	Overrides  timespan.ScheduleOverrides
+	IDs        timespan.ScheduleIDs
+	Levels     timespan.EscalationLevels
+	// Policies are the IDs of the escalation policies using each schedule
+	Policies map[timespan.ScheduleName][]string
+}
+
+// ReadShifts returns a UserShift per schedule in a ScheduleUserShifts map from PagerDuty,
+// the overrides that were part of those shifts and the escalation level of each schedule.
+// Users are located in their timezone according to timezones
+func ReadShifts(client *pagerduty.Client, PdSchedules []string, startDate, endDate time.Time, timezones Timezones) (Schedules, error) {
 	getschopts := pagerduty.GetScheduleOptions{
 		Since: startDate.String(),
 		Until: endDate.String(),
 	}
-	schdUserShifts := make(timespan.ScheduleUserShifts)
+	schedules := Schedules{
+		UserShifts: make(timespan.ScheduleUserShifts),
+		Overrides:  make(timespan.ScheduleOverrides),
+		IDs:        make(timespan.ScheduleIDs),
+		Levels:     make(timespan.EscalationLevels),
+		Policies:   make(map[timespan.ScheduleName][]string),
+	}
+	users := newUserCache(client, timezones)
+	policies := newPolicyCache(client)
 	for _, PdSchedule := range PdSchedules {
 		us := make(timespan.UserShifts)
 		ds, err := client.GetSchedule(PdSchedule, getschopts)
 		if err != nil {
-			return nil, err
+			return Schedules{}, err
 		}
 		for _, se := range ds.FinalSchedule.RenderedScheduleEntries {
-			startTime, terr := time.Parse(pdTimeFormat, se.Start)
-			if terr != nil {
-				return nil, terr
-			}
-			endTime, terr := time.Parse(pdTimeFormat, se.End)
+			shiftSpan, user, terr := users.scheduleEntry(se)
 			if terr != nil {
-				return nil, terr
-			}
-			shiftSpan := timespan.New(startTime, endTime)
-			user := timespan.User{
-				Name:     se.User.Summary,
-				Location: startTime.Location(),
+				return Schedules{}, terr
 			}
 			us[user] = append(us[user], shiftSpan)
 		}
-		schdUserShifts[timespan.ScheduleName(ds.Name)] = us
+		schedules.UserShifts[timespan.ScheduleName(ds.Name)] = us
+		schedules.IDs[timespan.ScheduleName(ds.Name)] = PdSchedule
+
+		level, lerr := policies.escalationLevel(ds)
+		if lerr != nil {
+			return Schedules{}, lerr
+		}
+		schedules.Levels[timespan.ScheduleName(ds.Name)] = level
+		for _, ep := range ds.EscalationPolicies {
+			schedules.Policies[timespan.ScheduleName(ds.Name)] = append(schedules.Policies[timespan.ScheduleName(ds.Name)], ep.ID)
+		}
+
+		overrides, oerr := users.overrides(ds)
+		if oerr != nil {
+			return Schedules{}, oerr
+		}
+		schedules.Overrides[timespan.ScheduleName(ds.Name)] = overrides
 	}
 
-	return schdUserShifts, nil
+	return schedules, nil
+}
+
+// scheduleEntry returns the span and user of a rendered schedule entry
+func (uc *userCache) scheduleEntry(se pagerduty.RenderedScheduleEntry) (timespan.Span, timespan.User, error) {
+	startTime, err := time.Parse(pdTimeFormat, se.Start)
+	if err != nil {
+		return timespan.Span{}, timespan.User{}, err
+	}
+	endTime, err := time.Parse(pdTimeFormat, se.End)
+	if err != nil {
+		return timespan.Span{}, timespan.User{}, err
+	}
+	return timespan.New(startTime, endTime), uc.user(se.User), nil
 }
@@ -0,0 +1,38 @@
+package pd
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+// contextCheckingClient fails every request whose context is done, like the net/http client
+type contextCheckingClient struct {
+	fakePagerDuty
+}
+
+func (c contextCheckingClient) Do(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	return c.fakePagerDuty.Do(req)
+}
+
+func TestWithContext(t *testing.T) {
+	client := newFakeClient(discoveryFixture)
+	client.HTTPClient = contextCheckingClient{discoveryFixture}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	withCtx := WithContext(ctx, client)
+	if _, err := findTeams(withCtx, []string{"Platform"}); err != nil {
+		t.Fatalf("Unexpected error before cancelling: %s", err.Error())
+	}
+	cancel()
+	if _, err := findTeams(withCtx, []string{"Platform"}); err == nil {
+		t.Errorf("Expected an error reading teams after cancelling")
+	}
+	// The original client isn't affected
+	if _, err := findTeams(client, []string{"Platform"}); err != nil {
+		t.Errorf("Unexpected error from the client without the context: %s", err.Error())
+	}
+}
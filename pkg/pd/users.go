@@ -0,0 +1,89 @@
+package pd
+
+import (
+	"strings"
+	"time"
+
+	"github.com/PagerDuty/go-pagerduty"
+	"github.com/leosunmo/pagertally/pkg/timespan"
+	log "github.com/sirupsen/logrus"
+)
+
+// Timezones configures the timezone each user's on-call time is attributed in.
+// Users without a timezone get a nil Location, meaning the timezone of the schedule
+type Timezones struct {
+	// Force ignores users' own PagerDuty timezones
+	Force bool
+	// Users maps lower case PagerDuty user IDs or emails to timezones, overriding their PagerDuty timezone
+	Users map[string]*time.Location
+}
+
+// userCache looks up PagerDuty users by ID, fetching every user only once
+type userCache struct {
+	client    *pagerduty.Client
+	timezones Timezones
+	users     map[string]timespan.User
+	// locations are the timezones loaded so far by name, so users in the same timezone share a Location
+	locations map[string]*time.Location
+}
+
+func newUserCache(client *pagerduty.Client, timezones Timezones) *userCache {
+	return &userCache{
+		client:    client,
+		timezones: timezones,
+		users:     map[string]timespan.User{},
+		locations: map[string]*time.Location{},
+	}
+}
+
+// user returns the user referenced by ref. Users that can't be fetched,
+// e.g. because they've been deleted, are identified by their ID and summary only
+func (uc *userCache) user(ref pagerduty.APIObject) timespan.User {
+	if user, exists := uc.users[ref.ID]; exists {
+		return user
+	}
+	user := timespan.User{
+		ID:   ref.ID,
+		Name: ref.Summary,
+	}
+	var timezone string
+	if ref.ID != "" {
+		pdUser, err := uc.client.GetUser(ref.ID, pagerduty.GetUserOptions{})
+		if err != nil {
+			log.Warnf("Failed to fetch PagerDuty user %s (%s), err: %s", ref.ID, ref.Summary, err.Error())
+		} else {
+			user.Name = pdUser.Name
+			user.Email = pdUser.Email
+			timezone = pdUser.Timezone
+		}
+	}
+	user.Location = uc.location(user, timezone)
+	if user.Location != nil {
+		log.Debugf("Attributing %s's time in %s", user.Name, user.Location)
+	}
+	uc.users[ref.ID] = user
+	return user
+}
+
+// location returns the timezone to attribute user's time in, given the timezone from their PagerDuty profile
+func (uc *userCache) location(user timespan.User, timezone string) *time.Location {
+	if loc, exists := uc.timezones.Users[strings.ToLower(user.ID)]; exists {
+		return loc
+	}
+	if loc, exists := uc.timezones.Users[strings.ToLower(user.Email)]; exists && user.Email != "" {
+		return loc
+	}
+	if uc.timezones.Force || timezone == "" {
+		return nil
+	}
+	if loc, exists := uc.locations[timezone]; exists {
+		return loc
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		log.Warnf("Failed to load %s's timezone %q, using the schedule's timezone, err: %s", user.Name, timezone, err.Error())
+		loc = nil
+	}
+	uc.locations[timezone] = loc
+	return loc
+}
@@ -0,0 +1,146 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/datasources"
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+// Decisions on the datasources of an Explanation
+const (
+	// Decided is the datasource that decided the attribute
+	Decided = "decided"
+	// Stacked is a datasource whose attribute was stacked on the decided one
+	Stacked = "stacked"
+	// Skipped is a datasource that didn't apply or was beaten by another
+	Skipped = "skipped"
+)
+
+// BusinessHoursSource is the name of the business hours fallback in an Explanation
+const BusinessHoursSource = "business_hours"
+
+// Explanation is how a single instant of a user's shift on a schedule was attributed
+type Explanation struct {
+	User     timespan.User
+	Schedule timespan.ScheduleName
+	At       time.Time
+	// Shift is the user's shift covering At
+	Shift timespan.Span
+	// Location is the timezone the shift was attributed in
+	Location *time.Location
+	Stacking bool
+	// Sources are all datasources in order of precedence followed by business hours,
+	// and whether they decided the attribute
+	Sources []SourceExplanation
+	// Attributed is what the instant was attributed to
+	Attributed timespan.AttributedSpan
+	// Attributes name the attributes of the explanation
+	Attributes *timespan.Attributes
+}
+
+// SourceExplanation is whether a single datasource applied to the instant of an Explanation, and why it did or didn't count
+type SourceExplanation struct {
+	Name      string
+	Attribute timespan.OnCallAttribute
+	// Span is the datasource's span covering the instant, the zero Span if it doesn't apply
+	Span timespan.Span
+	// Description is where the span comes from, like the calendar event or business hours rule, if known
+	Description string
+	// Decision is one of Decided, Stacked or Skipped
+	Decision string
+	Reason   string
+}
+
+// Explain traces how the instant at was attributed if it's during one of the user's shifts on the schedule, using the
+// schedule's datasources ds. Datasources are matched the same way shifts are attributed: the first datasource in order of
+// precedence with a span covering the instant decides, and when stacking the first datasource of each other attribute
+// stacks its attribute on it. It returns false if none of the shifts cover the instant
+func Explain(ctx context.Context, user timespan.User, schedule timespan.ScheduleName, shifts []timespan.Span, at time.Time, ds datasources.ScheduleDataSources) (Explanation, bool, error) {
+	shift, covered := datasources.SpanAt(shifts, at)
+	if !covered {
+		return Explanation{}, false, nil
+	}
+	loc := ds.TimezoneFor(user.Location)
+	explanation := Explanation{
+		User:       user,
+		Schedule:   schedule,
+		At:         at,
+		Shift:      shift,
+		Location:   loc,
+		Stacking:   ds.Stacking,
+		Attributes: ds.Attributes,
+	}
+	attributed, err := attributeShift(ctx, []timespan.Span{timespan.New(at, at.Add(time.Second))}, ds.Sources, ds.Period, loc, ds.Stacking)
+	if err != nil {
+		return Explanation{}, false, err
+	}
+	explanation.Attributed = attributed[0]
+
+	name := ds.Attributes.Name
+	var decider *datasources.AttributeDataSource
+	stackedBy := map[timespan.OnCallAttribute]string{}
+	for i, source := range ds.Sources {
+		sourceSpans, err := source.Spans(ctx, ds.Period, loc)
+		if err != nil {
+			return Explanation{}, false, fmt.Errorf("datasource %s: %s", source.Name, err.Error())
+		}
+		explained := SourceExplanation{Name: source.Name, Attribute: source.Attribute}
+		span, applies := datasources.SpanAt(sourceSpans, at)
+		if applies {
+			explained.Span = span
+			explained.Description, err = describe(ctx, source, ds.Period, at, loc)
+			if err != nil {
+				return Explanation{}, false, fmt.Errorf("datasource %s: %s", source.Name, err.Error())
+			}
+		}
+		switch {
+		case !applies:
+			explained.Decision = Skipped
+			explained.Reason = fmt.Sprintf("no %s at this time", name(source.Attribute))
+		case decider == nil:
+			decider = &ds.Sources[i]
+			explained.Decision = Decided
+			explained.Reason = "first datasource in order of precedence that applies"
+		case ds.Stacking && source.Attribute == decider.Attribute:
+			explained.Decision = Skipped
+			explained.Reason = fmt.Sprintf("%s was already decided by %s", name(source.Attribute), decider.Name)
+		case ds.Stacking && stackedBy[source.Attribute] != "":
+			explained.Decision = Skipped
+			explained.Reason = fmt.Sprintf("%s was already stacked by %s", name(source.Attribute), stackedBy[source.Attribute])
+		case ds.Stacking:
+			stackedBy[source.Attribute] = source.Name
+			explained.Decision = Stacked
+			explained.Reason = fmt.Sprintf("stacked on %s decided by %s", name(decider.Attribute), decider.Name)
+		default:
+			explained.Decision = Skipped
+			explained.Reason = fmt.Sprintf("%s (%s) takes precedence", decider.Name, name(decider.Attribute))
+		}
+		explanation.Sources = append(explanation.Sources, explained)
+	}
+
+	business := SourceExplanation{Name: BusinessHoursSource, Attribute: timespan.Business}
+	if decider == nil {
+		business.Span = shift
+		business.Decision = Decided
+		business.Reason = "no other datasource applies"
+	} else {
+		business.Decision = Skipped
+		business.Reason = "only applies when no other datasource does"
+	}
+	explanation.Sources = append(explanation.Sources, business)
+	return explanation, true, nil
+}
+
+// describe returns where the span of source at the time at comes from, if it can tell
+func describe(ctx context.Context, source datasources.AttributeDataSource, period timespan.Span, at time.Time, loc *time.Location) (string, error) {
+	switch ds := source.DataSource.(type) {
+	case datasources.DescribedDataSource:
+		return ds.Describe(ctx, period, at, loc)
+	case datasources.NamedDataSource:
+		return ds.SpanName(timespan.New(at, at.Add(time.Second))), nil
+	}
+	return "", nil
+}
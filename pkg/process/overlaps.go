@@ -0,0 +1,202 @@
+package process
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+// Overlap modes decide how time a user is on call for more than one schedule at once is counted
+const (
+	// OverlapSum counts the time on every schedule, the default
+	OverlapSum = "sum"
+	// OverlapUnion counts the time once, on the schedule with the lowest escalation level, then by name
+	OverlapUnion = "union"
+	// OverlapHighest counts the time once, on the schedule paying the most for it
+	OverlapHighest = "highest"
+)
+
+// RateFunc returns the hourly amount paid for time with the attribute attr on the schedule of result
+type RateFunc func(result timespan.UserShiftResults, attr timespan.OnCallAttribute) float64
+
+// resultIndex is the position of a single user's results in the results of all schedules
+type resultIndex struct {
+	schedule string
+	i        int
+}
+
+// ResolveOverlaps finds all time users were on call for more than one schedule at once. Unless mode is
+// OverlapSum, the time is removed from the shifts and breakdowns of all but one of the schedules so it's
+// only counted once. The overlapping time is split where any of the schedules' shifts or attributes change,
+// and each piece is kept by a single schedule out of all the ones covering it. So with OverlapHighest
+// an overlap can be counted on one schedule during business hours and another after hours
+func ResolveOverlaps(results map[string][]timespan.UserShiftResults, mode string, rate RateFunc) (map[string][]timespan.UserShiftResults, []timespan.Overlap, error) {
+	switch mode {
+	case "", OverlapSum, OverlapUnion, OverlapHighest:
+	default:
+		return nil, nil, fmt.Errorf("unknown overlap mode %q, use %s, %s or %s", mode, OverlapSum, OverlapUnion, OverlapHighest)
+	}
+	resolve := mode == OverlapUnion || mode == OverlapHighest
+	if mode != OverlapHighest {
+		rate = func(timespan.UserShiftResults, timespan.OnCallAttribute) float64 { return 0 }
+	}
+
+	userResults := map[string][]resultIndex{}
+	for schedule, scheduleResults := range results {
+		for i, result := range scheduleResults {
+			userResults[result.User.ID] = append(userResults[result.User.ID], resultIndex{schedule, i})
+		}
+	}
+
+	lost := map[resultIndex][]timespan.Span{}
+	overlaps := []timespan.Overlap{}
+	for _, indexes := range userResults {
+		// Earlier results win ties
+		sort.Slice(indexes, func(i, j int) bool {
+			return resultBefore(results[indexes[i].schedule][indexes[i].i], results[indexes[j].schedule][indexes[j].i])
+		})
+		overlaps = append(overlaps, userOverlaps(results, indexes, resolve, rate, lost)...)
+	}
+
+	output := map[string][]timespan.UserShiftResults{}
+	for schedule, scheduleResults := range results {
+		output[schedule] = append([]timespan.UserShiftResults{}, scheduleResults...)
+		for i := range output[schedule] {
+			spans, exists := lost[resultIndex{schedule, i}]
+			if !exists {
+				continue
+			}
+			output[schedule][i].Shifts = timespan.Subtract(output[schedule][i].Shifts, spans)
+			output[schedule][i].Breakdown = output[schedule][i].Breakdown.Without(spans)
+		}
+	}
+	return output, mergeOverlaps(overlaps), nil
+}
+
+// userOverlaps returns the overlaps between a single user's results at indexes, in order of precedence. The user's
+// time is split in to segments at the start and end of every attributed span, so each segment has the same
+// schedules and attributes throughout. If resolve is set, the schedule paying the most for a segment keeps it,
+// or the first of them if they pay the same, and the others' lost time is added to lost
+func userOverlaps(results map[string][]timespan.UserShiftResults, indexes []resultIndex, resolve bool, rate RateFunc, lost map[resultIndex][]timespan.Span) []timespan.Overlap {
+	boundaries := []time.Time{}
+	for _, idx := range indexes {
+		for _, span := range results[idx.schedule][idx.i].Breakdown {
+			boundaries = append(boundaries, span.Start(), span.End())
+		}
+	}
+	sort.Slice(boundaries, func(i, j int) bool { return boundaries[i].Before(boundaries[j]) })
+
+	overlaps := []timespan.Overlap{}
+	for b := 1; b < len(boundaries); b++ {
+		segment := timespan.New(boundaries[b-1], boundaries[b])
+		if segment.Duration() <= 0 {
+			continue
+		}
+		covering := []resultIndex{}
+		winner := 0
+		var highest float64
+		for _, idx := range indexes {
+			result := results[idx.schedule][idx.i]
+			span, found := spanDuring(result.Breakdown, segment)
+			if !found {
+				continue
+			}
+			if segmentRate := spanRate(rate, result, span); len(covering) == 0 || segmentRate > highest {
+				winner = len(covering)
+				highest = segmentRate
+			}
+			covering = append(covering, idx)
+		}
+		if len(covering) < 2 {
+			continue
+		}
+		overlap := timespan.Overlap{Span: segment, User: results[covering[0].schedule][covering[0].i].User}
+		for i, idx := range covering {
+			overlap.Schedules = append(overlap.Schedules, results[idx.schedule][idx.i].Schedule)
+			if resolve && i != winner {
+				lost[idx] = append(lost[idx], segment)
+			}
+		}
+		if resolve {
+			overlap.CountedOn = overlap.Schedules[winner]
+		}
+		overlaps = append(overlaps, overlap)
+	}
+	return overlaps
+}
+
+// spanDuring returns the attributed span of spans during segment, which is never split by any of their starts or ends
+func spanDuring(spans timespan.AttributedSpans, segment timespan.Span) (timespan.AttributedSpan, bool) {
+	for _, span := range spans {
+		if span.Span.Overlaps(segment) {
+			return span, true
+		}
+	}
+	return timespan.AttributedSpan{}, false
+}
+
+// spanRate returns the hourly amount paid for span on the schedule of result, the highest of its attributes if they're stacked
+func spanRate(rate RateFunc, result timespan.UserShiftResults, span timespan.AttributedSpan) float64 {
+	highest := rate(result, span.SpanType)
+	for _, attr := range span.Stacked {
+		if stackedRate := rate(result, attr); stackedRate > highest {
+			highest = stackedRate
+		}
+	}
+	return highest
+}
+
+// resultBefore returns true if a's schedule comes before b's, by escalation level and then name.
+// Schedules with unknown escalation levels come last
+func resultBefore(a, b timespan.UserShiftResults) bool {
+	levelA, levelB := a.EscalationLevel, b.EscalationLevel
+	if levelA != levelB && (levelA == 0 || levelB == 0) {
+		return levelB == 0
+	}
+	if levelA != levelB {
+		return levelA < levelB
+	}
+	return a.Schedule < b.Schedule
+}
+
+// mergeOverlaps merges back to back overlaps of the same user on the same schedules
+// that were counted the same way, and sorts them by start time
+func mergeOverlaps(overlaps []timespan.Overlap) []timespan.Overlap {
+	sort.Slice(overlaps, func(i, j int) bool {
+		if !overlaps[i].Start().Equal(overlaps[j].Start()) {
+			return overlaps[i].Start().Before(overlaps[j].Start())
+		}
+		if overlaps[i].User.ID != overlaps[j].User.ID {
+			return overlaps[i].User.ID < overlaps[j].User.ID
+		}
+		return schedulesKey(overlaps[i].Schedules) < schedulesKey(overlaps[j].Schedules)
+	})
+	merged := []timespan.Overlap{}
+	for _, overlap := range overlaps {
+		joined := false
+		for i := range merged {
+			prev := merged[i]
+			if prev.User.ID == overlap.User.ID && schedulesKey(prev.Schedules) == schedulesKey(overlap.Schedules) && prev.CountedOn == overlap.CountedOn && prev.End().Equal(overlap.Start()) {
+				merged[i].Span = timespan.New(prev.Start(), overlap.End())
+				joined = true
+				break
+			}
+		}
+		if !joined {
+			merged = append(merged, overlap)
+		}
+	}
+	return merged
+}
+
+// schedulesKey joins the names of schedules to compare and sort them
+func schedulesKey(schedules []timespan.ScheduleName) string {
+	names := []string{}
+	for _, schedule := range schedules {
+		names = append(names, string(schedule))
+	}
+	return strings.Join(names, "\x00")
+}
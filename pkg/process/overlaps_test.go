@@ -0,0 +1,152 @@
+package process
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leosunmo/pagertally/pkg/timespan"
+)
+
+func TestResolveOverlaps(t *testing.T) {
+	user := timespan.User{ID: "PABC123", Name: "User1"}
+	start := time.Date(2019, time.January, 7, 0, 0, 0, 0, time.UTC)
+	platform := timespan.New(start, start.Add(24*time.Hour))
+	database := timespan.New(start.Add(16*time.Hour), start.Add(40*time.Hour))
+	results := map[string][]timespan.UserShiftResults{
+		"Platform": {{
+			User:      user,
+			Schedule:  "Platform",
+			Shifts:    []timespan.Span{platform},
+			Breakdown: timespan.AttributedSpans{{Span: platform, SpanType: timespan.Business}},
+		}},
+		"Database": {{
+			User:      user,
+			Schedule:  "Database",
+			Shifts:    []timespan.Span{database},
+			Breakdown: timespan.AttributedSpans{{Span: database, SpanType: timespan.Business}},
+		}},
+	}
+	rates := map[timespan.ScheduleName]float64{"Platform": 10, "Database": 5}
+	rate := func(result timespan.UserShiftResults, attr timespan.OnCallAttribute) float64 {
+		return rates[result.Schedule]
+	}
+	tests := []struct {
+		mode      string
+		platform  time.Duration
+		database  time.Duration
+		countedOn timespan.ScheduleName
+	}{
+		{OverlapSum, 24 * time.Hour, 24 * time.Hour, ""},
+		// Database comes first by name
+		{OverlapUnion, 16 * time.Hour, 24 * time.Hour, "Database"},
+		{OverlapHighest, 24 * time.Hour, 16 * time.Hour, "Platform"},
+	}
+	for _, test := range tests {
+		resolved, overlaps, err := ResolveOverlaps(results, test.mode, rate)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %s", test.mode, err.Error())
+		}
+		if len(overlaps) != 1 || overlaps[0].Duration() != 8*time.Hour || overlaps[0].CountedOn != test.countedOn {
+			t.Errorf("%s: expected a single 8h overlap counted on %q, got %+v", test.mode, test.countedOn, overlaps)
+		}
+		if dur := resolved["Platform"][0].Breakdown.TotalDur(); dur != test.platform {
+			t.Errorf("%s: expected %s on Platform, got %s", test.mode, test.platform, dur)
+		}
+		if dur := resolved["Database"][0].Breakdown.TotalDur(); dur != test.database {
+			t.Errorf("%s: expected %s on Database, got %s", test.mode, test.database, dur)
+		}
+	}
+	if results["Platform"][0].Breakdown.TotalDur() != 24*time.Hour {
+		t.Errorf("Expected the original results to be untouched")
+	}
+	if _, _, err := ResolveOverlaps(results, "max", rate); err == nil {
+		t.Errorf("Expected an error for an unknown overlap mode")
+	}
+}
+
+func TestResolveOverlapsOfThreeSchedules(t *testing.T) {
+	user := timespan.User{ID: "PABC123", Name: "User1"}
+	start := time.Date(2019, time.January, 7, 0, 0, 0, 0, time.UTC)
+	shifts := map[string]timespan.Span{
+		"Platform": timespan.New(start, start.Add(24*time.Hour)),
+		"Database": timespan.New(start.Add(16*time.Hour), start.Add(40*time.Hour)),
+		"Security": timespan.New(start.Add(12*time.Hour), start.Add(20*time.Hour)),
+	}
+	results := map[string][]timespan.UserShiftResults{}
+	for schedule, shift := range shifts {
+		results[schedule] = []timespan.UserShiftResults{{
+			User:      user,
+			Schedule:  timespan.ScheduleName(schedule),
+			Shifts:    []timespan.Span{shift},
+			Breakdown: timespan.AttributedSpans{{Span: shift, SpanType: timespan.Business}},
+		}}
+	}
+	rates := map[timespan.ScheduleName]float64{"Platform": 10, "Database": 5, "Security": 20}
+	rate := func(result timespan.UserShiftResults, attr timespan.OnCallAttribute) float64 {
+		return rates[result.Schedule]
+	}
+
+	type expectedOverlap struct {
+		from, to  time.Duration
+		schedules string
+		countedOn timespan.ScheduleName
+	}
+	tests := []struct {
+		mode      string
+		overlaps  []expectedOverlap
+		durations map[string]time.Duration
+	}{
+		{
+			// Database comes first by name, then Platform
+			mode: OverlapUnion,
+			overlaps: []expectedOverlap{
+				{12 * time.Hour, 16 * time.Hour, "Platform Security", "Platform"},
+				{16 * time.Hour, 20 * time.Hour, "Database Platform Security", "Database"},
+				{20 * time.Hour, 24 * time.Hour, "Database Platform", "Database"},
+			},
+			durations: map[string]time.Duration{"Platform": 16 * time.Hour, "Database": 24 * time.Hour, "Security": 0},
+		},
+		{
+			// Security pays the most whenever it's on call, even though Platform pays more than Database
+			mode: OverlapHighest,
+			overlaps: []expectedOverlap{
+				{12 * time.Hour, 16 * time.Hour, "Platform Security", "Security"},
+				{16 * time.Hour, 20 * time.Hour, "Database Platform Security", "Security"},
+				{20 * time.Hour, 24 * time.Hour, "Database Platform", "Platform"},
+			},
+			durations: map[string]time.Duration{"Platform": 16 * time.Hour, "Database": 16 * time.Hour, "Security": 8 * time.Hour},
+		},
+	}
+	for _, test := range tests {
+		resolved, overlaps, err := ResolveOverlaps(results, test.mode, rate)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %s", test.mode, err.Error())
+		}
+		if len(overlaps) != len(test.overlaps) {
+			t.Fatalf("%s: expected %d overlaps, got %+v", test.mode, len(test.overlaps), overlaps)
+		}
+		for i, overlap := range overlaps {
+			expected := test.overlaps[i]
+			schedules := []string{}
+			for _, schedule := range overlap.Schedules {
+				schedules = append(schedules, string(schedule))
+			}
+			if !overlap.Start().Equal(start.Add(expected.from)) || !overlap.End().Equal(start.Add(expected.to)) ||
+				strings.Join(schedules, " ") != expected.schedules || overlap.CountedOn != expected.countedOn {
+				t.Errorf("%s: expected %s to %s on %s counted on %s, got %+v", test.mode, expected.from, expected.to, expected.schedules, expected.countedOn, overlap)
+			}
+		}
+		// The 40 hours from the start of Platform until the end of Database are counted exactly once
+		var total time.Duration
+		for schedule, dur := range test.durations {
+			if resolvedDur := resolved[schedule][0].Breakdown.TotalDur(); resolvedDur != dur {
+				t.Errorf("%s: expected %s on %s, got %s", test.mode, dur, schedule, resolvedDur)
+			}
+			total += resolved[schedule][0].Breakdown.TotalDur()
+		}
+		if total != 40*time.Hour {
+			t.Errorf("%s: expected 40h counted across all schedules, got %s", test.mode, total)
+		}
+	}
+}
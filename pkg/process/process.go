@@ -1,6 +1,7 @@
 package process
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"time"
@@ -46,79 +47,201 @@ func genIntersectorFromDatasourceSpans(attr timespan.OnCallAttribute, matchSpans
 	}
 }
 
+// genIntersectorFromDatasource returns an Intersector for the spans of ds during period in the timezone loc with the
+// provided on call attribute, naming the attributed spans after the spans of ds if it's a NamedDataSource
+func genIntersectorFromDatasource(ctx context.Context, attr timespan.OnCallAttribute, ds datasources.DataSource, period timespan.Span, loc *time.Location) (Intersector, error) {
+	spans, err := ds.Spans(ctx, period, loc)
+	if err != nil {
+		return nil, err
+	}
+	intersector := genIntersectorFromDatasourceSpans(attr, spans)
+	named, isNamed := ds.(datasources.NamedDataSource)
+	if !isNamed {
+		return intersector, nil
+	}
+	return func(testSpans []timespan.Span) []timespan.AttributedSpan {
+		out := intersector(testSpans)
+		for i := range out {
+			out[i].Name = named.SpanName(out[i].Span)
+		}
+		return out
+	}, nil
+}
+
 // ScheduleUserShifts processes all user shifts for all Pagerduty schedules and
-// returns a slice of attributed user shifts with the user and PD schedule as values of that struct
-func ScheduleUserShifts(schedUserShifts timespan.ScheduleUserShifts, companyDayDatasource, calendarDatasource, weekendDatasource, afterHoursDatasource datasources.DataSource) map[string][]timespan.UserShiftResults {
+// returns a slice of attributed user shifts with the user and PD schedule as values of that struct.
+// Incidents are attributed to the user on call for the schedule at the time of the callout if they responded to it,
+// overrides to the user that took them over. Every schedule is attributed using its own datasources,
+// with weekends and after hours in each user's own timezone. Results are tagged with the escalation level of their schedule.
+// Datasources stop once ctx is done
+func ScheduleUserShifts(ctx context.Context, schedUserShifts timespan.ScheduleUserShifts, schedIncidents timespan.ScheduleIncidents, schedOverrides timespan.ScheduleOverrides, schedIDs timespan.ScheduleIDs, schedLevels timespan.EscalationLevels, scheduleDatasources func(scheduleID string) (datasources.ScheduleDataSources, error)) (map[string][]timespan.UserShiftResults, error) {
 	output := map[string][]timespan.UserShiftResults{}
 	for schedule, userShifts := range schedUserShifts {
 		userResults := []timespan.UserShiftResults{}
-		// DEBUG
-		var totalDurs time.Duration
-		// DEBUG
+		ds, err := scheduleDatasources(schedIDs[schedule])
+		if err != nil {
+			return nil, fmt.Errorf("schedule %s: %s", schedule, err.Error())
+		}
+		userIncidents, err := attributeIncidents(ctx, userShifts, schedIncidents[schedule], ds)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %s: %s", schedule, err.Error())
+		}
 		for user, shifts := range userShifts {
-			attrShifts := attributeShift(shifts, companyDayDatasource, calendarDatasource, weekendDatasource, afterHoursDatasource)
+			loc := ds.TimezoneFor(user.Location)
+			attrShifts, err := attributeShift(ctx, shifts, ds.Sources, ds.Period, loc, ds.Stacking)
+			if err != nil {
+				return nil, fmt.Errorf("schedule %s: %s", schedule, err.Error())
+			}
 			singleResult := timespan.UserShiftResults{
-				Schedule:  schedule,
-				User:      user,
-				Shifts:    shifts,
-				Breakdown: attrShifts,
+				Schedule:        schedule,
+				ScheduleID:      schedIDs[schedule],
+				EscalationLevel: schedLevels[schedule],
+				Location:        loc,
+				User:            user,
+				Shifts:          shifts,
+				Breakdown:       attrShifts,
+				Incidents:       userIncidents[user],
+				Overrides:       userOverrides(user, schedOverrides[schedule]),
 			}
-			// DEBUG
-			if log.GetLevel() == log.TraceLevel {
-				fmt.Printf("%s's shifts:\n", user.Name)
-				var shiftsDuration time.Duration
-				for i, shift := range shifts {
-					shiftsDuration = shiftsDuration + shift.End().Sub(shift.Start())
-					fmt.Printf("\tShift %d:\n\t%s  -  %s\n\tDuration: %s\n\n", i, shift.Start(), shift.End(), shift.End().Sub(shift.Start()))
-				}
-				fmt.Printf("\nTotal shift duration: %s\n", shiftsDuration)
-				fmt.Printf("\n%s's breakdown:\n", user.Name)
+			userResults = append(userResults, singleResult)
+		}
+		output[string(schedule)] = userResults
+	}
+	return output, nil
+}
 
-				var attrShiftsDuration time.Duration
-				for i, shift := range attrShifts {
-					attrShiftsDuration = attrShiftsDuration + shift.End().Sub(shift.Start())
-					fmt.Printf("\tAttribShift %d Type: %d:\n\t%s  -  %s\n\tDuration: %s\n\n", i, shift.SpanType, shift.Start(), shift.End(), shift.End().Sub(shift.Start()))
+// attributeIncidents finds the user on call at the time of each incident's callout that responded to it and
+// attributes both the callout and the time spent engaged until it was resolved. Users on call for a schedule
+// that was never notified, like a secondary when the primary acknowledged, aren't attributed the incident
+func attributeIncidents(ctx context.Context, userShifts timespan.UserShifts, incidents []timespan.Incident, ds datasources.ScheduleDataSources) (map[timespan.User][]timespan.AttributedIncident, error) {
+	output := map[timespan.User][]timespan.AttributedIncident{}
+	for _, incident := range incidents {
+		calloutTime := incident.CalloutTime()
+		found := false
+		for user, shifts := range userShifts {
+			if !incident.RespondedBy(user) {
+				continue
+			}
+			for _, shift := range shifts {
+				// Shifts are back to back, so a callout right on the handover belongs to the next shift
+				if calloutTime.Before(shift.Start()) || !calloutTime.Before(shift.End()) {
+					continue
+				}
+				// Open incidents count as engaged until the end of the shift
+				engaged := incident.EngagedSpan(shift.End())
+				loc := ds.TimezoneFor(user.Location)
+				callout, err := attributeShift(ctx, []timespan.Span{timespan.New(calloutTime, calloutTime.Add(time.Minute))}, ds.Sources, ds.Period, loc, ds.Stacking)
+				if err != nil {
+					return nil, err
 				}
-				fmt.Printf("Total attribShift duration: %s\n", attrShiftsDuration)
-				fmt.Println()
-				if shiftsDuration-attrShiftsDuration != 0 {
-					fmt.Println("===============================================================")
-					fmt.Printf("Shifts and attrShifts mismatch, %s vs %s\n", shiftsDuration, attrShiftsDuration)
-					fmt.Println("===============================================================")
+				attrIncident := timespan.AttributedIncident{
+					Incident:  incident,
+					Attribute: callout[0].SpanType,
 				}
-				totalDurs = totalDurs + attrShiftsDuration
+				if engaged.Duration() > 0 {
+					attrIncident.Engaged, err = attributeShift(ctx, []timespan.Span{engaged}, ds.Sources, ds.Period, loc, ds.Stacking)
+					if err != nil {
+						return nil, err
+					}
+				}
+				output[user] = append(output[user], attrIncident)
+				found = true
+				break
+			}
+			if found {
+				break
 			}
-			// END TRACE
-			userResults = append(userResults, singleResult)
 		}
-		// DEBUG
-		if log.GetLevel() == log.DebugLevel {
-			log.Debugf("Total time from schedule %s : %s", schedule, totalDurs)
+		if !found {
+			log.Debugf("Incident #%d at %s wasn't during the shift of anyone that responded, ignoring", incident.Number, calloutTime)
 		}
-		// END DEBUG
-		output[string(schedule)] = userResults
 	}
-	return output
+	return output, nil
 }
 
-// attributeShift returns timespans with added oncall attributes for the whole shift
-func attributeShift(spans []timespan.Span, companyDayDatasource, calendarDatasource, weekendDatasource, afterHoursDatasource datasources.DataSource) []timespan.AttributedSpan {
-
-	var deciders = []Intersector{
-		genIntersectorFromDatasourceSpans(timespan.CompanyDay, companyDayDatasource.Spans()),
-		genIntersectorFromDatasourceSpans(timespan.StatHoliday, calendarDatasource.Spans()),
-		genIntersectorFromDatasourceSpans(timespan.Weekend, weekendDatasource.Spans()),
-		genIntersectorFromDatasourceSpans(timespan.AfterHours, afterHoursDatasource.Spans()),
-		businessHoursIntersector,
+// attributeShift returns timespans with added oncall attributes for the whole shift. Each span gets the attribute
+// of the first of sources in order of precedence that it's in, or business hours if it's in none of them.
+// The sources are asked for their spans during the reporting period in the timezone loc.
+// When stacking, the spans are split further where the other sources apply as well, and those attributes are stacked on them
+func attributeShift(ctx context.Context, spans []timespan.Span, sources []datasources.AttributeDataSource, period timespan.Span, loc *time.Location, stacking bool) ([]timespan.AttributedSpan, error) {
+	var deciders []Intersector
+	for _, source := range sources {
+		decider, err := genIntersectorFromDatasource(ctx, source.Attribute, source.DataSource, period, loc)
+		if err != nil {
+			return nil, fmt.Errorf("datasource %s: %s", source.Name, err.Error())
+		}
+		deciders = append(deciders, decider)
 	}
+	deciders = append(deciders, businessHoursIntersector)
 	output := timespan.AttributedSpans{}
 	for _, decider := range deciders {
 		matches := decider(spans)
 		output = append(output, matches...)
 		spans = removeMatchedSpans(spans, matches)
 	}
+	if stacking {
+		var err error
+		output, err = stackAttributes(ctx, output, sources, period, loc)
+		if err != nil {
+			return nil, err
+		}
+	}
 	sort.Sort(output)
-	return output
+	return output, nil
+}
+
+// stackAttributes splits spans where the sources of other attributes apply to them too during period in the timezone loc,
+// and stacks those attributes on the pieces
+func stackAttributes(ctx context.Context, spans timespan.AttributedSpans, sources []datasources.AttributeDataSource, period timespan.Span, loc *time.Location) (timespan.AttributedSpans, error) {
+	stacked := timespan.AttributedSpans{}
+	for _, span := range spans {
+		pieces := timespan.AttributedSpans{span}
+		for _, source := range sources {
+			if source.Attribute == span.SpanType {
+				continue
+			}
+			sourceSpans, err := source.Spans(ctx, period, loc)
+			if err != nil {
+				return nil, fmt.Errorf("datasource %s: %s", source.Name, err.Error())
+			}
+			split := timespan.AttributedSpans{}
+			for _, piece := range pieces {
+				if stackedOn(piece, source.Attribute) {
+					// Another source of the attribute already applies
+					split = append(split, piece)
+					continue
+				}
+				for _, sourceSpan := range sourceSpans {
+					inside, overlap := piece.Span.Intersection(sourceSpan)
+					if !overlap {
+						continue
+					}
+					stackedPiece := piece
+					stackedPiece.Span = inside
+					stackedPiece.Stacked = append(append([]timespan.OnCallAttribute{}, piece.Stacked...), source.Attribute)
+					split = append(split, stackedPiece)
+				}
+				for _, outside := range timespan.Subtract([]timespan.Span{piece.Span}, sourceSpans) {
+					outsidePiece := piece
+					outsidePiece.Span = outside
+					split = append(split, outsidePiece)
+				}
+			}
+			pieces = split
+		}
+		stacked = append(stacked, pieces...)
+	}
+	return stacked, nil
+}
+
+// stackedOn returns true if attr is already stacked on span
+func stackedOn(span timespan.AttributedSpan, attr timespan.OnCallAttribute) bool {
+	for _, stacked := range span.Stacked {
+		if stacked == attr {
+			return true
+		}
+	}
+	return false
 }
 
 // removeMatchedSpans returns all spans that were not present in the matches slice.
@@ -238,3 +361,14 @@ func compareSpanToMatches(testSpan timespan.Span, matches []timespan.AttributedS
 	dedupLeftovers := timespan.Deduplicate(leftovers)
 	return dedupLeftovers
 }
+
+// userOverrides returns the overrides that put user on call
+func userOverrides(user timespan.User, overrides []timespan.Override) []timespan.Override {
+	out := []timespan.Override{}
+	for _, override := range overrides {
+		if override.User.ID == user.ID {
+			out = append(out, override)
+		}
+	}
+	return out
+}
@@ -1,6 +1,7 @@
 package process
 
 import (
+	"context"
 	"strings"
 	"testing"
 	"time"
@@ -23,7 +24,7 @@ const (
 var schedStart, _ = time.Parse(timeParseString, firstDec)
 var schedEnd, _ = time.Parse(timeParseString, firstJan)
 
-var testConfig = config.Config{
+var testConfig = config.ScheduleConfig{
 	Holidays:     []string{"Christmas Day", "Boxing Day"},
 	CompanyDays:  []string{"24/12/2018", "27/12/2018", "28/12/2018", "31/12/2018"},
 	CalendarURL:  "http://apps.employment.govt.nz/ical/public-holidays-all.ics",
@@ -37,6 +38,7 @@ var testConfig = config.Config{
 
 var userShifts = timespan.UserShifts{
 	timespan.User{
+		ID:       "PUSER1",
 		Name:     "User1",
 		Location: aklTz,
 	}: []timespan.Span{
@@ -46,6 +48,7 @@ var userShifts = timespan.UserShifts{
 		mustParseTimeSpan("2018-12-13 17:00:00 +1300 NZDT - 2018-12-14 17:00:00 +1300 NZDT"),
 	},
 	timespan.User{
+		ID:       "PUSER2",
 		Name:     "User2",
 		Location: aklTz,
 	}: []timespan.Span{
@@ -77,7 +80,7 @@ func TestShiftAttributionDurations(t *testing.T) {
 	config.GlobalConfig = testConfig
 	var totalDurs time.Duration
 	for user, shifts := range userShifts {
-		attrShifts := attributeShift(shifts, datasources.NewCompanyDayDataSource(), datasources.NewCalendarDataSource(), datasources.NewWeekendDataSource(), datasources.NewAfterHoursDataSource())
+		attrShifts := mustAttributeShift(t, shifts, datasources.BuiltInSources(mustCompanyDays(t, config.GlobalConfig), mustCalendar(t, config.GlobalConfig), datasources.NewWeekendDataSourceFor(config.GlobalConfig), datasources.NewAfterHoursDataSourceFor(config.GlobalConfig)), config.GlobalConfig.ScheduleSpan, config.Timezone(), false)
 		var shiftsDuration time.Duration
 		for i, shift := range shifts {
 			shiftsDuration = shiftsDuration + shift.End().Sub(shift.Start())
@@ -102,3 +105,251 @@ func TestShiftAttributionDurations(t *testing.T) {
 	}
 
 }
+
+func TestIncidentAttribution(t *testing.T) {
+	// Christmas and Boxing Day inline instead of from the iCal, so the test runs offline
+	config.GlobalConfig = testConfig
+	config.GlobalConfig.CalendarURL = ""
+	config.GlobalConfig.HolidayDates = []config.HolidayDate{
+		{Name: "Christmas Day", Date: "25/12/2018"},
+		{Name: "Boxing Day", Date: "26/12/2018"},
+	}
+	incidents := []timespan.Incident{
+		{
+			// Saturday evening during User1's shift, resolved after an hour and a half
+			Number:       1,
+			Created:      mustParseTime("2018-12-08 19:55:00 +1300 NZDT"),
+			Acknowledged: mustParseTime("2018-12-08 20:00:00 +1300 NZDT"),
+			Resolved:     mustParseTime("2018-12-08 21:30:00 +1300 NZDT"),
+			Responders:   []string{"PUSER1"},
+		},
+		{
+			// Tuesday evening during User2's shift, never acknowledged
+			Number:     2,
+			Created:    mustParseTime("2018-12-18 22:00:00 +1300 NZDT"),
+			Resolved:   mustParseTime("2018-12-18 22:30:00 +1300 NZDT"),
+			Responders: []string{"PUSER2"},
+		},
+		{
+			// Nobody on call
+			Number:     3,
+			Created:    mustParseTime("2018-12-10 12:00:00 +1300 NZDT"),
+			Responders: []string{"PUSER1", "PUSER2"},
+		},
+		{
+			// During User1's shift, but only someone on another schedule was paged
+			Number:       4,
+			Created:      mustParseTime("2018-12-13 23:00:00 +1300 NZDT"),
+			Acknowledged: mustParseTime("2018-12-13 23:05:00 +1300 NZDT"),
+			Resolved:     mustParseTime("2018-12-13 23:30:00 +1300 NZDT"),
+			Responders:   []string{"PPRIMARY"},
+		},
+	}
+	sources, err := datasources.NewScheduleDataSources(config.GlobalConfig)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	userIncidents, err := attributeIncidents(context.Background(), userShifts, incidents, sources)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	var total int
+	for user, attrIncidents := range userIncidents {
+		total += len(attrIncidents)
+		for _, incident := range attrIncidents {
+			switch incident.Number {
+			case 1:
+				if user.Name != "User1" || incident.Attribute != timespan.Weekend {
+					t.Errorf("Expected incident 1 to be a weekend callout for User1, got %s for %s", incident.Attribute, user.Name)
+				}
+				// User1's shift ends at 21:00 but they kept working until it was resolved
+				if incident.Engaged.TotalDur() != 90*time.Minute {
+					t.Errorf("Expected incident 1 to be engaged for 1h30m, got %s", incident.Engaged.TotalDur())
+				}
+			case 2:
+				if user.Name != "User2" || incident.Attribute != timespan.AfterHours {
+					t.Errorf("Expected incident 2 to be an after hours callout for User2, got %s for %s", incident.Attribute, user.Name)
+				}
+				if incident.Engaged.TotalDur() != 30*time.Minute {
+					t.Errorf("Expected incident 2 to be engaged for 30m, got %s", incident.Engaged.TotalDur())
+				}
+			default:
+				t.Errorf("Didn't expect incident %d to be attributed to %s", incident.Number, user.Name)
+			}
+		}
+	}
+	if total != 2 {
+		t.Errorf("Expected 2 attributed incidents, got %d", total)
+	}
+}
+
+// mustCalendar returns the calendar datasource of sc, failing the test if there's an error
+func mustCalendar(t *testing.T, sc config.ScheduleConfig) datasources.CalendarDataSource {
+	t.Helper()
+	cal, err := datasources.NewCalendarDataSourceFor(sc)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	return cal
+}
+
+// mustCompanyDays returns the company day datasource of sc, failing the test if there's an error
+func mustCompanyDays(t *testing.T, sc config.ScheduleConfig) datasources.CompanyDaysDataSource {
+	t.Helper()
+	vds, err := datasources.NewCompanyDayDataSourceFor(sc)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	return vds
+}
+
+func mustAttributeShift(t *testing.T, spans []timespan.Span, sources []datasources.AttributeDataSource, period timespan.Span, loc *time.Location, stacking bool) []timespan.AttributedSpan {
+	t.Helper()
+	attrShifts, err := attributeShift(context.Background(), spans, sources, period, loc, stacking)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	return attrShifts
+}
+
+func mustParseTime(rawTime string) time.Time {
+	t, err := time.Parse(timeParseString, rawTime)
+	if err != nil {
+		log.Fatal("Failed to parse timestamp ", rawTime)
+	}
+	return t
+}
+
+func TestTimeBandAttribution(t *testing.T) {
+	night, err := timespan.NewAttributes().Register("night")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	sc := config.ScheduleConfig{
+		Timezone:     "Pacific/Auckland",
+		ScheduleSpan: timespan.New(time.Date(2019, time.January, 1, 0, 0, 0, 0, aklTz), time.Date(2019, time.January, 8, 0, 0, 0, 0, aklTz)),
+		BusinessHours: config.BusinessHoursStruct{
+			Start: "08:00",
+			End:   "17:30",
+		},
+	}
+	band := config.TimeBand{
+		Name:      "night",
+		Weekly:    []config.WeeklyWindow{{Start: "22:00", End: "06:00"}},
+		Attribute: night,
+	}
+	nightDatasource := datasources.NewTimeBandDataSourceFor(band)
+	builtIns := datasources.BuiltInSources(
+		mustCompanyDays(t, sc),
+		mustCalendar(t, sc),
+		datasources.NewWeekendDataSourceFor(sc),
+		datasources.NewAfterHoursDataSourceFor(sc),
+	)
+	// Friday evening until Saturday morning, all weekend without the band
+	shifts := []timespan.Span{timespan.New(time.Date(2019, time.January, 4, 20, 0, 0, 0, aklTz), time.Date(2019, time.January, 5, 8, 0, 0, 0, aklTz))}
+
+	tests := []struct {
+		before  timespan.OnCallAttribute
+		night   time.Duration
+		weekend time.Duration
+	}{
+		{timespan.Unknown, 8 * time.Hour, 4 * time.Hour},
+		{timespan.Weekend, 8 * time.Hour, 4 * time.Hour},
+		{timespan.AfterHours, 0, 12 * time.Hour},
+	}
+	for _, test := range tests {
+		sources := datasources.Ordered(builtIns, []datasources.AttributeDataSource{{DataSource: nightDatasource, Attribute: night, Before: test.before}}, nil)
+		attrShifts := timespan.AttributedSpans(mustAttributeShift(t, shifts, sources, sc.ScheduleSpan, aklTz, false))
+		if attrShifts.Dur(night) != test.night || attrShifts.WeekendDur() != test.weekend {
+			t.Errorf("Before %s: expected %s night and %s weekend, got %s and %s", test.before, test.night, test.weekend, attrShifts.Dur(night), attrShifts.WeekendDur())
+		}
+	}
+}
+
+func TestAttributionPrecedence(t *testing.T) {
+	sc := config.ScheduleConfig{
+		Timezone:     "Pacific/Auckland",
+		ScheduleSpan: timespan.New(time.Date(2019, time.January, 1, 0, 0, 0, 0, aklTz), time.Date(2019, time.January, 8, 0, 0, 0, 0, aklTz)),
+		BusinessHours: config.BusinessHoursStruct{
+			Start: "08:00",
+			End:   "17:30",
+		},
+		HolidayDates: []config.HolidayDate{{Name: "Test Day", Date: "05/01/2019"}},
+	}
+	builtIns := datasources.BuiltInSources(
+		mustCompanyDays(t, sc),
+		mustCalendar(t, sc),
+		datasources.NewWeekendDataSourceFor(sc),
+		datasources.NewAfterHoursDataSourceFor(sc),
+	)
+	// A stat holiday on a Saturday
+	shifts := []timespan.Span{timespan.New(time.Date(2019, time.January, 5, 0, 0, 0, 0, aklTz), time.Date(2019, time.January, 6, 0, 0, 0, 0, aklTz))}
+
+	attrShifts := timespan.AttributedSpans(mustAttributeShift(t, shifts, datasources.Ordered(builtIns, nil, nil), sc.ScheduleSpan, aklTz, false))
+	if attrShifts.StatDur() != 24*time.Hour {
+		t.Errorf("Expected the holiday to be stat holiday by default, got %s", attrShifts.StatDur())
+	}
+
+	attrShifts = mustAttributeShift(t, shifts, datasources.Ordered(builtIns, nil, []timespan.OnCallAttribute{timespan.Weekend}), sc.ScheduleSpan, aklTz, false)
+	if attrShifts.WeekendDur() != 24*time.Hour {
+		t.Errorf("Expected the holiday to be weekend when weekends come first, got %s", attrShifts.WeekendDur())
+	}
+
+	attrShifts = mustAttributeShift(t, shifts, datasources.Ordered(builtIns, nil, nil), sc.ScheduleSpan, aklTz, true)
+	if attrShifts.StatDur() != 24*time.Hour {
+		t.Errorf("Expected the stacked holiday to be stat holiday first, got %s", attrShifts.StatDur())
+	}
+	for _, span := range attrShifts {
+		if len(span.Stacked) != 1 || span.Stacked[0] != timespan.Weekend {
+			t.Errorf("Expected weekend to be stacked on the holiday, got %v", span.Stacked)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	sc := config.ScheduleConfig{
+		Timezone:     "Pacific/Auckland",
+		ScheduleSpan: timespan.New(time.Date(2019, time.January, 1, 0, 0, 0, 0, aklTz), time.Date(2019, time.January, 8, 0, 0, 0, 0, aklTz)),
+		BusinessHours: config.BusinessHoursStruct{
+			Start: "08:00",
+			End:   "17:30",
+		},
+		HolidayDates: []config.HolidayDate{{Name: "Test Day", Date: "05/01/2019"}},
+	}
+	ds, err := datasources.NewScheduleDataSources(sc)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	user := timespan.User{ID: "PABC123", Name: "John Smith"}
+	shifts := []timespan.Span{timespan.New(time.Date(2019, time.January, 4, 9, 0, 0, 0, aklTz), time.Date(2019, time.January, 7, 9, 0, 0, 0, aklTz))}
+
+	if _, covered, err := Explain(context.Background(), user, "Primary", shifts, time.Date(2019, time.January, 7, 12, 0, 0, 0, aklTz), ds); err != nil || covered {
+		t.Errorf("Expected an instant after the shift not to be covered, got %t and %v", covered, err)
+	}
+
+	// Saturday on the holiday, after the Friday close of business
+	explanation, covered, err := Explain(context.Background(), user, "Primary", shifts, time.Date(2019, time.January, 5, 10, 0, 0, 0, aklTz), ds)
+	if err != nil || !covered {
+		t.Fatalf("Expected the instant to be covered, got %t and %v", covered, err)
+	}
+	if explanation.Attributed.SpanType != timespan.StatHoliday {
+		t.Errorf("Expected the instant to be attributed as stat holiday, got %s", explanation.Attributed.SpanType)
+	}
+	decisions := map[string]SourceExplanation{}
+	for _, source := range explanation.Sources {
+		decisions[source.Name] = source
+	}
+	if calendar := decisions["calendar"]; calendar.Decision != Decided || calendar.Description != `holiday date "Test Day"` {
+		t.Errorf("Expected the calendar to decide with the holiday date, got %+v", calendar)
+	}
+	if weekend := decisions["weekends"]; weekend.Decision != Skipped || weekend.Description != "closed on Saturday" {
+		t.Errorf("Expected the weekend to be skipped for the holiday, got %+v", weekend)
+	}
+	if afterHours := decisions["after_hours"]; afterHours.Decision != Skipped || !afterHours.Span.IsZero() {
+		t.Errorf("Expected after hours not to apply, got %+v", afterHours)
+	}
+	if business := decisions[BusinessHoursSource]; business.Decision != Skipped {
+		t.Errorf("Expected business hours to be skipped, got %+v", business)
+	}
+}
@@ -3,6 +3,9 @@
 package timespan
 
 import (
+	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	timerange "github.com/leosunmo/timerange-go"
@@ -10,6 +13,9 @@ import (
 
 const spanDateFormat = "20060102"
 
+// monthFormat is how calendar months are keyed
+const monthFormat = "2006-01"
+
 //Span represents an inclusive range between two time instants.
 //
 //The zero value of type span has both start and end times set to the zero value
@@ -21,6 +27,10 @@ type Span struct {
 type AttributedSpan struct {
 	Span
 	SpanType OnCallAttribute
+	// Name is the name of what the span was attributed to, like a named company day, if it has one
+	Name string
+	// Stacked are the other attributes that apply to the span when attributes are stacked
+	Stacked []OnCallAttribute
 }
 type AttributedSpans []AttributedSpan
 
@@ -32,17 +42,126 @@ type ScheduleName string
 // ScheduleUserShifts is a map of UserShifts by the schedule name
 type ScheduleUserShifts map[ScheduleName]UserShifts
 
-// User is a Pagerduty User
+// ScheduleIDs maps schedule names to their Pagerduty schedule IDs
+type ScheduleIDs map[ScheduleName]string
+
+// EscalationLevels maps schedule names to the escalation level they are on call for,
+// 1 being the primary on-call. 0 means the schedule isn't part of any escalation policy
+type EscalationLevels map[ScheduleName]int
+
+// User is a Pagerduty User, identified by ID
 type User struct {
+	ID       string
 	Name     string
+	Email    string
 	Location *time.Location
 }
 
 type UserShiftResults struct {
-	User      User
-	Schedule  ScheduleName
+	User       User
+	Schedule   ScheduleName
+	ScheduleID string
+	// EscalationLevel is the escalation level of the schedule, see EscalationLevels
+	EscalationLevel int
+	// Location is the timezone the shifts were attributed in, the user's or else the schedule's
+	Location  *time.Location
 	Shifts    []Span
 	Breakdown AttributedSpans
+	Incidents []AttributedIncident
+	// Overrides are the parts of Shifts the user took over from someone else using overrides
+	Overrides []Override
+}
+
+// Override is on-call time taken over from the regular rotation with a Pagerduty override
+type Override struct {
+	Span
+	// User is the user that was on call because of the override
+	User User
+	// CoveredFor is the user that would have been on call without the override,
+	// the zero User if nobody would have been
+	CoveredFor User
+}
+
+// ScheduleOverrides is a map of overrides by the schedule name
+type ScheduleOverrides map[ScheduleName][]Override
+
+// Overlap is time a user was on call for more than one schedule at once
+type Overlap struct {
+	Span
+	User User
+	// Schedules are all the schedules the user was on call for during the overlap
+	Schedules []ScheduleName
+	// CountedOn is the schedule the time was counted on, empty if it was counted on all of them
+	CountedOn ScheduleName
+}
+
+// Incident is a Pagerduty incident that paged whoever was on call
+type Incident struct {
+	ID           string
+	Number       uint
+	Title        string
+	Created      time.Time
+	Acknowledged time.Time // Acknowledged is the first acknowledgement, zero if never acknowledged
+	Resolved     time.Time // Resolved is the last resolution, zero if still open
+	// Responders are the IDs of the users that were notified of or acknowledged the incident
+	Responders []string
+}
+
+// ScheduleIncidents is a map of incidents by the name of the schedule that was paged
+type ScheduleIncidents map[ScheduleName][]Incident
+
+// AttributedIncident is an incident attributed to the user that was on call when it was acknowledged
+type AttributedIncident struct {
+	Incident
+	// Attribute is the on-call attribute at the time of the callout
+	Attribute OnCallAttribute
+	// Engaged is the time from the callout until the incident was resolved
+	Engaged AttributedSpans
+}
+
+// RespondedBy returns true if user was notified of or acknowledged the incident
+func (i Incident) RespondedBy(user User) bool {
+	for _, id := range i.Responders {
+		if id == user.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// CalloutTime returns when the incident was acknowledged, or created if it never was
+func (i Incident) CalloutTime() time.Time {
+	if i.Acknowledged.IsZero() {
+		return i.Created
+	}
+	return i.Acknowledged
+}
+
+// EngagedSpan returns the span from the callout until the incident was resolved,
+// or until openUntil if it's not resolved yet
+func (i Incident) EngagedSpan(openUntil time.Time) Span {
+	if i.Resolved.IsZero() {
+		return New(i.CalloutTime(), openUntil)
+	}
+	return New(i.CalloutTime(), i.Resolved)
+}
+
+// IncidentCount returns the number of incidents per on-call attribute at the time of the callout
+func (u UserShiftResults) IncidentCount() map[OnCallAttribute]int {
+	counts := map[OnCallAttribute]int{}
+	for _, incident := range u.Incidents {
+		counts[incident.Attribute]++
+	}
+	return counts
+}
+
+// EngagedDur returns the total time spent engaged in incidents
+func (u UserShiftResults) EngagedDur() time.Duration {
+	var durs time.Duration
+	for _, incident := range u.Incidents {
+		durs += incident.Engaged.TotalDur()
+	}
+	return durs
 }
 
 // UserShifts is a map of users to slice of their shifts
@@ -66,6 +185,127 @@ const (
 	CompanyDay // 5
 )
 
+// onCallAttributeNames are the names used for the built in OnCallAttributes in configuration and outputs.
+// User-defined attributes are named by the Attributes they were registered in
+var onCallAttributeNames = map[OnCallAttribute]string{
+	Unknown:     "unknown",
+	Business:    "business",
+	AfterHours:  "after_hours",
+	Weekend:     "weekend",
+	StatHoliday: "stat_holiday",
+	CompanyDay:  "company_day",
+}
+
+// String returns the name of the built in attribute, e.g. "after_hours". User-defined attributes
+// are "unknown", use Attributes.Name for their names
+func (a OnCallAttribute) String() string {
+	if name, exists := onCallAttributeNames[a]; exists {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseOnCallAttribute returns the built in OnCallAttribute with the provided name
+func ParseOnCallAttribute(name string) (OnCallAttribute, error) {
+	for _, attr := range BuiltInAttributes() {
+		if attr.String() == name {
+			return attr, nil
+		}
+	}
+	return Unknown, fmt.Errorf("unknown on-call attribute %q", name)
+}
+
+// IsBuiltIn returns true for the attributes that aren't user-defined
+func (a OnCallAttribute) IsBuiltIn() bool {
+	return a <= CompanyDay
+}
+
+// BuiltInAttributes returns the built in attributes in order
+func BuiltInAttributes() []OnCallAttribute {
+	return []OnCallAttribute{Business, AfterHours, Weekend, StatHoliday, CompanyDay}
+}
+
+// Attributes are the user-defined attributes of a config, like its time bands, and their names. Every Attributes
+// numbers its own attributes after the built in ones, so configs with time bands of the same name don't share them
+// and attributes are only meaningful with the Attributes they were registered in.
+// A nil Attributes only knows the built in attributes
+type Attributes struct {
+	mu     sync.RWMutex
+	custom []OnCallAttribute
+	names  map[OnCallAttribute]string
+}
+
+// NewAttributes returns Attributes without any user-defined attributes
+func NewAttributes() *Attributes {
+	return &Attributes{names: map[OnCallAttribute]string{}}
+}
+
+// Register adds a user-defined attribute with the provided name after the built in ones.
+// Registering a name again returns the same attribute
+func (attrs *Attributes) Register(name string) (OnCallAttribute, error) {
+	attrs.mu.Lock()
+	defer attrs.mu.Unlock()
+	if attr, err := attrs.parse(name); err == nil {
+		if attr.IsBuiltIn() {
+			return Unknown, fmt.Errorf("%q is a built in on-call attribute", name)
+		}
+		return attr, nil
+	}
+	if name == "" || name == Unknown.String() {
+		return Unknown, fmt.Errorf("invalid on-call attribute name %q", name)
+	}
+	if attrs.names == nil {
+		attrs.names = map[OnCallAttribute]string{}
+	}
+	attr := CompanyDay + OnCallAttribute(len(attrs.custom)+1)
+	attrs.names[attr] = name
+	attrs.custom = append(attrs.custom, attr)
+	return attr, nil
+}
+
+// Name returns the name of the built in or registered attribute attr, "unknown" if it isn't either
+func (attrs *Attributes) Name(attr OnCallAttribute) string {
+	if attrs == nil || attr.IsBuiltIn() {
+		return attr.String()
+	}
+	attrs.mu.RLock()
+	defer attrs.mu.RUnlock()
+	if name, exists := attrs.names[attr]; exists {
+		return name
+	}
+	return Unknown.String()
+}
+
+// Parse returns the built in or registered OnCallAttribute with the provided name
+func (attrs *Attributes) Parse(name string) (OnCallAttribute, error) {
+	if attrs == nil {
+		return ParseOnCallAttribute(name)
+	}
+	attrs.mu.RLock()
+	defer attrs.mu.RUnlock()
+	return attrs.parse(name)
+}
+
+// parse is Parse for callers holding attrs.mu
+func (attrs *Attributes) parse(name string) (OnCallAttribute, error) {
+	for _, attr := range attrs.custom {
+		if attrs.names[attr] == name {
+			return attr, nil
+		}
+	}
+	return ParseOnCallAttribute(name)
+}
+
+// All returns the built in attributes in order, followed by the registered ones in the order they were added
+func (attrs *Attributes) All() []OnCallAttribute {
+	if attrs == nil {
+		return BuiltInAttributes()
+	}
+	attrs.mu.RLock()
+	defer attrs.mu.RUnlock()
+	return append(BuiltInAttributes(), attrs.custom...)
+}
+
 // TotalShifts returns the total number of shifts
 func (u UserShiftResults) TotalShifts() int {
 	return len(u.Shifts)
@@ -126,6 +366,17 @@ func (spans AttributedSpans) CompanyDayDur() time.Duration {
 	return durs
 }
 
+// Dur returns the duration of the spans with the attribute attr
+func (spans AttributedSpans) Dur(attr OnCallAttribute) time.Duration {
+	var durs time.Duration
+	for _, span := range spans {
+		if span.SpanType == attr {
+			durs += span.Duration()
+		}
+	}
+	return durs
+}
+
 // CompanyDayCount returns duration of company days holiday hours on call
 func (spans AttributedSpans) CompanyDayCount() int {
 	var count int
@@ -137,6 +388,64 @@ func (spans AttributedSpans) CompanyDayCount() int {
 	return count
 }
 
+// Names returns the distinct names of the spans with the attribute attr, sorted
+func (spans AttributedSpans) Names(attr OnCallAttribute) []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, span := range spans {
+		if span.SpanType != attr || span.Name == "" {
+			continue
+		}
+		if !seen[span.Name] {
+			seen[span.Name] = true
+			names = append(names, span.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DayCount returns the number of distinct calendar days in loc where spans with the
+// attribute attr add up to at least minDur. Any time at all counts if minDur is 0
+func (spans AttributedSpans) DayCount(attr OnCallAttribute, minDur time.Duration, loc *time.Location) int {
+	return len(spans.qualifyingDays(attr, minDur, loc))
+}
+
+// MonthlyDayCount returns DayCount per calendar month in loc, keyed by the month as 2006-01
+func (spans AttributedSpans) MonthlyDayCount(attr OnCallAttribute, minDur time.Duration, loc *time.Location) map[string]int {
+	months := map[string]int{}
+	for _, day := range spans.qualifyingDays(attr, minDur, loc) {
+		months[day.Format(monthFormat)]++
+	}
+	return months
+}
+
+// qualifyingDays returns the start of every calendar day in loc where spans with the
+// attribute attr add up to at least minDur
+func (spans AttributedSpans) qualifyingDays(attr OnCallAttribute, minDur time.Duration, loc *time.Location) []time.Time {
+	perDay := map[string]time.Duration{}
+	starts := map[string]time.Time{}
+	for _, span := range spans {
+		if span.SpanType != attr {
+			continue
+		}
+		end := span.End().In(loc)
+		for day := StartOfDay(span.Start().In(loc)); day.Before(end); day = day.AddDate(0, 0, 1) {
+			if portion, overlap := span.Span.Intersection(New(day, day.AddDate(0, 0, 1))); overlap {
+				perDay[day.Format(spanDateFormat)] += portion.Duration()
+				starts[day.Format(spanDateFormat)] = day
+			}
+		}
+	}
+	days := []time.Time{}
+	for key, dur := range perDay {
+		if dur > 0 && dur >= minDur {
+			days = append(days, starts[key])
+		}
+	}
+	return days
+}
+
 // TotalDur returns the total duration on call
 func (spans AttributedSpans) TotalDur() time.Duration {
 	var totalDur time.Duration
@@ -147,6 +456,17 @@ func (spans AttributedSpans) TotalDur() time.Duration {
 	return totalDur
 }
 
+// Without returns spans with the parts that overlap any of others removed, keeping their attributes
+func (spans AttributedSpans) Without(others []Span) AttributedSpans {
+	result := AttributedSpans{}
+	for _, span := range spans {
+		for _, piece := range Subtract([]Span{span.Span}, others) {
+			result = append(result, AttributedSpan{Span: piece, SpanType: span.SpanType, Name: span.Name, Stacked: span.Stacked})
+		}
+	}
+	return result
+}
+
 func (spans AttributedSpans) Less(a, b int) bool {
 	return spans[a].start.Before(spans[b].start)
 }
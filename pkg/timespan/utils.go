@@ -108,3 +108,30 @@ func FindLastEnd(spans []Span) time.Time {
 	}
 	return latestEnd
 }
+
+// Subtract returns spans with the parts that overlap any of others removed
+func Subtract(spans []Span, others []Span) []Span {
+	result := []Span{}
+	for _, span := range spans {
+		pieces := []Span{span}
+		for _, other := range others {
+			remaining := []Span{}
+			for _, piece := range pieces {
+				overlap, overlaps := piece.Intersection(other)
+				if !overlaps {
+					remaining = append(remaining, piece)
+					continue
+				}
+				if piece.start.Before(overlap.start) {
+					remaining = append(remaining, New(piece.start, overlap.start))
+				}
+				if piece.end.After(overlap.end) {
+					remaining = append(remaining, New(overlap.end, piece.end))
+				}
+			}
+			pieces = remaining
+		}
+		result = append(result, pieces...)
+	}
+	return result
+}